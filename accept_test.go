@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestPrefersJSON(t *testing.T) {
+	t.Run("should prefer JSON only when it outranks HTML", func(t *testing.T) {
+		for accept, want := range map[string]bool{
+			"":                                  false,
+			"*/*":                               false,
+			"text/html":                         false,
+			"application/json":                  true,
+			"application/json, */*":             true,
+			"Application/JSON":                  true,
+			"text/html, application/json":       false,
+			"text/html;q=0.5, application/json": true,
+			"application/json;q=0.5, text/html": false,
+			"application/json;q=0":              false,
+			"application/json;q=oops":           false,
+			"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8": false,
+		} {
+			req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+			req.Header.Set("Accept", accept)
+
+			should.BeEqual(t, prefersJSON(req), want, should.WithMessage("Accept: "+accept))
+		}
+	})
+}
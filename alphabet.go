@@ -0,0 +1,32 @@
+package main
+
+import "fmt"
+
+// Values of SNIPLINK_CODE_ALPHABET
+const (
+	alphabetDefault     = "default"
+	alphabetUnambiguous = "unambiguous"
+)
+
+const (
+	// defaultAlphabet lists the characters random short codes are made of
+	defaultAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// unambiguousAlphabet leaves out 0, O, 1, l and I, which are easily
+	// mistaken for one another when a printed code is typed back in
+	unambiguousAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// codeAlphabet is the alphabet of short codes, configured through
+// SNIPLINK_CODE_ALPHABET
+var codeAlphabet = defaultAlphabet
+
+// parseCodeAlphabet returns the alphabet selected by an alphabet setting
+func parseCodeAlphabet(raw string) (string, error) {
+	switch raw {
+	case alphabetDefault:
+		return defaultAlphabet, nil
+	case alphabetUnambiguous:
+		return unambiguousAlphabet, nil
+	}
+	return "", fmt.Errorf("code alphabet %q is not %q or %q", raw, alphabetDefault, alphabetUnambiguous)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestParseCodeAlphabet(t *testing.T) {
+	t.Run("should select the presets", func(t *testing.T) {
+		alphabet, err := parseCodeAlphabet(alphabetDefault)
+		should.BeNil(t, err)
+		should.BeEqual(t, alphabet, defaultAlphabet)
+		alphabet, err = parseCodeAlphabet(alphabetUnambiguous)
+		should.BeNil(t, err)
+		should.BeEqual(t, alphabet, unambiguousAlphabet)
+	})
+
+	t.Run("should reject unknown alphabets", func(t *testing.T) {
+		_, err := parseCodeAlphabet("emoji")
+		should.NotBeNil(t, err)
+	})
+}
+
+func TestUnambiguousAlphabet(t *testing.T) {
+	t.Run("should leave out look-alike characters only", func(t *testing.T) {
+		should.BeEqual(t, len(unambiguousAlphabet), len(defaultAlphabet)-5)
+		for _, char := range "0O1lI" {
+			should.BeFalse(t, strings.ContainsRune(unambiguousAlphabet, char), should.WithMessage(string(char)+" should be left out"))
+		}
+	})
+
+	t.Run("should generate codes without look-alike characters", func(t *testing.T) {
+		codeAlphabet = unambiguousAlphabet
+		defer func() { codeAlphabet = defaultAlphabet }()
+
+		for i := 0; i < 1000; i++ {
+			code, err := generateShortCode(8)
+			should.BeNil(t, err)
+			should.BeFalse(t, strings.ContainsAny(code, "0O1lI"), should.WithMessage("Code "+code+" has a look-alike character"))
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// apiKey is the bearer token required by write endpoints and the link
+// listings, an empty key leaves them open
+var apiKey string
+
+// authMiddleware rejects requests with 401 unless they carry
+// "Authorization: Bearer <apiKey>". The scheme is case-insensitive and the
+// key is compared in constant time
+func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if apiKey != "" {
+			token, found := bearerToken(r)
+			if !found || subtle.ConstantTimeCompare([]byte(token), []byte(apiKey)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				errorResponse(w, "Missing or invalid API key", errCodeUnauthorized, http.StatusUnauthorized)
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// keyRequiredMiddleware answers 404 while no apiKey is configured, for the
+// endpoints that must not be left open along with the others
+func keyRequiredMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if apiKey == "" {
+			notFoundHandler(w, r)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// bearerToken returns the token of a bearer Authorization header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	apiKey = "secret"
+	defer func() { apiKey = "" }()
+
+	handler := authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	send := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/shorten", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	t.Run("should accept the correct key", func(t *testing.T) {
+		w := send("Bearer secret")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+	})
+
+	t.Run("should accept any case of the scheme", func(t *testing.T) {
+		for _, authorization := range []string{"bearer secret", "BEARER secret", "Bearer  secret"} {
+			w := send(authorization)
+
+			should.BeEqual(t, w.Code, http.StatusOK, should.WithMessage("Authorization: "+authorization))
+		}
+	})
+
+	t.Run("should reject a wrong key", func(t *testing.T) {
+		for _, authorization := range []string{"Bearer wrong", "Bearer secret2", "Basic secret", "secret", "Bearer "} {
+			w := send(authorization)
+
+			should.BeEqual(t, w.Code, http.StatusUnauthorized, should.WithMessage("Authorization: "+authorization))
+			should.BeEqual(t, decodeError(t, w).Code, errCodeUnauthorized)
+		}
+	})
+
+	t.Run("should reject a missing header", func(t *testing.T) {
+		w := send("")
+
+		should.BeEqual(t, w.Code, http.StatusUnauthorized)
+		should.BeEqual(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+	})
+
+	t.Run("should allow everything without a configured key", func(t *testing.T) {
+		apiKey = ""
+		defer func() { apiKey = "secret" }()
+
+		w := send("")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+	})
+}
+
+func TestRouteAuth(t *testing.T) {
+	apiKey = "secret"
+	defer func() { apiKey = "" }()
+
+	t.Run("should leave redirects unauthenticated", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/abc123", nil))
+
+		should.BeEqual(t, w.Code, redirectStatus)
+	})
+
+	t.Run("should leave stats unauthenticated", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/stats/abc123", nil))
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+	})
+
+	t.Run("should require the key to delete", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodDelete, "/abc123", nil))
+
+		should.BeEqual(t, w.Code, http.StatusUnauthorized)
+		_, exists, _ := store.Get("abc123")
+		should.BeTrue(t, exists, should.WithMessage("Unauthenticated delete should keep the link"))
+
+		req := httptest.NewRequest(http.MethodDelete, "/abc123", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w = httptest.NewRecorder()
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusNoContent)
+	})
+
+	t.Run("should require the key to list links", func(t *testing.T) {
+		resetStore()
+
+		for _, target := range []string{"/links", "/links/search?q=example"} {
+			w := httptest.NewRecorder()
+			route(w, httptest.NewRequest(http.MethodGet, target, nil))
+			should.BeEqual(t, w.Code, http.StatusUnauthorized, should.WithMessage(target+" without the key"))
+
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			w = httptest.NewRecorder()
+			route(w, req)
+			should.BeEqual(t, w.Code, http.StatusOK, should.WithMessage(target+" with the key"))
+		}
+	})
+}
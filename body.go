@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxBodyBytes is the largest accepted request body unless
+// SNIPLINK_MAX_BODY_BYTES overrides it
+const defaultMaxBodyBytes = 1 << 20
+
+// maxBodyBytes is the largest request body decodeJSONBody reads
+var maxBodyBytes int64 = defaultMaxBodyBytes
+
+var errTrailingData = errors.New("request body must hold a single JSON value")
+
+// Media types of the request bodies write endpoints accept
+const (
+	mediaTypeJSON = "application/json"
+	mediaTypeForm = "application/x-www-form-urlencoded"
+)
+
+var errMaxBodyBytes = errors.New("max body bytes must be a positive integer")
+
+// parseMaxBodyBytes parses the request body size limit setting
+func parseMaxBodyBytes(raw string) (int64, error) {
+	limit, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || limit < 1 {
+		return 0, errMaxBodyBytes
+	}
+	return limit, nil
+}
+
+// bodyLimitMiddleware caps every request body at maxBodyBytes. Requests that
+// declare a larger Content-Length are rejected with 413 before any handler
+// runs, others fail with *http.MaxBytesError once they read past the limit
+func bodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBodyBytes {
+			errorResponse(w, "Request body too large", errCodeBodyTooLarge, http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// contentTypeMiddleware rejects POST, PUT and PATCH requests with 415 unless
+// their Content-Type is one of mediaTypes. Parameters such as charset are
+// ignored, a missing Content-Type is rejected
+func contentTypeMiddleware(mediaTypes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch:
+				mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+				if err != nil || !slices.Contains(mediaTypes, mediaType) {
+					w.Header().Set("Accept", strings.Join(mediaTypes, ", "))
+					errorResponse(w, "Content-Type must be "+strings.Join(mediaTypes, " or "),
+						errCodeMediaType, http.StatusUnsupportedMediaType)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// decodeJSONBody decodes the request body into dst. Bodies larger than
+// maxBodyBytes are rejected with 413, also when the handler is called without
+// bodyLimitMiddleware, and unknown fields or data after the value with 400,
+// so typos in field names are reported instead of silently ignored
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst any) *apiError {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err == nil {
+		err = decodeEnd(decoder)
+	}
+	if err == nil {
+		return nil
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return &apiError{"Request body too large", errCodeBodyTooLarge, http.StatusRequestEntityTooLarge}
+	}
+	if errors.Is(err, errTrailingData) {
+		return &apiError{"Request body must hold a single JSON value", errCodeInvalidBody, http.StatusBadRequest}
+	}
+	// encoding/json has no error type for unknown fields
+	if field, found := strings.CutPrefix(err.Error(), "json: unknown field "); found {
+		return &apiError{"Unknown field " + field, errCodeInvalidBody, http.StatusBadRequest}
+	}
+	return &apiError{"Invalid request body", errCodeInvalidBody, http.StatusBadRequest}
+}
+
+// decodeEnd checks that nothing but whitespace follows the value decoder
+// read, failing with errTrailingData otherwise
+func decodeEnd(decoder *json.Decoder) error {
+	var extra json.RawMessage
+	err := decoder.Decode(&extra)
+	if err == io.EOF {
+		return nil
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return err
+	}
+	return errTrailingData
+}
+
+// isFormBody reports whether the request declares a form encoded body, as
+// sent by plain HTML forms
+func isFormBody(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == mediaTypeForm
+}
+
+// parseFormBody parses a form encoded request body with the size limit of
+// decodeJSONBody. Unknown fields are ignored since forms also submit their
+// buttons
+func parseFormBody(w http.ResponseWriter, r *http.Request) *apiError {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	err := r.ParseForm()
+	if err == nil {
+		return nil
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return &apiError{"Request body too large", errCodeBodyTooLarge, http.StatusRequestEntityTooLarge}
+	}
+	return &apiError{"Invalid request body", errCodeInvalidBody, http.StatusBadRequest}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+// jsonRequest returns a test request declaring a JSON body, as the write
+// routes require
+func jsonRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("Content-Type", mediaTypeJSON)
+	return req
+}
+
+func TestDecodeJSONBody(t *testing.T) {
+	postShorten := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		shortenHandler(w, req)
+		return w
+	}
+
+	t.Run("should reject bodies over the limit", func(t *testing.T) {
+		resetStore()
+		maxBodyBytes = 64
+		defer func() { maxBodyBytes = defaultMaxBodyBytes }()
+
+		w := postShorten(`{"original":"https://example.com/` + strings.Repeat("a", 64) + `"}`)
+
+		should.BeEqual(t, w.Code, http.StatusRequestEntityTooLarge)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeBodyTooLarge)
+		should.BeEmpty(t, storedLinks(t), should.WithMessage("Rejected request should not create a link"))
+	})
+
+	t.Run("should accept bodies within the limit", func(t *testing.T) {
+		resetStore()
+		maxBodyBytes = 64
+		defer func() { maxBodyBytes = defaultMaxBodyBytes }()
+
+		w := postShorten(`{"original":"https://example.com"}`)
+
+		should.BeEqual(t, w.Code, http.StatusCreated)
+	})
+
+	t.Run("should reject data after the JSON value", func(t *testing.T) {
+		resetStore()
+
+		for _, body := range []string{
+			`{"original":"https://example.com/a"} garbage`,
+			`{"original":"https://example.com/a"}{"original":"https://example.com/b"}`,
+		} {
+			w := postShorten(body)
+
+			should.BeEqual(t, w.Code, http.StatusBadRequest, should.WithMessage("Should reject "+body))
+			should.BeEqual(t, decodeError(t, w).Error, "Request body must hold a single JSON value")
+		}
+		should.BeEmpty(t, storedLinks(t))
+	})
+
+	t.Run("should accept whitespace after the JSON value", func(t *testing.T) {
+		resetStore()
+
+		w := postShorten("{\"original\":\"https://example.com\"}\n")
+
+		should.BeEqual(t, w.Code, http.StatusCreated)
+	})
+
+	t.Run("should reject unknown fields", func(t *testing.T) {
+		resetStore()
+
+		w := postShorten(`{"orignal":"https://example.com"}`)
+
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+		body := decodeError(t, w)
+		should.BeEqual(t, body.Code, errCodeInvalidBody)
+		should.BeEqual(t, body.Error, `Unknown field "orignal"`)
+	})
+
+	t.Run("should apply to batch and update requests", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		req := httptest.NewRequest(http.MethodPost, "/shorten/batch", strings.NewReader(`[{"url":"https://example.com"}]`))
+		w := httptest.NewRecorder()
+		batchShortenHandler(w, req)
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+
+		req = jsonRequest(http.MethodPut, "/shorten/abc123", strings.NewReader(`{"original":"https://example.org","extra":1}`))
+		w = httptest.NewRecorder()
+		route(w, req)
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+	})
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	t.Run("should reject a declared length over the limit up front", func(t *testing.T) {
+		resetStore()
+		maxBodyBytes = 64
+		defer func() { maxBodyBytes = defaultMaxBodyBytes }()
+
+		req := httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(strings.Repeat("a", 65)))
+		w := httptest.NewRecorder()
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusRequestEntityTooLarge)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeBodyTooLarge)
+	})
+
+	t.Run("should cut off bodies of unknown length at the limit", func(t *testing.T) {
+		resetStore()
+		maxBodyBytes = 64
+		defer func() { maxBodyBytes = defaultMaxBodyBytes }()
+
+		var readErr error
+		handler := bodyLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, readErr = io.ReadAll(r.Body)
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(strings.Repeat("a", 65)))
+		req.ContentLength = -1
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		var tooLarge *http.MaxBytesError
+		should.BeTrue(t, errors.As(readErr, &tooLarge), should.WithMessage("Reading past the limit should fail"))
+	})
+
+	t.Run("should pass bodies within the limit", func(t *testing.T) {
+		resetStore()
+
+		req := jsonRequest(http.MethodPost, "/shorten", strings.NewReader(`{"original":"https://example.com"}`))
+		w := httptest.NewRecorder()
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusCreated)
+	})
+}
+
+func TestContentTypeMiddleware(t *testing.T) {
+	send := func(method, target, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		route(w, req)
+		return w
+	}
+
+	t.Run("should reject other media types with 415", func(t *testing.T) {
+		resetStore()
+
+		for _, contentType := range []string{"", "text/plain", "application/xml", "text/xml; charset=utf-8", "application/jsonp", "not a media type"} {
+			w := send(http.MethodPost, "/shorten", contentType, `{"original":"https://example.com"}`)
+
+			should.BeEqual(t, w.Code, http.StatusUnsupportedMediaType, should.WithMessage("Content-Type "+contentType))
+			should.BeEqual(t, decodeError(t, w).Code, errCodeMediaType)
+			should.BeEqual(t, w.Header().Get("Accept"), mediaTypeJSON+", "+mediaTypeForm)
+		}
+		should.BeEmpty(t, storedLinks(t))
+	})
+
+	t.Run("should accept JSON with parameters", func(t *testing.T) {
+		resetStore()
+
+		for _, contentType := range []string{"application/json", "application/json; charset=utf-8", "Application/JSON;charset=UTF-8"} {
+			w := send(http.MethodPost, "/shorten", contentType, `{"original":"https://example.com"}`)
+
+			should.BeTrue(t, w.Code == http.StatusCreated || w.Code == http.StatusOK, should.WithMessage("Content-Type "+contentType))
+		}
+	})
+
+	t.Run("should accept form bodies on /shorten only", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := send(http.MethodPost, "/shorten", mediaTypeForm, "original=https%3A%2F%2Fexample.com")
+		should.BeEqual(t, w.Code, http.StatusCreated)
+
+		w = send(http.MethodPost, "/shorten/batch", mediaTypeForm, "original=https%3A%2F%2Fexample.com")
+		should.BeEqual(t, w.Code, http.StatusUnsupportedMediaType)
+		should.BeEqual(t, w.Header().Get("Accept"), mediaTypeJSON)
+
+		for _, target := range []string{"/abc123", "/shorten/abc123"} {
+			w = send(http.MethodPut, target, "text/plain", `{"original":"https://example.org"}`)
+			should.BeEqual(t, w.Code, http.StatusUnsupportedMediaType, should.WithMessage("PUT "+target))
+		}
+	})
+
+	t.Run("should not check requests without a body", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := send(http.MethodDelete, "/shorten/abc123", "", "")
+
+		should.BeEqual(t, w.Code, http.StatusNoContent)
+	})
+}
+
+func TestParseMaxBodyBytes(t *testing.T) {
+	t.Run("should parse a positive limit", func(t *testing.T) {
+		limit, err := parseMaxBodyBytes("4096")
+		should.BeNil(t, err)
+		should.BeEqual(t, limit, int64(4096))
+	})
+
+	for _, raw := range []string{"0", "-1", "1MB", ""} {
+		t.Run("should reject "+raw, func(t *testing.T) {
+			_, err := parseMaxBodyBytes(raw)
+			should.BeEqual(t, err, errMaxBodyBytes)
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltOpenTimeout bounds the wait for the file lock, another process holding
+// the database fails the open instead of hanging the startup
+const boltOpenTimeout = time.Second
+
+// Buckets of the bolt store. Records are JSON encoded URLRecords under their
+// short code, clicks are the total and bot clicks as big endian uint64s and
+// originals maps an original URL to its short code
+var (
+	boltURLsBucket      = []byte("urls")
+	boltClicksBucket    = []byte("clicks")
+	boltOriginalsBucket = []byte("originals")
+)
+
+// ErrNotFound is what the transactions of the bolt store return for a short
+// code missing from the urls bucket, the URLStore methods report it as false
+var ErrNotFound = errors.New("short code not found")
+
+// boltStore is the URLStore kept in an embedded bbolt database, a single
+// file that needs no server. bbolt serializes writes itself, every method
+// but Resolve runs in one transaction
+type boltStore struct {
+	db *bolt.DB
+	// count is the number of links, read from the urls bucket at open and
+	// kept up to date by every committed insert and delete
+	count atomic.Int64
+}
+
+// newBoltStore opens the database at path, creating it and its directory
+// when missing
+func newBoltStore(path string) (*boltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, err
+	}
+	s := &boltStore{db: db}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltURLsBucket, boltClicksBucket, boltOriginalsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		s.count.Store(int64(tx.Bucket(boltURLsBucket).Stats().KeyN))
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database and releases its file lock
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+// boltRecord reads the record stored under shortCode in tx, or returns
+// ErrNotFound
+func boltRecord(tx *bolt.Tx, shortCode string) (URLRecord, error) {
+	data := tx.Bucket(boltURLsBucket).Get([]byte(shortCode))
+	if data == nil {
+		return URLRecord{}, ErrNotFound
+	}
+	var record URLRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return URLRecord{}, err
+	}
+	return record, nil
+}
+
+// boltFound turns the ErrNotFound of a transaction into the false the
+// URLStore methods report for a missing short code
+func boltFound(err error) (bool, error) {
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// boltClicks reads the click counts of shortCode in tx, missing counts are 0
+func boltClicks(tx *bolt.Tx, shortCode string) ClickCounts {
+	data := tx.Bucket(boltClicksBucket).Get([]byte(shortCode))
+	var clicks ClickCounts
+	// Clicks counted before bots were told apart only have the total
+	if len(data) >= 8 {
+		clicks.Total = binary.BigEndian.Uint64(data)
+	}
+	if len(data) == 16 {
+		clicks.Bot = binary.BigEndian.Uint64(data[8:])
+	}
+	return clicks
+}
+
+// boltPut stores the record under shortCode in tx and points the original
+// URL index at it, dropping the index entry of the record it replaces
+func boltPut(tx *bolt.Tx, shortCode string, record URLRecord) error {
+	if err := boltUnindex(tx, shortCode); err != nil {
+		return err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltURLsBucket).Put([]byte(shortCode), data); err != nil {
+		return err
+	}
+	return tx.Bucket(boltOriginalsBucket).Put([]byte(record.Original), []byte(shortCode))
+}
+
+// boltUnindex drops shortCode from the original URL index in tx
+func boltUnindex(tx *bolt.Tx, shortCode string) error {
+	record, err := boltRecord(tx, shortCode)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	originals := tx.Bucket(boltOriginalsBucket)
+	if bytes.Equal(originals.Get([]byte(record.Original)), []byte(shortCode)) {
+		return originals.Delete([]byte(record.Original))
+	}
+	return nil
+}
+
+// boltDelete removes shortCode, its clicks and its index entry in tx
+func boltDelete(tx *bolt.Tx, shortCode string) error {
+	if err := boltUnindex(tx, shortCode); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltClicksBucket).Delete([]byte(shortCode)); err != nil {
+		return err
+	}
+	return tx.Bucket(boltURLsBucket).Delete([]byte(shortCode))
+}
+
+// Get returns the record stored under the given short code
+func (s *boltStore) Get(shortCode string) (URLRecord, bool, error) {
+	var record URLRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		record, err = boltRecord(tx, shortCode)
+		return err
+	})
+	exists, err := boltFound(err)
+	return record, exists, err
+}
+
+// Resolve returns the record stored under the given short code and counts
+// it as a click, by a bot or not, unless the record has expired. The record
+// is read on its own and the click is counted through db.Batch, which
+// commits the clicks of concurrent redirects together instead of syncing
+// the file for each
+func (s *boltStore) Resolve(shortCode string, now time.Time, bot bool) (URLRecord, bool, error) {
+	record, exists, err := s.Get(shortCode)
+	if err != nil || !exists || record.expired(now) {
+		return record, exists, err
+	}
+	err = s.db.Batch(func(tx *bolt.Tx) error {
+		// The link may have been deleted since it was read
+		if tx.Bucket(boltURLsBucket).Get([]byte(shortCode)) == nil {
+			return nil
+		}
+		clicks := boltClicks(tx, shortCode).add(bot)
+		data := binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint64(nil, clicks.Total), clicks.Bot)
+		return tx.Bucket(boltClicksBucket).Put([]byte(shortCode), data)
+	})
+	return record, true, err
+}
+
+// Stats returns the record stored under the given short code and the number
+// of times it was resolved
+func (s *boltStore) Stats(shortCode string) (URLRecord, ClickCounts, bool, error) {
+	var (
+		record URLRecord
+		clicks ClickCounts
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		record, err = boltRecord(tx, shortCode)
+		clicks = boltClicks(tx, shortCode)
+		return err
+	})
+	exists, err := boltFound(err)
+	return record, clicks, exists, err
+}
+
+// LookupOriginal returns the short code of an unexpired link to originalURL
+func (s *boltStore) LookupOriginal(originalURL string, now time.Time) (string, bool, error) {
+	var (
+		shortCode string
+		exists    bool
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		code := tx.Bucket(boltOriginalsBucket).Get([]byte(originalURL))
+		if code == nil {
+			return nil
+		}
+		record, err := boltRecord(tx, string(code))
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil || record.expired(now) {
+			return err
+		}
+		shortCode, exists = string(code), true
+		return nil
+	})
+	return shortCode, exists, err
+}
+
+// Set stores the record under the given short code, keeping its click count
+// when the code already exists
+func (s *boltStore) Set(shortCode string, record URLRecord) error {
+	added := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		added = tx.Bucket(boltURLsBucket).Get([]byte(shortCode)) == nil
+		return boltPut(tx, shortCode, record)
+	})
+	if err == nil && added {
+		s.count.Add(1)
+	}
+	return err
+}
+
+// SetIfAbsent stores the record under the given short code unless the code
+// is already taken, and reports whether it was stored
+func (s *boltStore) SetIfAbsent(shortCode string, record URLRecord) (bool, error) {
+	stored := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltURLsBucket).Get([]byte(shortCode)) != nil {
+			return nil
+		}
+		stored = true
+		return boltPut(tx, shortCode, record)
+	})
+	if err != nil {
+		return false, err
+	}
+	if stored {
+		s.count.Add(1)
+	}
+	return stored, nil
+}
+
+// SetAllIfAbsent stores every record under its short code unless one of the
+// codes is taken, then it stores none and returns the taken codes
+func (s *boltStore) SetAllIfAbsent(records map[string]URLRecord) ([]string, error) {
+	var taken []string
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		urls := tx.Bucket(boltURLsBucket)
+		for shortCode := range records {
+			if urls.Get([]byte(shortCode)) != nil {
+				taken = append(taken, shortCode)
+			}
+		}
+		if len(taken) > 0 {
+			return nil
+		}
+		for shortCode, record := range records {
+			if err := boltPut(tx, shortCode, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(taken) == 0 {
+		s.count.Add(int64(len(records)))
+	}
+	return taken, nil
+}
+
+// UpdateOriginal points an existing short code at originalURL, keeping the
+// rest of its record and its clicks, and returns the updated record
+func (s *boltStore) UpdateOriginal(shortCode, originalURL string) (URLRecord, bool, error) {
+	var record URLRecord
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		record, err = boltRecord(tx, shortCode)
+		if err != nil {
+			return err
+		}
+		record.Original = originalURL
+		return boltPut(tx, shortCode, record)
+	})
+	exists, err := boltFound(err)
+	if err != nil {
+		return URLRecord{}, false, err
+	}
+	return record, exists, nil
+}
+
+// Delete removes the mapping for the given short code and reports whether it existed
+func (s *boltStore) Delete(shortCode string) (bool, error) {
+	deleted := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltURLsBucket).Get([]byte(shortCode)) == nil {
+			return nil
+		}
+		deleted = true
+		return boltDelete(tx, shortCode)
+	})
+	if err != nil {
+		return false, err
+	}
+	if deleted {
+		s.count.Add(-1)
+	}
+	return deleted, nil
+}
+
+// DeleteExpired removes every record that has expired by now and returns
+// their short codes
+func (s *boltStore) DeleteExpired(now time.Time) ([]string, error) {
+	var removed []string
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		// bbolt forbids deleting keys while iterating over their bucket
+		var expired []string
+		err := tx.Bucket(boltURLsBucket).ForEach(func(k, v []byte) error {
+			var record URLRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if record.expired(now) {
+				expired = append(expired, string(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, shortCode := range expired {
+			if err := boltDelete(tx, shortCode); err != nil {
+				return err
+			}
+		}
+		removed = expired
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.count.Add(-int64(len(removed)))
+	return removed, nil
+}
+
+// Count returns the number of stored mappings
+func (s *boltStore) Count() (int, error) {
+	return int(s.count.Load()), nil
+}
+
+// List returns a copy of every stored mapping
+func (s *boltStore) List() (map[string]URLRecord, error) {
+	m := make(map[string]URLRecord)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltURLsBucket).ForEach(func(k, v []byte) error {
+			var record URLRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			m[string(k)] = record
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
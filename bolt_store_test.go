@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltStore(t *testing.T) {
+	openTemp := func(t *testing.T, path string) *boltStore {
+		s, err := newBoltStore(path)
+		should.BeNil(t, err, should.WithMessage("Database should open"))
+		t.Cleanup(func() { s.Close() })
+		return s
+	}
+
+	testURLStore(t, func(t *testing.T) URLStore {
+		return openTemp(t, filepath.Join(t.TempDir(), "urls.bolt"))
+	})
+
+	t.Run("should keep links and clicks across reopening", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "data", "urls.bolt")
+		first := openTemp(t, path)
+		first.Set("abc123", URLRecord{Original: "https://example.com"})
+		first.Resolve("abc123", time.Now(), false)
+		first.Close()
+
+		second := openTemp(t, path)
+		record, clicks, exists, _ := second.Stats("abc123")
+		should.BeTrue(t, exists, should.WithMessage("Stored link should survive a restart"))
+		should.BeEqual(t, record.Original, "https://example.com")
+		should.BeEqual(t, clicks.Total, uint64(1))
+		shortCode, _, _ := second.LookupOriginal("https://example.com", time.Now())
+		should.BeEqual(t, shortCode, "abc123", should.WithMessage("The original URL index should survive a restart"))
+	})
+
+	t.Run("should keep the link count across reopening", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "urls.bolt")
+		first := openTemp(t, path)
+		first.Set("abc123", URLRecord{Original: "https://example.com"})
+		first.Set("abc123", URLRecord{Original: "https://example.org"})
+		first.SetAllIfAbsent(map[string]URLRecord{"def456": {Original: "https://example.net"}, "ghi789": {Original: "https://example.io"}})
+		first.Delete("def456")
+		count, _ := first.Count()
+		should.BeEqual(t, count, 2)
+		first.Close()
+
+		second := openTemp(t, path)
+		count, _ = second.Count()
+		should.BeEqual(t, count, 2)
+	})
+
+	t.Run("should count every concurrent click", func(t *testing.T) {
+		s := openTemp(t, filepath.Join(t.TempDir(), "urls.bolt"))
+		s.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		var wg sync.WaitGroup
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.Resolve("abc123", time.Now(), false)
+			}()
+		}
+		wg.Wait()
+
+		_, clicks, _, _ := s.Stats("abc123")
+		should.BeEqual(t, clicks.Total, uint64(20))
+	})
+
+	t.Run("should read clicks counted before bots were told apart", func(t *testing.T) {
+		s := openTemp(t, filepath.Join(t.TempDir(), "urls.bolt"))
+		s.Set("abc123", URLRecord{Original: "https://example.com"})
+		s.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(boltClicksBucket).Put([]byte("abc123"), binary.BigEndian.AppendUint64(nil, 5))
+		})
+
+		s.Resolve("abc123", time.Now(), true)
+
+		_, clicks, _, err := s.Stats("abc123")
+		should.BeNil(t, err)
+		should.BeEqual(t, clicks, ClickCounts{Total: 6, Bot: 1})
+	})
+
+	t.Run("should return ErrNotFound for missing codes in transactions", func(t *testing.T) {
+		s := openTemp(t, filepath.Join(t.TempDir(), "urls.bolt"))
+
+		err := s.db.View(func(tx *bolt.Tx) error {
+			_, err := boltRecord(tx, "missing")
+			return err
+		})
+		should.BeEqual(t, err, ErrNotFound)
+	})
+
+	t.Run("should refuse a database another store holds open", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "urls.bolt")
+		openTemp(t, path)
+
+		_, err := newBoltStore(path)
+		should.NotBeNil(t, err, should.WithMessage("The file lock should time out"))
+	})
+}
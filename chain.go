@@ -0,0 +1,31 @@
+package main
+
+import "net/http"
+
+// Middleware wraps a handler with behavior that runs around it
+type Middleware func(next http.HandlerFunc) http.HandlerFunc
+
+// Chain is a list of middlewares applied together, so composing them needs
+// no nesting
+type Chain struct {
+	middlewares []Middleware
+}
+
+// NewChain returns a chain of ms. The first middleware is the outermost, it
+// runs first on the way in and last on the way out
+func NewChain(ms ...Middleware) Chain {
+	return Chain{middlewares: append([]Middleware(nil), ms...)}
+}
+
+// Then wraps h in every middleware of the chain
+func (c Chain) Then(h http.HandlerFunc) http.HandlerFunc {
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		h = c.middlewares[i](h)
+	}
+	return h
+}
+
+// Wrap is Then for http.Handler, the middleware signature of chi
+func (c Chain) Wrap(next http.Handler) http.Handler {
+	return c.Then(next.ServeHTTP)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestChain(t *testing.T) {
+	// tracing records the name of a middleware on the way in and on the way out
+	tracing := func(calls *[]string, name string) Middleware {
+		return func(next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				*calls = append(*calls, name+" in")
+				next(w, r)
+				*calls = append(*calls, name+" out")
+			}
+		}
+	}
+
+	t.Run("should run middlewares in declaration order around the handler", func(t *testing.T) {
+		var calls []string
+		handler := NewChain(tracing(&calls, "first"), tracing(&calls, "second"), tracing(&calls, "third")).
+			Then(func(w http.ResponseWriter, r *http.Request) { calls = append(calls, "handler") })
+
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		should.BeEqual(t, calls, []string{"first in", "second in", "third in", "handler", "third out", "second out", "first out"})
+	})
+
+	t.Run("should call the handler directly when empty", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		NewChain().Then(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		should.BeEqual(t, w.Code, http.StatusTeapot)
+	})
+
+	t.Run("should let middlewares stop the request", func(t *testing.T) {
+		var calls []string
+		stop := func(next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusForbidden) }
+		}
+		w := httptest.NewRecorder()
+		NewChain(tracing(&calls, "outer"), stop).
+			Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls = append(calls, "handler") })).
+			ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		should.BeEqual(t, w.Code, http.StatusForbidden)
+		should.BeEqual(t, calls, []string{"outer in", "outer out"})
+	})
+}
@@ -0,0 +1,146 @@
+// Package client is a Go client for the SnipLink HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls the SnipLink API at BaseURL. APIKey is sent as a bearer
+// token when set, and a nil HTTPClient uses http.DefaultClient
+type Client struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// ShortenResponse is the result of Shorten
+type ShortenResponse struct {
+	ShortCode string    `json:"short_code"`
+	ShortURL  string    `json:"short_url"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// StatsResponse is the result of Stats
+type StatsResponse struct {
+	ShortCode   string `json:"short_code"`
+	OriginalURL string `json:"original"`
+	Clicks      uint64 `json:"clicks"`
+	HumanClicks uint64 `json:"human_clicks"`
+	BotClicks   uint64 `json:"bot_clicks"`
+}
+
+// PreviewResponse is the result of Preview
+type PreviewResponse struct {
+	ShortCode   string    `json:"short_code"`
+	OriginalURL string    `json:"original_url"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+}
+
+// APIError is returned for responses with an error status, Code is the
+// machine readable code of the server's error envelope
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("sniplink: %d %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("sniplink: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Shorten creates a short link to original
+func (c *Client) Shorten(ctx context.Context, original string) (*ShortenResponse, error) {
+	body, err := json.Marshal(map[string]string{"original": original})
+	if err != nil {
+		return nil, err
+	}
+	var response ShortenResponse
+	if err := c.do(ctx, http.MethodPost, "/shorten", body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Delete removes the short link named by code
+func (c *Client) Delete(ctx context.Context, code string) error {
+	return c.do(ctx, http.MethodDelete, "/shorten/"+url.PathEscape(code), nil, nil)
+}
+
+// Stats returns how many times the short link named by code was followed
+func (c *Client) Stats(ctx context.Context, code string) (*StatsResponse, error) {
+	var response StatsResponse
+	if err := c.do(ctx, http.MethodGet, "/stats/"+url.PathEscape(code), nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Preview returns where the short link named by code points without
+// following it
+func (c *Client) Preview(ctx context.Context, code string) (*PreviewResponse, error) {
+	var response PreviewResponse
+	if err := c.do(ctx, http.MethodGet, "/preview/"+url.PathEscape(code), nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// do sends a request to path and decodes a successful JSON response into
+// out, which may be nil. Error statuses become an *APIError
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out any) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.BaseURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		var envelope struct {
+			Error string `json:"error"`
+			Code  string `json:"code"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&envelope) == nil && envelope.Error != "" {
+			apiErr.Message = envelope.Error
+			apiErr.Code = envelope.Code
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+)
+
+// newTestClient returns a Client talking to a server that runs handler
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &Client{BaseURL: server.URL, APIKey: "secret", HTTPClient: server.Client()}
+}
+
+func TestShorten(t *testing.T) {
+	t.Run("should post the URL and decode the short link", func(t *testing.T) {
+		expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			should.BeEqual(t, r.Method, http.MethodPost)
+			should.BeEqual(t, r.URL.Path, "/shorten")
+			should.BeEqual(t, r.Header.Get("Authorization"), "Bearer secret")
+			should.BeEqual(t, r.Header.Get("Content-Type"), "application/json")
+
+			var body map[string]string
+			should.BeNil(t, json.NewDecoder(r.Body).Decode(&body))
+			should.BeEqual(t, body, map[string]string{"original": "https://example.com"})
+
+			json.NewEncoder(w).Encode(map[string]any{
+				"short_code": "abc123",
+				"short_url":  "http://localhost:8080/abc123",
+				"expires_at": expiresAt,
+				"created_at": createdAt,
+			})
+		})
+
+		response, err := c.Shorten(context.Background(), "https://example.com")
+		should.BeNil(t, err)
+		should.BeEqual(t, response.ShortCode, "abc123")
+		should.BeEqual(t, response.ShortURL, "http://localhost:8080/abc123")
+		should.BeTrue(t, response.ExpiresAt.Equal(expiresAt), should.WithMessage("Expiry should be decoded"))
+		should.BeTrue(t, response.CreatedAt.Equal(createdAt), should.WithMessage("Creation time should be decoded"))
+	})
+
+	t.Run("should return the error envelope as an APIError", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"Invalid URL","code":"INVALID_URL"}`))
+		})
+
+		_, err := c.Shorten(context.Background(), "not a url")
+		var apiErr *APIError
+		should.BeTrue(t, errors.As(err, &apiErr), should.WithMessage("Error statuses should become an APIError"))
+		should.BeEqual(t, apiErr.StatusCode, http.StatusBadRequest)
+		should.BeEqual(t, apiErr.Code, "INVALID_URL")
+		should.BeEqual(t, apiErr.Message, "Invalid URL")
+	})
+
+	t.Run("should fall back to the status text without an envelope", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "upstream down", http.StatusBadGateway)
+		})
+
+		_, err := c.Shorten(context.Background(), "https://example.com")
+		var apiErr *APIError
+		should.BeTrue(t, errors.As(err, &apiErr))
+		should.BeEqual(t, apiErr.Code, "")
+		should.BeEqual(t, apiErr.Message, "Bad Gateway")
+	})
+
+	t.Run("should not send an Authorization header without an API key", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			should.BeEqual(t, r.Header.Get("Authorization"), "")
+			w.Write([]byte(`{"short_code":"abc123"}`))
+		})
+		c.APIKey = ""
+
+		_, err := c.Shorten(context.Background(), "https://example.com")
+		should.BeNil(t, err)
+	})
+}
+
+func TestDelete(t *testing.T) {
+	t.Run("should delete the code", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			should.BeEqual(t, r.Method, http.MethodDelete)
+			should.BeEqual(t, r.URL.Path, "/shorten/abc123")
+			should.BeEqual(t, r.Header.Get("Authorization"), "Bearer secret")
+			w.WriteHeader(http.StatusNoContent)
+		})
+
+		should.BeNil(t, c.Delete(context.Background(), "abc123"))
+	})
+
+	t.Run("should report missing codes", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"Short code not found","code":"NOT_FOUND"}`))
+		})
+
+		err := c.Delete(context.Background(), "missing")
+		var apiErr *APIError
+		should.BeTrue(t, errors.As(err, &apiErr))
+		should.BeEqual(t, apiErr.StatusCode, http.StatusNotFound)
+		should.BeEqual(t, apiErr.Code, "NOT_FOUND")
+	})
+}
+
+func TestStats(t *testing.T) {
+	t.Run("should decode the click count", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			should.BeEqual(t, r.Method, http.MethodGet)
+			should.BeEqual(t, r.URL.Path, "/stats/abc123")
+			w.Write([]byte(`{"short_code":"abc123","original":"https://example.com","clicks":7}`))
+		})
+
+		response, err := c.Stats(context.Background(), "abc123")
+		should.BeNil(t, err)
+		should.BeEqual(t, *response, StatsResponse{ShortCode: "abc123", OriginalURL: "https://example.com", Clicks: 7})
+	})
+}
+
+func TestPreview(t *testing.T) {
+	t.Run("should decode the destination and creation time", func(t *testing.T) {
+		createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			should.BeEqual(t, r.Method, http.MethodGet)
+			should.BeEqual(t, r.URL.Path, "/preview/abc123")
+			json.NewEncoder(w).Encode(map[string]any{
+				"short_code":   "abc123",
+				"original_url": "https://example.com",
+				"created_at":   createdAt,
+			})
+		})
+
+		response, err := c.Preview(context.Background(), "abc123")
+		should.BeNil(t, err)
+		should.BeEqual(t, response.OriginalURL, "https://example.com")
+		should.BeTrue(t, response.CreatedAt.Equal(createdAt), should.WithMessage("Creation time should be decoded"))
+	})
+
+	t.Run("should stop when the context is cancelled", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("Cancelled requests should not reach the server")
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := c.Preview(ctx, "abc123")
+		should.BeTrue(t, errors.Is(err, context.Canceled), should.WithMessage("Cancellation should be returned"))
+	})
+}
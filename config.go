@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+	"go.uber.org/zap/zapcore"
+)
+
+// envFile is loaded at startup so development setups need not export the
+// settings below, variables already set in the environment take precedence
+const envFile = ".env"
+
+// Environment variables the service is configured through
+const (
+	// Server
+	envAddr            = "SNIPLINK_ADDR"
+	envBaseURL         = "SNIPLINK_BASE_URL"
+	envShutdownTimeout = "SNIPLINK_SHUTDOWN_TIMEOUT"
+	envReadTimeout     = "SNIPLINK_READ_TIMEOUT"
+	envWriteTimeout    = "SNIPLINK_WRITE_TIMEOUT"
+	envIdleTimeout     = "SNIPLINK_IDLE_TIMEOUT"
+	envTLSCertFile     = "SNIPLINK_TLS_CERT_FILE"
+	envTLSKeyFile      = "SNIPLINK_TLS_KEY_FILE"
+	envDebugPort       = "SNIPLINK_DEBUG_PORT"
+	envLogFormat       = "SNIPLINK_LOG_FORMAT"
+	envLogLevel        = "SNIPLINK_LOG_LEVEL"
+	envFaviconFile     = "SNIPLINK_FAVICON_FILE"
+
+	// Storage
+	envStore      = "SNIPLINK_STORE"
+	envDataFile   = "SNIPLINK_DATA_FILE"
+	envMaxLinks   = "SNIPLINK_MAX_LINKS"
+	envSQLitePath = "SNIPLINK_SQLITE_PATH"
+	envRedisURL   = "SNIPLINK_REDIS_URL"
+	envBoltPath   = "SNIPLINK_BOLT_PATH"
+
+	// Short codes and redirects
+	envCodeLength     = "SNIPLINK_CODE_LENGTH"
+	envCodeMode       = "SNIPLINK_CODE_MODE"
+	envCodeAlphabet   = "SNIPLINK_CODE_ALPHABET"
+	envMaxAliasLength = "SNIPLINK_MAX_ALIAS_LENGTH"
+	envHMACSecret     = "SNIPLINK_HMAC_SECRET"
+	envRedirectStatus = "SNIPLINK_REDIRECT_STATUS"
+	envDeduplicate    = "SNIPLINK_DEDUPLICATE"
+
+	// Requests
+	envAPIKey          = "SNIPLINK_API_KEY"
+	envRateLimitRPS    = "SNIPLINK_RATE_LIMIT_RPS"
+	envRateLimitBurst  = "SNIPLINK_RATE_LIMIT_BURST"
+	envMaxBodyBytes    = "SNIPLINK_MAX_BODY_BYTES"
+	envBlockedNetworks = "SNIPLINK_BLOCKED_NETWORKS"
+	envTrustedProxies  = "SNIPLINK_TRUSTED_PROXIES"
+	envCORSOrigins     = "SNIPLINK_CORS_ORIGINS"
+)
+
+// envAlias is another name a setting is read from. convert, when set, turns
+// its value into one of the SNIPLINK_ variable, or into "" when the value
+// says nothing about the setting
+type envAlias struct {
+	name    string
+	convert func(string) string
+}
+
+// envAliases are the unprefixed names of the settings, as deployment
+// manifests and platforms setting PORT use them
+var envAliases = map[string][]envAlias{
+	envAddr:        {{name: "ADDR"}, {name: "PORT", convert: func(port string) string { return ":" + port }}},
+	envBaseURL:     {{name: "BASE_URL"}},
+	envRedisURL:    {{name: "REDIS_URL"}},
+	envTLSCertFile: {{name: "TLS_CERT_FILE"}},
+	envTLSKeyFile:  {{name: "TLS_KEY_FILE"}},
+	envLogFormat:   {{name: "LOG_FORMAT"}, {name: "ENV", convert: developmentLogFormat}},
+	envLogLevel:    {{name: "LOG_LEVEL"}},
+}
+
+// developmentLogFormat logs to the console when ENV is development and
+// leaves the format alone otherwise
+func developmentLogFormat(env string) string {
+	if env == "development" {
+		return logFormatConsole
+	}
+	return ""
+}
+
+// applyEnvAliases sets every unset SNIPLINK_ variable from the first of its
+// aliases that is set, the prefixed name wins when both are
+func applyEnvAliases() {
+	for key, aliases := range envAliases {
+		if os.Getenv(key) != "" {
+			continue
+		}
+		for _, alias := range aliases {
+			value := os.Getenv(alias.name)
+			if value == "" {
+				continue
+			}
+			if alias.convert != nil {
+				value = alias.convert(value)
+			}
+			if value == "" {
+				continue
+			}
+			os.Setenv(key, value)
+			break
+		}
+	}
+}
+
+// loadEnvFile sets the variables of the env file at path that are not set
+// yet. A missing file is not an error
+func loadEnvFile(path string) error {
+	err := godotenv.Load(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// flagConfig is the configuration the command line can set
+type flagConfig struct {
+	addr     string
+	dataFile string
+	logLevel string
+}
+
+// parseFlags parses the command line arguments args with fs. Flags default
+// to the environment, so a flag that is given overrides its variable
+func parseFlags(fs *flag.FlagSet, args []string) (flagConfig, error) {
+	host, port, err := net.SplitHostPort(getEnv(envAddr, defaultAddr))
+	if err != nil {
+		return flagConfig{}, fmt.Errorf("invalid %s: %w", envAddr, err)
+	}
+
+	var config flagConfig
+	fs.StringVar(&port, "port", port, "port to listen on, overrides the port of "+envAddr)
+	fs.StringVar(&config.dataFile, "data-file", getEnv(envDataFile, defaultDataFile),
+		"file the memory store persists links to, overrides "+envDataFile)
+	fs.StringVar(&config.logLevel, "log-level", os.Getenv(envLogLevel),
+		"minimum log level, debug, info, warn or error, overrides "+envLogLevel)
+	if err := fs.Parse(args); err != nil {
+		return flagConfig{}, err
+	}
+	if fs.NArg() > 0 {
+		return flagConfig{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return flagConfig{}, fmt.Errorf("port %q is not a number between 0 and 65535", port)
+	}
+	config.addr = net.JoinHostPort(host, port)
+	if config.logLevel != "" {
+		if _, err := zapcore.ParseLevel(config.logLevel); err != nil {
+			return flagConfig{}, err
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestLoadEnvFile(t *testing.T) {
+	// unsetEnv unsets key for the rest of the test and restores it afterwards
+	unsetEnv := func(t *testing.T, key string) {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	writeEnvFile := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), ".env")
+		should.BeNil(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("should set the variables of the file", func(t *testing.T) {
+		unsetEnv(t, envBaseURL)
+		unsetEnv(t, envCodeLength)
+		path := writeEnvFile(t, "# Local setup\n"+envBaseURL+"=http://localhost:9000\n"+envCodeLength+"=8\n")
+
+		should.BeNil(t, loadEnvFile(path))
+
+		should.BeEqual(t, os.Getenv(envBaseURL), "http://localhost:9000")
+		should.BeEqual(t, os.Getenv(envCodeLength), "8")
+	})
+
+	t.Run("should keep variables already set", func(t *testing.T) {
+		t.Setenv(envBaseURL, "https://snip.example")
+		path := writeEnvFile(t, envBaseURL+"=http://localhost:9000\n")
+
+		should.BeNil(t, loadEnvFile(path))
+
+		should.BeEqual(t, os.Getenv(envBaseURL), "https://snip.example")
+	})
+
+	t.Run("should ignore a missing file", func(t *testing.T) {
+		should.BeNil(t, loadEnvFile(filepath.Join(t.TempDir(), ".env")))
+	})
+
+	t.Run("should report a file it cannot parse", func(t *testing.T) {
+		path := writeEnvFile(t, envBaseURL+"='unterminated\n")
+
+		should.NotBeNil(t, loadEnvFile(path))
+	})
+}
+
+func TestApplyEnvAliases(t *testing.T) {
+	// unsetEnv unsets keys for the rest of the test and restores them
+	// afterwards
+	unsetEnv := func(t *testing.T, keys ...string) {
+		for _, key := range keys {
+			t.Setenv(key, "")
+			os.Unsetenv(key)
+		}
+	}
+
+	t.Run("should read the unprefixed names", func(t *testing.T) {
+		unsetEnv(t, envAddr, envBaseURL, envRedisURL, "PORT")
+		t.Setenv("ADDR", "127.0.0.1:9000")
+		t.Setenv("BASE_URL", "https://snip.example")
+		t.Setenv("REDIS_URL", "redis://cache:6379/1")
+
+		applyEnvAliases()
+
+		should.BeEqual(t, os.Getenv(envAddr), "127.0.0.1:9000")
+		should.BeEqual(t, os.Getenv(envBaseURL), "https://snip.example")
+		should.BeEqual(t, os.Getenv(envRedisURL), "redis://cache:6379/1")
+	})
+
+	t.Run("should listen on every interface at PORT", func(t *testing.T) {
+		unsetEnv(t, envAddr, "ADDR")
+		t.Setenv("PORT", "9000")
+
+		applyEnvAliases()
+
+		should.BeEqual(t, os.Getenv(envAddr), ":9000")
+	})
+
+	t.Run("should read the TLS files", func(t *testing.T) {
+		unsetEnv(t, envTLSCertFile, envTLSKeyFile)
+		t.Setenv("TLS_CERT_FILE", "/etc/sniplink/cert.pem")
+		t.Setenv("TLS_KEY_FILE", "/etc/sniplink/key.pem")
+
+		applyEnvAliases()
+
+		should.BeEqual(t, os.Getenv(envTLSCertFile), "/etc/sniplink/cert.pem")
+		should.BeEqual(t, os.Getenv(envTLSKeyFile), "/etc/sniplink/key.pem")
+	})
+
+	t.Run("should read the logging settings", func(t *testing.T) {
+		unsetEnv(t, envLogFormat, envLogLevel, "ENV")
+		t.Setenv("LOG_FORMAT", "console")
+		t.Setenv("LOG_LEVEL", "debug")
+
+		applyEnvAliases()
+
+		should.BeEqual(t, os.Getenv(envLogFormat), logFormatConsole)
+		should.BeEqual(t, os.Getenv(envLogLevel), "debug")
+	})
+
+	t.Run("should log to the console in development", func(t *testing.T) {
+		unsetEnv(t, envLogFormat, "LOG_FORMAT")
+		t.Setenv("ENV", "development")
+
+		applyEnvAliases()
+
+		should.BeEqual(t, os.Getenv(envLogFormat), logFormatConsole)
+	})
+
+	t.Run("should keep the default format in other environments", func(t *testing.T) {
+		unsetEnv(t, envLogFormat, "LOG_FORMAT")
+		t.Setenv("ENV", "production")
+
+		applyEnvAliases()
+
+		_, set := os.LookupEnv(envLogFormat)
+		should.BeFalse(t, set)
+	})
+
+	t.Run("should prefer the prefixed names", func(t *testing.T) {
+		t.Setenv(envBaseURL, "https://snip.example")
+		t.Setenv("BASE_URL", "https://other.example")
+
+		applyEnvAliases()
+
+		should.BeEqual(t, os.Getenv(envBaseURL), "https://snip.example")
+	})
+}
+
+func TestParseFlags(t *testing.T) {
+	parse := func(args ...string) (flagConfig, error) {
+		fs := flag.NewFlagSet("sniplink", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		return parseFlags(fs, args)
+	}
+	// clearEnv unsets the variables the flags default to
+	clearEnv := func(t *testing.T) {
+		for _, key := range []string{envAddr, envDataFile, envLogLevel} {
+			t.Setenv(key, "")
+			os.Unsetenv(key)
+		}
+	}
+
+	t.Run("should default to the built in settings", func(t *testing.T) {
+		clearEnv(t)
+
+		config, err := parse()
+
+		should.BeNil(t, err)
+		should.BeEqual(t, config, flagConfig{addr: ":8080", dataFile: defaultDataFile})
+	})
+
+	t.Run("should default to the environment", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv(envAddr, "127.0.0.1:9000")
+		t.Setenv(envDataFile, "/var/lib/sniplink/urls.json")
+		t.Setenv(envLogLevel, "warn")
+
+		config, err := parse()
+
+		should.BeNil(t, err)
+		should.BeEqual(t, config, flagConfig{addr: "127.0.0.1:9000", dataFile: "/var/lib/sniplink/urls.json", logLevel: "warn"})
+	})
+
+	t.Run("should let flags override the environment", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv(envAddr, "127.0.0.1:9000")
+		t.Setenv(envDataFile, "/var/lib/sniplink/urls.json")
+		t.Setenv(envLogLevel, "warn")
+
+		config, err := parse("--port", "9090", "--data-file", "links.json", "--log-level", "debug")
+
+		should.BeNil(t, err)
+		should.BeEqual(t, config, flagConfig{addr: "127.0.0.1:9090", dataFile: "links.json", logLevel: "debug"},
+			should.WithMessage("The host of the environment address should be kept"))
+	})
+
+	t.Run("should reject invalid values", func(t *testing.T) {
+		clearEnv(t)
+
+		for _, args := range [][]string{
+			{"--port", "http"},
+			{"--port", "70000"},
+			{"--log-level", "loud"},
+			{"--verbose"},
+			{"extra"},
+		} {
+			_, err := parse(args...)
+			should.NotBeNil(t, err, should.WithMessage(strings.Join(args, " ")+" should fail"))
+		}
+	})
+
+	t.Run("should reject an invalid environment address", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv(envAddr, "8080")
+
+		_, err := parse()
+
+		should.NotBeNil(t, err)
+	})
+}
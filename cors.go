@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	corsAllowedMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	corsAllowedHeaders = "Content-Type, Authorization, X-Request-ID"
+	// corsExposedHeaders are the response headers scripts may read
+	corsExposedHeaders = "X-Request-ID, Retry-After"
+	// corsMaxAge is how many seconds browsers may cache a preflight answer
+	corsMaxAge = "600"
+)
+
+// corsMiddleware adds CORS headers for requests coming from one of
+// allowedOrigins, "*" allows any origin. Preflight OPTIONS requests are
+// answered with 204 without reaching next and may be cached by the browser.
+// Requests from other origins get no CORS headers, so browsers block them
+func corsMiddleware(next http.HandlerFunc, allowedOrigins []string) http.HandlerFunc {
+	allowAny := slices.Contains(allowedOrigins, "*")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && (allowAny || slices.Contains(allowedOrigins, origin)) {
+			if allowAny {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if w.Header().Get("Access-Control-Allow-Origin") != "" {
+				w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// parseOrigins splits a comma separated list of allowed origins
+func parseOrigins(raw string) []string {
+	var origins []string
+	for _, origin := range strings.Split(raw, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
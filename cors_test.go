@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	nextCalled := false
+	next := func(w http.ResponseWriter, r *http.Request) {
+		nextCalled = true
+		w.WriteHeader(http.StatusOK)
+	}
+
+	t.Run("should answer preflight requests with 204", func(t *testing.T) {
+		nextCalled = false
+		handler := corsMiddleware(next, []string{"https://app.example.com"})
+
+		req := httptest.NewRequest(http.MethodOptions, "/shorten", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := httptest.NewRecorder()
+
+		handler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusNoContent)
+		should.BeFalse(t, nextCalled, should.WithMessage("Preflight should not reach the handler"))
+		should.BeEqual(t, w.Header().Get("Access-Control-Allow-Origin"), "https://app.example.com")
+		should.ContainSubstring(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodPost)
+		should.ContainSubstring(t, w.Header().Get("Access-Control-Allow-Headers"), "Content-Type")
+		should.BeEqual(t, w.Header().Get("Access-Control-Max-Age"), corsMaxAge)
+	})
+
+	t.Run("should add headers to cross-origin POST requests", func(t *testing.T) {
+		nextCalled = false
+		handler := corsMiddleware(next, []string{"https://app.example.com"})
+
+		req := httptest.NewRequest(http.MethodPost, "/shorten", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		w := httptest.NewRecorder()
+
+		handler(w, req)
+
+		should.BeTrue(t, nextCalled, should.WithMessage("Actual request should reach the handler"))
+		should.BeEqual(t, w.Header().Get("Access-Control-Allow-Origin"), "https://app.example.com")
+		should.BeEqual(t, w.Header().Get("Vary"), "Origin")
+		should.ContainSubstring(t, w.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID")
+	})
+
+	t.Run("should allow any origin with a wildcard", func(t *testing.T) {
+		handler := corsMiddleware(next, []string{"*"})
+
+		req := httptest.NewRequest(http.MethodPost, "/shorten", nil)
+		req.Header.Set("Origin", "https://anything.example.com")
+		w := httptest.NewRecorder()
+
+		handler(w, req)
+
+		should.BeEqual(t, w.Header().Get("Access-Control-Allow-Origin"), "*")
+	})
+
+	t.Run("should not add headers for disallowed origins", func(t *testing.T) {
+		handler := corsMiddleware(next, []string{"https://app.example.com"})
+
+		req := httptest.NewRequest(http.MethodPost, "/shorten", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		w := httptest.NewRecorder()
+
+		handler(w, req)
+
+		should.BeEmpty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		should.BeEmpty(t, w.Header().Get("Access-Control-Allow-Methods"))
+	})
+}
+
+func TestParseOrigins(t *testing.T) {
+	t.Run("should split and trim comma separated origins", func(t *testing.T) {
+		origins := parseOrigins(" https://a.example.com, https://b.example.com ,,")
+		should.BeEqual(t, origins, []string{"https://a.example.com", "https://b.example.com"})
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultDebugPort is the port of the pprof server unless
+	// SNIPLINK_DEBUG_PORT says otherwise, an empty value or 0 disables it
+	defaultDebugPort = "6060"
+	// debugShutdownTimeout bounds how long a running profile may delay exit
+	debugShutdownTimeout = 5 * time.Second
+)
+
+// debugAddr returns the address of the pprof server, or "" when it is
+// disabled. The server only listens on the loopback interface, profiles
+// reveal internals and can load the process
+func debugAddr() (string, error) {
+	raw, set := os.LookupEnv(envDebugPort)
+	if !set {
+		raw = defaultDebugPort
+	}
+	if raw == "" || raw == "0" {
+		return "", nil
+	}
+	port, err := strconv.Atoi(raw)
+	if err != nil || port < 1 || port > 65535 {
+		return "", fmt.Errorf("debug port %q is not a port number", raw)
+	}
+	return net.JoinHostPort("localhost", raw), nil
+}
+
+// newDebugHandler routes the net/http/pprof handlers under /debug/pprof/.
+// They are registered on their own mux, never on the public router
+func newDebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// serveDebug serves the pprof handlers on ln in the background and returns a
+// function that stops the server
+func serveDebug(ln net.Listener) (stop func()) {
+	// No write timeout, CPU profiles and traces stream for as long as the
+	// seconds parameter asks
+	srv := &http.Server{Handler: newDebugHandler(), ReadHeaderTimeout: defaultServerTimeouts.read}
+	go func() {
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Debug server failed", zap.Error(err))
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), debugShutdownTimeout)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}
+}
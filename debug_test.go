@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestDebugAddr(t *testing.T) {
+	t.Run("should default to port 6060 on localhost", func(t *testing.T) {
+		addr, err := debugAddr()
+		should.BeNil(t, err)
+		should.BeEqual(t, addr, "localhost:6060")
+	})
+
+	t.Run("should use the configured port", func(t *testing.T) {
+		t.Setenv("SNIPLINK_DEBUG_PORT", "7070")
+		addr, _ := debugAddr()
+		should.BeEqual(t, addr, "localhost:7070")
+	})
+
+	t.Run("should be disabled by an empty port or 0", func(t *testing.T) {
+		for _, raw := range []string{"", "0"} {
+			t.Setenv("SNIPLINK_DEBUG_PORT", raw)
+			addr, err := debugAddr()
+			should.BeNil(t, err)
+			should.BeEqual(t, addr, "", should.WithMessage("Port "+raw+" should disable the server"))
+		}
+	})
+
+	t.Run("should reject invalid ports", func(t *testing.T) {
+		for _, raw := range []string{"http", "-1", "70000"} {
+			t.Setenv("SNIPLINK_DEBUG_PORT", raw)
+			_, err := debugAddr()
+			should.NotBeNil(t, err, should.WithMessage("Port "+raw+" should be rejected"))
+		}
+	})
+}
+
+func TestDebugServer(t *testing.T) {
+	t.Run("should serve pprof on its own listener", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		should.BeNil(t, err)
+		stop := serveDebug(ln)
+		defer stop()
+
+		resp, err := http.Get("http://" + ln.Addr().String() + "/debug/pprof/")
+		should.BeNil(t, err)
+		resp.Body.Close()
+		should.BeEqual(t, resp.StatusCode, http.StatusOK)
+
+		resp, err = http.Get("http://" + ln.Addr().String() + "/debug/pprof/cmdline")
+		should.BeNil(t, err)
+		resp.Body.Close()
+		should.BeEqual(t, resp.StatusCode, http.StatusOK)
+	})
+
+	t.Run("should not expose pprof on the main router", func(t *testing.T) {
+		resetStore()
+		for _, target := range []string{"/debug/pprof", "/debug/pprof/", "/debug/pprof/cmdline"} {
+			w := httptest.NewRecorder()
+			route(w, httptest.NewRequest(http.MethodGet, target, nil))
+			should.BeEqual(t, w.Code, http.StatusNotFound, should.WithMessage(target+" should not be routed"))
+		}
+	})
+}
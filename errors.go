@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// Machine readable error codes returned in the code field of error responses
+const (
+	errCodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	errCodeInvalidBody      = "INVALID_BODY"
+	errCodeBodyTooLarge     = "BODY_TOO_LARGE"
+	errCodeMediaType        = "UNSUPPORTED_MEDIA_TYPE"
+	errCodeInvalidURL       = "INVALID_URL"
+	errCodeBlockedURL       = "BLOCKED_URL"
+	errCodeInvalidShortCode = "INVALID_SHORT_CODE"
+	errCodeInvalidNamespace = "INVALID_NAMESPACE"
+	errCodeInvalidExpiry    = "INVALID_EXPIRY"
+	errCodeInvalidQuery     = "INVALID_QUERY"
+	errCodeUnauthorized     = "UNAUTHORIZED"
+	errCodeConflict         = "CONFLICT"
+	errCodeNotFound         = "NOT_FOUND"
+	errCodeGone             = "GONE"
+	errCodeRateLimited      = "RATE_LIMITED"
+	errCodeInternal         = "INTERNAL_ERROR"
+)
+
+// errorBody is the JSON envelope of every error response
+type errorBody struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// errorResponse writes a JSON error envelope with the given status code
+func errorResponse(w http.ResponseWriter, message, code string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorBody{Error: message, Code: code})
+}
+
+// storeFailure logs an error returned by the store and answers 500
+func storeFailure(w http.ResponseWriter, r *http.Request, err error) {
+	requestLogger(r).Error("Store operation failed", zap.Error(err))
+	errStoreUnavailable.write(w)
+}
+
+// errStoreUnavailable is the response to a failing store, the cause is only logged
+var errStoreUnavailable = &apiError{"Storage is unavailable", errCodeInternal, http.StatusInternalServerError}
+
+// apiError is a failure that maps to an error response
+type apiError struct {
+	message string
+	code    string
+	status  int
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// write sends the error as a JSON error envelope
+func (e *apiError) write(w http.ResponseWriter) {
+	errorResponse(w, e.message, e.code, e.status)
+}
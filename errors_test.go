@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+)
+
+// decodeError decodes the JSON error envelope written to w
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) errorBody {
+	t.Helper()
+	should.BeEqual(t, w.Header().Get("Content-Type"), "application/json", should.WithMessage("Errors should be JSON"))
+
+	var body errorBody
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	should.BeNil(t, err, should.WithMessage("Error response should be valid JSON"))
+	return body
+}
+
+func TestErrorResponses(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		method      string
+		target      string
+		body        string
+		setup       func()
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{
+			name: "shorten with wrong method", handler: route, method: http.MethodGet, target: "/shorten",
+			wantStatus: http.StatusMethodNotAllowed, wantCode: errCodeMethodNotAllowed, wantMessage: "Method not allowed",
+		},
+		{
+			name: "shorten with invalid JSON", handler: shortenHandler, method: http.MethodPost, target: "/shorten", body: "invalid json",
+			wantStatus: http.StatusBadRequest, wantCode: errCodeInvalidBody, wantMessage: "Invalid request body",
+		},
+		{
+			name: "shorten with invalid URL", handler: shortenHandler, method: http.MethodPost, target: "/shorten", body: `{"original":"ftp://example.com"}`,
+			wantStatus: http.StatusBadRequest, wantCode: errCodeInvalidURL, wantMessage: errURLInvalidScheme.Error(),
+		},
+		{
+			name: "shorten own short URL", handler: shortenHandler, method: http.MethodPost, target: "/shorten", body: `{"original":"http://localhost:8080/abc123"}`,
+			wantStatus: http.StatusBadRequest, wantCode: errCodeInvalidURL, wantMessage: "cannot shorten a URL pointing to this server",
+		},
+		{
+			name: "shorten with invalid expiry", handler: shortenHandler, method: http.MethodPost, target: "/shorten", body: `{"original":"https://example.com","expires_in":-1}`,
+			wantStatus: http.StatusBadRequest, wantCode: errCodeInvalidExpiry, wantMessage: errExpiresInNotPositive.Error(),
+		},
+		{
+			name: "shorten permanent with expiry", handler: shortenHandler, method: http.MethodPost, target: "/shorten", body: `{"original":"https://example.com","permanent":true,"expires_in":60}`,
+			wantStatus: http.StatusBadRequest, wantCode: errCodeInvalidExpiry, wantMessage: errPermanentExpiry.Error(),
+		},
+		{
+			name: "shorten with invalid alias", handler: shortenHandler, method: http.MethodPost, target: "/shorten", body: `{"original":"https://example.com","short_code":"a b"}`,
+			wantStatus: http.StatusBadRequest, wantCode: errCodeInvalidShortCode, wantMessage: errAliasInvalidChars.Error(),
+		},
+		{
+			name: "shorten with taken alias", handler: shortenHandler, method: http.MethodPost, target: "/shorten", body: `{"original":"https://example.com","short_code":"taken"}`,
+			setup:      func() { store.Set("taken", URLRecord{Original: "https://example.com"}) },
+			wantStatus: http.StatusConflict, wantCode: errCodeConflict, wantMessage: "short code already in use",
+		},
+		{
+			name: "shorten without a free code", handler: shortenHandler, method: http.MethodPost, target: "/shorten", body: `{"original":"https://example.com"}`,
+			setup: func() {
+				store.Set("taken", URLRecord{Original: "https://example.com"})
+				newShortCode = func(int) (string, error) { return "taken", nil }
+			},
+			wantStatus: http.StatusInternalServerError, wantCode: errCodeInternal, wantMessage: "Could not generate a unique short code",
+		},
+		{
+			name: "redirect to unknown code", handler: route, method: http.MethodGet, target: "/missing",
+			wantStatus: http.StatusNotFound, wantCode: errCodeNotFound, wantMessage: "Short code not found",
+		},
+		{
+			name: "redirect to expired link", handler: route, method: http.MethodGet, target: "/old",
+			setup: func() {
+				store.Set("old", URLRecord{Original: "https://example.com", ExpiresAt: time.Now().Add(-time.Second)})
+			},
+			wantStatus: http.StatusGone, wantCode: errCodeGone, wantMessage: "Short link has expired",
+		},
+		{
+			name: "delete unknown code", handler: route, method: http.MethodDelete, target: "/shorten/missing",
+			wantStatus: http.StatusNotFound, wantCode: errCodeNotFound, wantMessage: "Short code not found",
+		},
+		{
+			name: "short code with wrong method", handler: route, method: http.MethodPatch, target: "/shorten/abc123",
+			wantStatus: http.StatusMethodNotAllowed, wantCode: errCodeMethodNotAllowed, wantMessage: "Method not allowed",
+		},
+		{
+			name: "links with wrong method", handler: route, method: http.MethodPost, target: "/links",
+			wantStatus: http.StatusMethodNotAllowed, wantCode: errCodeMethodNotAllowed, wantMessage: "Method not allowed",
+		},
+		{
+			name: "stats for unknown code", handler: route, method: http.MethodGet, target: "/stats/missing",
+			wantStatus: http.StatusNotFound, wantCode: errCodeNotFound, wantMessage: "Short code not found",
+		},
+		{
+			name: "stats with wrong method", handler: route, method: http.MethodPost, target: "/stats/abc123",
+			wantStatus: http.StatusMethodNotAllowed, wantCode: errCodeMethodNotAllowed, wantMessage: "Method not allowed",
+		},
+		{
+			name: "batch shorten with invalid JSON", handler: batchShortenHandler, method: http.MethodPost, target: "/shorten/batch", body: "invalid json",
+			wantStatus: http.StatusBadRequest, wantCode: errCodeInvalidBody, wantMessage: "Invalid request body",
+		},
+		{
+			name: "update unknown code", handler: route, method: http.MethodPut, target: "/shorten/missing", body: `{"original":"https://example.com"}`,
+			wantStatus: http.StatusNotFound, wantCode: errCodeNotFound, wantMessage: "Short code not found",
+		},
+		{
+			name: "update with invalid URL", handler: route, method: http.MethodPut, target: "/shorten/abc123", body: `{"original":""}`,
+			wantStatus: http.StatusBadRequest, wantCode: errCodeInvalidURL, wantMessage: errURLEmpty.Error(),
+		},
+		{
+			name: "preview unknown code", handler: route, method: http.MethodGet, target: "/preview/missing",
+			wantStatus: http.StatusNotFound, wantCode: errCodeNotFound, wantMessage: "Short code not found",
+		},
+		{
+			name: "QR code with invalid size", handler: route, method: http.MethodGet, target: "/abc123/qr?size=1",
+			setup:      func() { store.Set("abc123", URLRecord{Original: "https://example.com"}) },
+			wantStatus: http.StatusBadRequest, wantCode: errCodeInvalidQuery, wantMessage: errQRSize.Error(),
+		},
+		{
+			name: "health with wrong method", handler: route, method: http.MethodPost, target: "/healthz",
+			wantStatus: http.StatusMethodNotAllowed, wantCode: errCodeMethodNotAllowed, wantMessage: "Method not allowed",
+		},
+		{
+			name: "rate limited request", handler: rateLimitMiddleware(healthHandler, 1, 0), method: http.MethodGet, target: "/healthz",
+			wantStatus: http.StatusTooManyRequests, wantCode: errCodeRateLimited, wantMessage: "Rate limit exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetStore()
+			defer func() { newShortCode = generateShortCode }()
+			if tt.setup != nil {
+				tt.setup()
+			}
+
+			req := jsonRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			tt.handler(w, req)
+
+			should.BeEqual(t, w.Code, tt.wantStatus)
+			body := decodeError(t, w)
+			should.BeEqual(t, body.Error, tt.wantMessage)
+			should.BeEqual(t, body.Code, tt.wantCode)
+		})
+	}
+}
+
+func TestStoreFailureResponses(t *testing.T) {
+	saved := store
+	store = failingStore{}
+	defer func() { store = saved }()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		body    string
+	}{
+		{name: "shorten", handler: shortenHandler, method: http.MethodPost, target: "/shorten", body: `{"original":"https://example.com"}`},
+		{name: "shorten with alias", handler: shortenHandler, method: http.MethodPost, target: "/shorten", body: `{"original":"https://example.com","short_code":"alias"}`},
+		{name: "batch", handler: batchShortenHandler, method: http.MethodPost, target: "/shorten/batch", body: `[{"original":"https://example.com"}]`},
+		{name: "update", handler: route, method: http.MethodPut, target: "/shorten/abc123", body: `{"original":"https://example.com"}`},
+		{name: "delete", handler: route, method: http.MethodDelete, target: "/shorten/abc123"},
+		{name: "redirect", handler: route, method: http.MethodGet, target: "/abc123"},
+		{name: "links", handler: listHandler, method: http.MethodGet, target: "/links"},
+		{name: "stats", handler: route, method: http.MethodGet, target: "/stats/abc123"},
+		{name: "preview", handler: route, method: http.MethodGet, target: "/preview/abc123"},
+		{name: "QR code", handler: route, method: http.MethodGet, target: "/abc123/qr"},
+	}
+
+	for _, tt := range tests {
+		t.Run("should answer 500 from "+tt.name, func(t *testing.T) {
+			req := jsonRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			tt.handler(w, req)
+
+			should.BeEqual(t, w.Code, http.StatusInternalServerError)
+			body := decodeError(t, w)
+			should.BeEqual(t, body.Code, errCodeInternal)
+			should.BeEqual(t, body.Error, "Storage is unavailable", should.WithMessage("The cause should not leak to clients"))
+		})
+	}
+}
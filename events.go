@@ -0,0 +1,128 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxClickEvents caps the click events kept for each short code
+const maxClickEvents = 1000
+
+// maxTotalClickEvents caps the click events kept for all short codes
+// together
+const maxTotalClickEvents = 100_000
+
+// ClickEvent is one followed redirect of a short link
+type ClickEvent struct {
+	Time     time.Time `json:"time"`
+	Referrer string    `json:"referrer,omitempty"`
+	// Bot is set for clicks whose User-Agent matches botSignatures
+	Bot bool `json:"bot,omitempty"`
+}
+
+// clickLog keeps the latest click events of every short code in memory,
+// older events are dropped once a code has limit of them. Past totalLimit
+// events in all, the whole log of the least recently clicked code is
+// dropped
+type clickLog struct {
+	mu         sync.Mutex
+	limit      int
+	totalLimit int
+	total      int
+	events     map[string][]ClickEvent
+	// recency orders the short codes with events from most to least
+	// recently clicked
+	recency *list.List
+	entries map[string]*list.Element
+}
+
+// clickEvents is the click log filled by redirectHandler
+var clickEvents = newClickLog(maxClickEvents, maxTotalClickEvents)
+
+// newClickLog creates an empty clickLog keeping limit events per code and
+// totalLimit events in all
+func newClickLog(limit, totalLimit int) *clickLog {
+	return &clickLog{
+		limit:      limit,
+		totalLimit: totalLimit,
+		events:     make(map[string][]ClickEvent),
+		recency:    list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// record appends event to the log of shortCode
+func (l *clickLog) record(shortCode string, event ClickEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := l.events[shortCode]
+	if len(events) >= l.limit {
+		l.total -= len(events) - l.limit + 1
+		events = events[:copy(events, events[len(events)-l.limit+1:])]
+	}
+	l.events[shortCode] = append(events, event)
+	l.total++
+	if e, exists := l.entries[shortCode]; exists {
+		l.recency.MoveToFront(e)
+	} else {
+		l.entries[shortCode] = l.recency.PushFront(shortCode)
+	}
+	for l.total > l.totalLimit {
+		l.drop(l.recency.Back().Value.(string))
+	}
+}
+
+// latest returns a copy of the events of shortCode, newest first
+func (l *clickLog) latest(shortCode string) []ClickEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := slices.Clone(l.events[shortCode])
+	slices.Reverse(events)
+	return events
+}
+
+// drop removes the events of shortCode, the caller must hold mu
+func (l *clickLog) drop(shortCode string) {
+	l.total -= len(l.events[shortCode])
+	delete(l.events, shortCode)
+	if e, exists := l.entries[shortCode]; exists {
+		l.recency.Remove(e)
+		delete(l.entries, shortCode)
+	}
+}
+
+// forget drops the events of shortCode
+func (l *clickLog) forget(shortCode string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.drop(shortCode)
+}
+
+// clickEventsHandler lists the latest clicks of the short link named by
+// /stats/{code}/events, newest first
+func clickEventsHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := shortCodeParam(r)
+	_, exists, err := store.Get(shortCode)
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+	if !exists {
+		errorResponse(w, "Short code not found", errCodeNotFound, http.StatusNotFound)
+		return
+	}
+
+	events := clickEvents.latest(shortCode)
+	if events == nil {
+		events = []ClickEvent{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
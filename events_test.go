@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestClickLog(t *testing.T) {
+	t.Run("should return events newest first", func(t *testing.T) {
+		l := newClickLog(10, maxTotalClickEvents)
+		start := time.Now()
+		for i := range 3 {
+			l.record("abc123", ClickEvent{Time: start.Add(time.Duration(i) * time.Second), Referrer: fmt.Sprint(i)})
+		}
+
+		events := l.latest("abc123")
+		should.BeEqual(t, len(events), 3)
+		should.BeEqual(t, events[0].Referrer, "2")
+		should.BeEqual(t, events[2].Referrer, "0")
+	})
+
+	t.Run("should keep only the latest events", func(t *testing.T) {
+		l := newClickLog(3, maxTotalClickEvents)
+		for i := range 5 {
+			l.record("abc123", ClickEvent{Referrer: fmt.Sprint(i)})
+		}
+
+		events := l.latest("abc123")
+		should.BeEqual(t, len(events), 3)
+		should.BeEqual(t, events[0].Referrer, "4")
+		should.BeEqual(t, events[2].Referrer, "2", should.WithMessage("The oldest events should be dropped"))
+	})
+
+	t.Run("should forget the events of a code", func(t *testing.T) {
+		l := newClickLog(3, maxTotalClickEvents)
+		l.record("abc123", ClickEvent{})
+		l.record("def456", ClickEvent{})
+		l.forget("abc123")
+
+		should.BeEmpty(t, l.latest("abc123"))
+		should.BeEqual(t, len(l.latest("def456")), 1)
+	})
+
+	t.Run("should drop the least recently clicked code past the total limit", func(t *testing.T) {
+		l := newClickLog(3, 4)
+		l.record("abc123", ClickEvent{})
+		l.record("abc123", ClickEvent{})
+		l.record("def456", ClickEvent{})
+		l.record("abc123", ClickEvent{})
+		l.record("ghi789", ClickEvent{})
+
+		should.BeEmpty(t, l.latest("def456"), should.WithMessage("The least recently clicked code should be dropped"))
+		should.BeEqual(t, len(l.latest("abc123")), 3)
+		should.BeEqual(t, len(l.latest("ghi789")), 1)
+	})
+
+	t.Run("should not count events dropped by the per code limit", func(t *testing.T) {
+		l := newClickLog(2, 3)
+		for range 5 {
+			l.record("abc123", ClickEvent{})
+		}
+		l.record("def456", ClickEvent{})
+
+		should.BeEqual(t, len(l.latest("abc123")), 2)
+		should.BeEqual(t, len(l.latest("def456")), 1)
+	})
+}
+
+func TestClickEventsHandler(t *testing.T) {
+	getEvents := func(shortCode string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/stats/"+shortCode+"/events", nil)
+		w := httptest.NewRecorder()
+		route(w, req)
+		return w
+	}
+	follow := func(path, referrer string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if referrer != "" {
+			req.Header.Set("Referer", referrer)
+		}
+		route(httptest.NewRecorder(), req)
+	}
+
+	t.Run("should list redirects with their referrer newest first", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		follow("/abc123", "https://news.example.org/")
+		follow("/abc123", "")
+		follow("/abc123", "https://blog.example.net/post")
+
+		w := getEvents("abc123")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json")
+
+		var events []ClickEvent
+		err := json.Unmarshal(w.Body.Bytes(), &events)
+		should.BeNil(t, err, should.WithMessage("Response should be valid JSON"))
+		should.BeEqual(t, len(events), 3)
+		should.BeEqual(t, events[0].Referrer, "https://blog.example.net/post")
+		should.BeEqual(t, events[1].Referrer, "")
+		should.BeEqual(t, events[2].Referrer, "https://news.example.org/")
+		should.BeFalse(t, events[0].Time.Before(events[2].Time), should.WithMessage("Events should be newest first"))
+	})
+
+	t.Run("should return an empty array before any redirect", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := getEvents("abc123")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Body.String(), "[]\n")
+	})
+
+	t.Run("should not record misses or expired links", func(t *testing.T) {
+		resetStore()
+		store.Set("old", URLRecord{Original: "https://example.com", ExpiresAt: time.Now().Add(-time.Minute)})
+		follow("/old", "https://example.org/")
+		follow("/missing", "https://example.org/")
+
+		var events []ClickEvent
+		json.Unmarshal(getEvents("old").Body.Bytes(), &events)
+		should.BeEmpty(t, events)
+	})
+
+	t.Run("should serve namespaced links", func(t *testing.T) {
+		resetStore()
+		store.Set("team:abc123", URLRecord{Original: "https://example.com"})
+		follow("/team/abc123", "https://example.org/")
+
+		var events []ClickEvent
+		json.Unmarshal(getEvents("team/abc123").Body.Bytes(), &events)
+		should.BeEqual(t, len(events), 1)
+	})
+
+	t.Run("should forget the events of deleted links", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		follow("/abc123", "https://example.org/")
+		route(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/abc123", nil))
+		store.Set("abc123", URLRecord{Original: "https://example.net"})
+
+		var events []ClickEvent
+		json.Unmarshal(getEvents("abc123").Body.Bytes(), &events)
+		should.BeEmpty(t, events, should.WithMessage("A reused code should start without events"))
+	})
+
+	t.Run("should return 404 for unknown codes", func(t *testing.T) {
+		resetStore()
+
+		w := getEvents("missing")
+
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeNotFound)
+	})
+}
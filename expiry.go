@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// expirySweepInterval is how often expired links are removed from the store
+	expirySweepInterval = time.Minute
+	// maxExpiry is the furthest ahead a link may expire. Later times overflow
+	// the nanoseconds the stores keep expiries in
+	maxExpiry = 100 * 365 * 24 * time.Hour
+)
+
+var (
+	errExpiryConflict       = errors.New("only one of expires_in, ttl_seconds and expires_at may be set")
+	errExpiresInNotPositive = errors.New("expires_in and ttl_seconds must be a positive number of seconds")
+	errExpiresAtInPast      = errors.New("expires_at must be in the future")
+	errExpiryTooFar         = errors.New("links may expire at most 100 years ahead")
+	errPermanentExpiry      = errors.New("permanent links cannot expire")
+)
+
+// linkExpiry returns when a link requested by urlPair expires, relative to
+// now for expires_in and ttl_seconds. The zero time means the link never
+// expires, which permanent links must not since browsers cache their 301
+func linkExpiry(urlPair URLPair, now time.Time) (time.Time, error) {
+	if urlPair.Permanent && (urlPair.ExpiresIn != 0 || urlPair.TTLSeconds != 0 || !urlPair.ExpiresAt.IsZero()) {
+		return time.Time{}, errPermanentExpiry
+	}
+
+	expiresIn := urlPair.ExpiresIn
+	if urlPair.TTLSeconds != 0 {
+		if expiresIn != 0 {
+			return time.Time{}, errExpiryConflict
+		}
+		expiresIn = urlPair.TTLSeconds
+	}
+
+	switch {
+	case expiresIn != 0 && !urlPair.ExpiresAt.IsZero():
+		return time.Time{}, errExpiryConflict
+	case expiresIn < 0:
+		return time.Time{}, errExpiresInNotPositive
+	case expiresIn > int64(maxExpiry/time.Second):
+		return time.Time{}, errExpiryTooFar
+	case expiresIn > 0:
+		return now.Add(time.Duration(expiresIn) * time.Second), nil
+	case !urlPair.ExpiresAt.IsZero() && !urlPair.ExpiresAt.After(now):
+		return time.Time{}, errExpiresAtInPast
+	case urlPair.ExpiresAt.After(now.Add(maxExpiry)):
+		return time.Time{}, errExpiryTooFar
+	}
+	return urlPair.ExpiresAt, nil
+}
+
+// sweepExpired removes expired links from the store along with their click
+// events and webhooks, persisting the store when anything was removed
+func sweepExpired(now time.Time) {
+	removed, err := store.DeleteExpired(now)
+	for _, shortCode := range removed {
+		clickEvents.forget(shortCode)
+		webhooks.forget(shortCode)
+	}
+	if err != nil {
+		logger.Error("Failed to remove expired links", zap.Error(err))
+		return
+	}
+	if len(removed) == 0 {
+		return
+	}
+	logger.Info("Removed expired links", zap.Int("count", len(removed)))
+	persist()
+}
+
+// startExpirySweeper runs sweepExpired every interval in the background
+// until the returned stop function is called
+func startExpirySweeper(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				sweepExpired(now)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
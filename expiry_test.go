@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestLinkExpiry(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		urlPair URLPair
+		want    time.Time
+		wantErr error
+	}{
+		{name: "no expiry", urlPair: URLPair{}, want: time.Time{}},
+		{name: "expires in", urlPair: URLPair{ExpiresIn: 3600}, want: now.Add(time.Hour)},
+		{name: "ttl seconds", urlPair: URLPair{TTLSeconds: 3600}, want: now.Add(time.Hour)},
+		{name: "negative ttl seconds", urlPair: URLPair{TTLSeconds: -1}, wantErr: errExpiresInNotPositive},
+		{name: "ttl seconds and expires in", urlPair: URLPair{TTLSeconds: 60, ExpiresIn: 60}, wantErr: errExpiryConflict},
+		{name: "ttl seconds and expires at", urlPair: URLPair{TTLSeconds: 60, ExpiresAt: now.Add(time.Hour)}, wantErr: errExpiryConflict},
+		{name: "expires at", urlPair: URLPair{ExpiresAt: now.Add(24 * time.Hour)}, want: now.Add(24 * time.Hour)},
+		{name: "negative expires in", urlPair: URLPair{ExpiresIn: -1}, wantErr: errExpiresInNotPositive},
+		{name: "expires at in the past", urlPair: URLPair{ExpiresAt: now.Add(-time.Minute)}, wantErr: errExpiresAtInPast},
+		{name: "both set", urlPair: URLPair{ExpiresIn: 60, ExpiresAt: now.Add(time.Hour)}, wantErr: errExpiryConflict},
+		{name: "expires in at the maximum", urlPair: URLPair{ExpiresIn: int64(maxExpiry / time.Second)}, want: now.Add(maxExpiry)},
+		{name: "expires in past the maximum", urlPair: URLPair{ExpiresIn: int64(maxExpiry/time.Second) + 1}, wantErr: errExpiryTooFar},
+		{name: "expires in overflowing a duration", urlPair: URLPair{ExpiresIn: math.MaxInt64}, wantErr: errExpiryTooFar},
+		{name: "expires at past the maximum", urlPair: URLPair{ExpiresAt: time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)}, wantErr: errExpiryTooFar},
+		{name: "permanent", urlPair: URLPair{Permanent: true}, want: time.Time{}},
+		{name: "permanent with expires in", urlPair: URLPair{Permanent: true, ExpiresIn: 60}, wantErr: errPermanentExpiry},
+		{name: "permanent with ttl seconds", urlPair: URLPair{Permanent: true, TTLSeconds: 60}, wantErr: errPermanentExpiry},
+		{name: "permanent with expires at", urlPair: URLPair{Permanent: true, ExpiresAt: now.Add(time.Hour)}, wantErr: errPermanentExpiry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := linkExpiry(tt.urlPair, now)
+			should.BeEqual(t, err, tt.wantErr)
+			should.BeEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestSweepExpired(t *testing.T) {
+	t.Run("should remove only expired links", func(t *testing.T) {
+		resetStore()
+		now := time.Now()
+		store.Set("expired", URLRecord{Original: "https://example.com/old", ExpiresAt: now.Add(-time.Second)})
+		store.Set("active", URLRecord{Original: "https://example.com/new", ExpiresAt: now.Add(time.Hour)})
+		store.Set("forever", URLRecord{Original: "https://example.com"})
+
+		sweepExpired(now)
+
+		_, expiredKept, _ := store.Get("expired")
+		_, activeKept, _ := store.Get("active")
+		_, foreverKept, _ := store.Get("forever")
+		should.BeFalse(t, expiredKept, should.WithMessage("Expired link should be swept"))
+		should.BeTrue(t, activeKept, should.WithMessage("Unexpired link should be kept"))
+		should.BeTrue(t, foreverKept, should.WithMessage("Link without expiry should be kept"))
+	})
+
+	t.Run("should forget the click events of swept links", func(t *testing.T) {
+		resetStore()
+		now := time.Now()
+		store.Set("expired", URLRecord{Original: "https://example.com", ExpiresAt: now.Add(-time.Second)})
+		clickEvents.record("expired", ClickEvent{Time: now.Add(-time.Minute)})
+
+		sweepExpired(now)
+
+		should.BeEmpty(t, clickEvents.latest("expired"))
+	})
+
+	t.Run("should forget the webhooks of swept links", func(t *testing.T) {
+		resetStore()
+		now := time.Now()
+		store.Set("expired", URLRecord{Original: "https://example.com", ExpiresAt: now.Add(-time.Second)})
+		webhooks.register("expired", "https://hooks.example.com/clicks")
+		defer webhooks.forget("expired")
+
+		sweepExpired(now)
+
+		should.BeEmpty(t, webhooks.list("expired"))
+	})
+}
+
+func TestStartExpirySweeper(t *testing.T) {
+	t.Run("should sweep expired links in the background", func(t *testing.T) {
+		resetStore()
+		store.Set("expired", URLRecord{Original: "https://example.com", ExpiresAt: time.Now().Add(-time.Second)})
+
+		stop := startExpirySweeper(10 * time.Millisecond)
+		defer stop()
+
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if _, exists, _ := store.Get("expired"); !exists {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatal("expired link was not swept")
+	})
+}
+
+func TestLinkExpiration(t *testing.T) {
+	t.Run("should return the expiry of links created with expires_in", func(t *testing.T) {
+		resetStore()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com", ExpiresIn: 60})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusCreated)
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		expiresAt, err := time.Parse(time.RFC3339, response["expires_at"])
+		should.BeNil(t, err, should.WithMessage("expires_at should be an RFC3339 timestamp"))
+		should.BeInRange(t, time.Until(expiresAt), 58*time.Second, 61*time.Second)
+	})
+
+	t.Run("should expire links created with ttl_seconds", func(t *testing.T) {
+		resetStore()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com", TTLSeconds: 3600})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		record, _, _ := store.Get(response["short_code"])
+		should.BeInRange(t, time.Until(record.ExpiresAt), 59*time.Minute, time.Hour)
+	})
+
+	t.Run("should keep links without a TTL forever", func(t *testing.T) {
+		resetStore()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com"})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		should.NotContainKey(t, response, "expires_at")
+		record, _, _ := store.Get(response["short_code"])
+		should.BeTrue(t, record.ExpiresAt.IsZero(), should.WithMessage("Links without a TTL should never expire"))
+	})
+
+	t.Run("should reject a negative expires_in", func(t *testing.T) {
+		resetStore()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com", ExpiresIn: -5})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+		should.BeEmpty(t, storedLinks(t))
+	})
+
+	t.Run("should return gone for expired links", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com", ExpiresAt: time.Now().Add(-time.Second)})
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		w := httptest.NewRecorder()
+
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusGone, should.WithMessage("Should return 410 for an expired link"))
+		should.BeEqual(t, decodeError(t, w).Code, errCodeGone)
+		should.BeEmpty(t, w.Header().Get("Location"))
+		_, clicks, _, _ := store.Stats("abc123")
+		should.BeEqual(t, clicks.Total, uint64(0), should.WithMessage("Expired links should not count clicks"))
+	})
+
+	t.Run("should redirect links that have not expired yet", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com", ExpiresAt: time.Now().Add(time.Hour)})
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		w := httptest.NewRecorder()
+
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusTemporaryRedirect)
+	})
+}
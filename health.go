@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// healthPingTimeout bounds the ping of a networked store, probes give up
+// after a few seconds
+const healthPingTimeout = time.Second
+
+// startTime is when the server started, set in main
+var startTime time.Time
+
+// pinger is implemented by the stores behind a connection that can drop,
+// healthHandler pings them
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// healthResponse is the body returned by healthHandler
+type healthResponse struct {
+	Status        string  `json:"status"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+}
+
+// healthHandler reports whether the service is ready to serve links, for
+// liveness and readiness probes. It never reads the stored links, so a busy
+// store cannot make probes time out, but it pings a store behind a
+// connection and answers 503 when that fails
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	response := healthResponse{
+		Status:        "ok",
+		UptimeSeconds: time.Since(startTime).Seconds(),
+	}
+	status := http.StatusOK
+	if !storeReachable(r.Context()) {
+		response.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// storeReachable reports whether the store is set and, when it is a pinger,
+// answers a ping within healthPingTimeout
+func storeReachable(ctx context.Context) bool {
+	if store == nil {
+		return false
+	}
+	p, ok := store.(pinger)
+	if !ok {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+	defer cancel()
+	return p.Ping(ctx) == nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestHealthHandler(t *testing.T) {
+	t.Run("should report ok with uptime", func(t *testing.T) {
+		startTime = time.Now().Add(-5 * time.Second)
+
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		w := httptest.NewRecorder()
+
+		healthHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json")
+
+		var response map[string]any
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeNil(t, err, should.WithMessage("Response should be valid JSON"))
+		should.BeEqual(t, response["status"], "ok")
+		uptime, isNumber := response["uptime_seconds"].(float64)
+		should.BeTrue(t, isNumber, should.WithMessage("uptime_seconds should be a number"))
+		should.BeGreaterOrEqualTo(t, uptime, 5.0, should.WithMessage("Uptime should be measured from startTime"))
+	})
+
+	t.Run("should answer HEAD probes", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodHead, "/healthz", nil)
+		w := httptest.NewRecorder()
+
+		healthHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+	})
+
+	t.Run("should not wait for the store lock", func(t *testing.T) {
+		resetStore()
+		locked := store.(*urlStore)
+		locked.mu.Lock()
+		defer locked.mu.Unlock()
+
+		done := make(chan int, 1)
+		go func() {
+			w := httptest.NewRecorder()
+			healthHandler(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			done <- w.Code
+		}()
+
+		select {
+		case code := <-done:
+			should.BeEqual(t, code, http.StatusOK)
+		case <-time.After(time.Second):
+			t.Fatal("healthHandler blocked on the store lock")
+		}
+	})
+
+	t.Run("should report unavailable when the store does not answer", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		redisStore, err := newRedisStore("redis://" + server.Addr())
+		should.BeNil(t, err)
+		defer redisStore.Close()
+		saved := store
+		store = redisStore
+		defer func() { store = saved }()
+
+		w := httptest.NewRecorder()
+		healthHandler(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		should.BeEqual(t, w.Code, http.StatusOK)
+
+		server.Close()
+		w = httptest.NewRecorder()
+		healthHandler(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		should.BeEqual(t, w.Code, http.StatusServiceUnavailable)
+	})
+
+	t.Run("should report unavailable without a store", func(t *testing.T) {
+		startTime = time.Now()
+		saved := store
+		store = nil
+		defer func() { store = saved }()
+
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		w := httptest.NewRecorder()
+
+		healthHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusServiceUnavailable)
+		var response healthResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeEqual(t, response.Status, "unavailable")
+		should.BeGreaterOrEqualTo(t, response.UptimeSeconds, 0.0)
+	})
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"math/big"
+)
+
+// hmacCodeLength is the length of the codes generateHMACCode derives
+const hmacCodeLength = 8
+
+// hmacSecret keys the short codes derived by generateHMACCode, configured
+// through SNIPLINK_HMAC_SECRET. Empty leaves generation to newShortCode
+var hmacSecret string
+
+// generateHMACCode derives a short code from original: the first
+// hmacCodeLength digits of HMAC-SHA256(secret, original) written with
+// codeAlphabet, base62 by default. The same URL always gets the same code,
+// and without the secret the codes in use cannot be enumerated
+func generateHMACCode(secret, original string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(original))
+	n := new(big.Int).SetBytes(mac.Sum(nil))
+
+	base := big.NewInt(int64(len(codeAlphabet)))
+	digit := new(big.Int)
+	code := make([]byte, hmacCodeLength)
+	for i := range code {
+		n.DivMod(n, base, digit)
+		code[i] = codeAlphabet[digit.Int64()]
+	}
+	return string(code)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestGenerateHMACCode(t *testing.T) {
+	t.Run("should derive the same code from the same input", func(t *testing.T) {
+		code := generateHMACCode("secret", "https://example.com")
+
+		should.BeEqual(t, len(code), hmacCodeLength)
+		should.BeEqual(t, generateHMACCode("secret", "https://example.com"), code)
+	})
+
+	t.Run("should derive different codes from different inputs", func(t *testing.T) {
+		seen := make(map[string]bool)
+		for _, input := range [][2]string{
+			{"secret", "https://example.com"},
+			{"secret", "https://example.org"},
+			{"secret", "https://example.com/"},
+			{"other", "https://example.com"},
+		} {
+			code := generateHMACCode(input[0], input[1])
+			should.BeFalse(t, seen[code], should.WithMessage("Code of "+input[0]+" "+input[1]+" should be new"))
+			seen[code] = true
+		}
+	})
+
+	t.Run("should only use characters of the code alphabet", func(t *testing.T) {
+		codeAlphabet = unambiguousAlphabet
+		defer func() { codeAlphabet = defaultAlphabet }()
+
+		for _, original := range []string{"https://a.example", "https://b.example", "https://c.example"} {
+			for _, c := range generateHMACCode("secret", original) {
+				should.BeTrue(t, strings.ContainsRune(unambiguousAlphabet, c), should.WithMessage("Unexpected "+string(c)))
+			}
+		}
+	})
+}
+
+func TestShortenHandlerHMAC(t *testing.T) {
+	hmacSecret = "secret"
+	defer func() { hmacSecret = "" }()
+
+	shorten := func(urlPair URLPair) string {
+		jsonData, _ := json.Marshal(urlPair)
+		w := httptest.NewRecorder()
+		shortenHandler(w, httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData)))
+		should.BeEqual(t, w.Code, http.StatusCreated)
+
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response["short_code"]
+	}
+
+	t.Run("should store links under their derived code", func(t *testing.T) {
+		resetStore()
+
+		shortCode := shorten(URLPair{Original: "https://example.com"})
+
+		should.BeEqual(t, shortCode, generateHMACCode("secret", "https://example.com"))
+		stored, exists, _ := store.Get(shortCode)
+		should.BeTrue(t, exists)
+		should.BeEqual(t, stored.Original, "https://example.com")
+	})
+
+	t.Run("should fall back to a random code when the derived one is taken", func(t *testing.T) {
+		resetStore()
+		derived := generateHMACCode("secret", "https://example.com")
+		store.Set(derived, URLRecord{Original: "https://example.org"})
+
+		shortCode := shorten(URLPair{Original: "https://example.com"})
+
+		should.NotBeEqual(t, shortCode, derived)
+		stored, _, _ := store.Get(derived)
+		should.BeEqual(t, stored.Original, "https://example.org", should.WithMessage("The existing link should be kept"))
+	})
+
+	t.Run("should leave aliases alone", func(t *testing.T) {
+		resetStore()
+
+		should.BeEqual(t, shorten(URLPair{Original: "https://example.com", ShortCode: "mine"}), "mine")
+	})
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// hostLookupTimeout bounds the DNS lookup of a destination host
+const hostLookupTimeout = 2 * time.Second
+
+// noBlockedNetworks is the SNIPLINK_BLOCKED_NETWORKS value that lets links
+// point anywhere, for deployments that shorten internal links
+const noBlockedNetworks = "none"
+
+// defaultBlockedNetworks are the loopback, private, link-local, multicast,
+// reserved and other non public ranges links may not point into unless
+// SNIPLINK_BLOCKED_NETWORKS says otherwise
+var defaultBlockedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+}
+
+// blockedNetworks are the ranges links may not point into, configured
+// through SNIPLINK_BLOCKED_NETWORKS. Empty lets links point anywhere
+var blockedNetworks = defaultBlockedNetworks
+
+// lookupHost resolves destination hosts. Tests replace it to stay off the
+// network
+var lookupHost = net.DefaultResolver.LookupNetIP
+
+var errURLBlocked = errors.New("URL points to a private or internal address")
+
+// parseBlockedNetworks parses a comma separated list of CIDR ranges or
+// single addresses, "none" blocks nothing
+func parseBlockedNetworks(raw string) ([]netip.Prefix, error) {
+	if strings.TrimSpace(raw) == noBlockedNetworks {
+		return nil, nil
+	}
+	return parseNetworks(raw)
+}
+
+// parseNetworks parses a comma separated list of CIDR ranges or single
+// addresses
+func parseNetworks(raw string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(field); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, fmt.Errorf("%q is neither a CIDR range nor an address", field)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// blockedAddr reports whether addr lies in one of blockedNetworks
+func blockedAddr(addr netip.Addr) bool {
+	// IPv4 addresses may come back mapped into IPv6, and zones never match
+	// a prefix
+	addr = addr.Unmap().WithZone("")
+	for _, prefix := range blockedNetworks {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDestination rejects URLs whose host is, or resolves to, an address
+// in blockedNetworks, so the service cannot be used to lure visitors to
+// internal services, and URLs naming an address of this service, which
+// would redirect in loops. raw must have passed validateURL. Hosts that do
+// not resolve are let through, the check is best effort since a name can
+// resolve differently once a visitor follows the link
+func checkDestination(ctx context.Context, raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return errURLMalformed
+	}
+	host := parsed.Hostname()
+
+	ctx, cancel := context.WithTimeout(ctx, hostLookupTimeout)
+	defer cancel()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if isOwnAddr(ctx, parsed, addr) {
+			return errURLSelf
+		}
+		if blockedAddr(addr) {
+			return errURLBlocked
+		}
+		return nil
+	}
+
+	if len(blockedNetworks) == 0 {
+		return nil
+	}
+	addrs, err := lookupHost(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if blockedAddr(addr) {
+			return errURLBlocked
+		}
+	}
+	return nil
+}
+
+// isOwnAddr reports whether u, whose host is the address addr, is on the
+// port of baseURL and addr is one of the addresses of its host. Names are
+// not compared this way, sites behind the same CDN share addresses
+func isOwnAddr(ctx context.Context, u *url.URL, addr netip.Addr) bool {
+	base, err := url.Parse(baseURL)
+	if err != nil || base.Host == "" || urlPort(u) != urlPort(base) {
+		return false
+	}
+	var ownAddrs []netip.Addr
+	if ownAddr, err := netip.ParseAddr(base.Hostname()); err == nil {
+		ownAddrs = []netip.Addr{ownAddr}
+	} else if ownAddrs, err = lookupHost(ctx, "ip", base.Hostname()); err != nil {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+	for _, ownAddr := range ownAddrs {
+		if ownAddr.Unmap().WithZone("") == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// destinationError turns a checkDestination error into the API error
+// answering it
+func destinationError(err error) *apiError {
+	if errors.Is(err, errURLSelf) {
+		return &apiError{err.Error(), errCodeInvalidURL, http.StatusBadRequest}
+	}
+	return &apiError{err.Error(), errCodeBlockedURL, http.StatusForbidden}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+// fakeHosts are the names fakeLookupHost knows, every other name resolves
+// to a public address
+var fakeHosts = map[string][]netip.Addr{
+	"localhost":     {netip.MustParseAddr("::ffff:127.0.0.1")},
+	"internal.test": {netip.MustParseAddr("10.0.0.5")},
+	"mixed.test":    {netip.MustParseAddr("93.184.215.14"), netip.MustParseAddr("192.168.1.1")},
+	"missing.test":  nil,
+	"sniplink.test": {netip.MustParseAddr("203.0.113.10")},
+	"mirror.test":   {netip.MustParseAddr("203.0.113.10")},
+}
+
+// fakeLookupHost resolves names from fakeHosts so tests stay off the network
+func fakeLookupHost(_ context.Context, _, host string) ([]netip.Addr, error) {
+	addrs, known := fakeHosts[host]
+	if !known {
+		return []netip.Addr{netip.MustParseAddr("93.184.215.14")}, nil
+	}
+	if addrs == nil {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func TestMain(m *testing.M) {
+	lookupHost = fakeLookupHost
+	os.Exit(m.Run())
+}
+
+func TestCheckDestination(t *testing.T) {
+	check := func(raw string) error {
+		return checkDestination(context.Background(), raw)
+	}
+
+	t.Run("should allow public destinations", func(t *testing.T) {
+		for _, raw := range []string{"https://example.com", "http://93.184.215.14/path", "https://[2606:2800:220:1::]/"} {
+			should.BeNil(t, check(raw), should.WithMessage(raw+" should be allowed"))
+		}
+	})
+
+	t.Run("should block private, loopback and link-local addresses", func(t *testing.T) {
+		for _, raw := range []string{
+			"http://127.0.0.1:6379", "http://10.1.2.3", "http://172.16.0.1", "http://192.168.0.10",
+			"http://169.254.169.254/latest/meta-data", "http://[::1]:8080", "http://[fe80::1%25eth0]/", "http://[::ffff:10.0.0.1]/",
+		} {
+			should.BeEqual(t, check(raw), errURLBlocked, should.WithMessage(raw+" should be blocked"))
+		}
+	})
+
+	t.Run("should block multicast, reserved, benchmarking and NAT64 addresses", func(t *testing.T) {
+		for _, raw := range []string{
+			"http://224.0.0.251", "http://239.255.255.250:1900", "http://240.0.0.1", "http://255.255.255.255",
+			"http://198.18.0.1", "http://198.19.255.254", "http://[64:ff9b::a9fe:a9fe]/",
+		} {
+			should.BeEqual(t, check(raw), errURLBlocked, should.WithMessage(raw+" should be blocked"))
+		}
+	})
+
+	t.Run("should block names resolving into blocked networks", func(t *testing.T) {
+		for _, raw := range []string{"http://localhost:6379", "https://internal.test/admin", "https://mixed.test"} {
+			should.BeEqual(t, check(raw), errURLBlocked, should.WithMessage(raw+" should be blocked"))
+		}
+	})
+
+	t.Run("should let names that do not resolve through", func(t *testing.T) {
+		should.BeNil(t, check("https://missing.test"))
+	})
+
+	t.Run("should reject addresses of this service", func(t *testing.T) {
+		saved := baseURL
+		baseURL = "https://sniplink.test"
+		defer func() { baseURL = saved }()
+
+		for _, raw := range []string{"https://203.0.113.10/abc123", "https://[::ffff:203.0.113.10]:443/"} {
+			should.BeEqual(t, check(raw), errURLSelf, should.WithMessage(raw+" should be rejected"))
+		}
+		should.BeNil(t, check("http://203.0.113.10/abc123"), should.WithMessage("Another port is another server"))
+		should.BeNil(t, check("https://mirror.test/abc123"), should.WithMessage("Names sharing an address may be other sites"))
+	})
+
+	t.Run("should reject loopback addresses of a local service", func(t *testing.T) {
+		should.BeEqual(t, check("http://127.0.0.1:8080/abc123"), errURLSelf)
+	})
+
+	t.Run("should allow everything with no blocked networks", func(t *testing.T) {
+		blockedNetworks = nil
+		defer func() { blockedNetworks = defaultBlockedNetworks }()
+
+		should.BeNil(t, check("http://127.0.0.1:6379"))
+		should.BeNil(t, check("https://internal.test"))
+	})
+}
+
+func TestParseBlockedNetworks(t *testing.T) {
+	t.Run("should parse ranges and single addresses", func(t *testing.T) {
+		prefixes, err := parseBlockedNetworks("10.0.0.0/8, 192.168.1.7,::1")
+		should.BeNil(t, err)
+		should.BeEqual(t, prefixes, []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("192.168.1.7/32"),
+			netip.MustParsePrefix("::1/128"),
+		})
+	})
+
+	t.Run("should block nothing for none", func(t *testing.T) {
+		prefixes, err := parseBlockedNetworks("none")
+		should.BeNil(t, err)
+		should.BeEmpty(t, prefixes)
+	})
+
+	t.Run("should reject invalid entries", func(t *testing.T) {
+		_, err := parseBlockedNetworks("10.0.0.0/8,intranet")
+		should.NotBeNil(t, err)
+	})
+}
+
+func TestBlockedDestinations(t *testing.T) {
+	t.Run("should refuse to shorten internal URLs with 403", func(t *testing.T) {
+		resetStore()
+
+		w := httptest.NewRecorder()
+		route(w, jsonRequest(http.MethodPost, "/shorten", strings.NewReader(`{"original":"http://169.254.169.254/"}`)))
+
+		should.BeEqual(t, w.Code, http.StatusForbidden)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeBlockedURL)
+		should.BeEmpty(t, storedLinks(t))
+	})
+
+	t.Run("should refuse to point a link at an internal URL", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := httptest.NewRecorder()
+		route(w, jsonRequest(http.MethodPut, "/abc123", strings.NewReader(`{"original":"http://localhost:6379"}`)))
+
+		should.BeEqual(t, w.Code, http.StatusForbidden)
+		record, _, _ := store.Get("abc123")
+		should.BeEqual(t, record.Original, "https://example.com")
+	})
+
+	t.Run("should report blocked items of a batch", func(t *testing.T) {
+		resetStore()
+
+		w := httptest.NewRecorder()
+		route(w, jsonRequest(http.MethodPost, "/shorten/batch",
+			strings.NewReader(`[{"original":"https://example.com"},{"original":"http://10.0.0.1"}]`)))
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.ContainSubstring(t, w.Body.String(), errCodeBlockedURL)
+		should.HaveLength(t, storedLinks(t), 1)
+	})
+
+	t.Run("should refuse to shorten an address of this service with 400", func(t *testing.T) {
+		resetStore()
+
+		w := httptest.NewRecorder()
+		route(w, jsonRequest(http.MethodPost, "/shorten", strings.NewReader(`{"original":"http://127.0.0.1:8080/abc123"}`)))
+
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+		resp := decodeError(t, w)
+		should.BeEqual(t, resp.Code, errCodeInvalidURL)
+		should.BeEqual(t, resp.Error, errURLSelf.Error())
+		should.BeEmpty(t, storedLinks(t))
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webFS holds the landing page, robots.txt and the default icon, shipped
+// inside the binary
+//
+//go:embed web/index.html web/robots.txt web/favicon.svg
+var webFS embed.FS
+
+// faviconMaxAge is how long browsers may cache the icon, they ask on every
+// page load otherwise
+const faviconMaxAge = 7 * 24 * time.Hour
+
+// favicon is the icon served at /favicon.ico, read from
+// SNIPLINK_FAVICON_FILE at startup. Without one the embedded
+// web/favicon.svg is served
+var favicon []byte
+
+// indexHandler serves the landing page at /, a form that shortens URLs
+// through POST /shorten
+func indexHandler(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, webFS, "web/index.html")
+}
+
+// robotsHandler serves /robots.txt, which keeps crawlers on the short links
+// and off the API
+func robotsHandler(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, webFS, "web/robots.txt")
+}
+
+// faviconHandler serves /favicon.ico, which browsers fetch unasked and
+// would otherwise look up as a short code
+func faviconHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(faviconMaxAge.Seconds())))
+	if len(favicon) == 0 {
+		// Set, the system MIME table may not know .svg
+		w.Header().Set("Content-Type", "image/svg+xml")
+		http.ServeFileFS(w, r, webFS, "web/favicon.svg")
+		return
+	}
+	// Sniffed, the configured file may be a PNG and the system MIME table
+	// decides what .ico maps to
+	w.Header().Set("Content-Type", http.DetectContentType(favicon))
+	http.ServeContent(w, r, "favicon.ico", startTime, bytes.NewReader(favicon))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestIndexHandler(t *testing.T) {
+	t.Run("should serve a form that posts to /shorten", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		body := w.Body.String()
+		should.ContainSubstring(t, body, `action="/shorten"`)
+		should.ContainSubstring(t, body, `name="original"`)
+		should.ContainSubstring(t, body, `name="short_code"`)
+	})
+
+	t.Run("should keep 404 for unknown codes", func(t *testing.T) {
+		resetStore()
+
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeNotFound)
+	})
+}
+
+func TestFaviconHandler(t *testing.T) {
+	t.Run("should serve the built-in icon without a configured one", func(t *testing.T) {
+		resetStore()
+
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/favicon.ico", nil))
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "image/svg+xml")
+		should.ContainSubstring(t, w.Body.String(), "<svg")
+		should.BeEqual(t, w.Header().Get("Cache-Control"), "public, max-age=604800")
+	})
+
+	t.Run("should serve the configured icon", func(t *testing.T) {
+		// An ICO header for one 16x16 image
+		icon := []byte{0, 0, 1, 0, 1, 0, 16, 16, 0, 0, 1, 0, 32, 0}
+		favicon = icon
+		defer func() { favicon = nil }()
+
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/favicon.ico", nil))
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Body.Bytes(), icon)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "image/x-icon")
+	})
+
+	t.Run("should not log requests", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		saved := logger
+		logger = zap.New(core)
+		defer func() { logger = saved }()
+
+		for _, target := range []string{"/favicon.ico", "/robots.txt"} {
+			route(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+		}
+
+		should.BeEqual(t, logs.Len(), 0)
+	})
+}
+
+func TestRobotsHandler(t *testing.T) {
+	t.Run("should keep crawlers off the API", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/robots.txt", nil))
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.ContainSubstring(t, w.Header().Get("Content-Type"), "text/plain")
+		should.ContainSubstring(t, w.Body.String(), "User-agent: *")
+		should.ContainSubstring(t, w.Body.String(), "Disallow: /shorten")
+	})
+}
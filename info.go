@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// infoResponse is the body returned by infoHandler
+type infoResponse struct {
+	ShortCode   string    `json:"short_code"`
+	OriginalURL string    `json:"original_url"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+	ExpiresAt   time.Time `json:"expires_at,omitzero"`
+	Clicks      uint64    `json:"clicks"`
+}
+
+// infoHandler describes the short link named by /{code}/info without
+// redirecting: where it points, when it was created and how often it was
+// followed. Expired links are still described, with their expiry
+func infoHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := shortCodeParam(r)
+	record, clicks, exists, err := store.Stats(shortCode)
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+	if !exists {
+		errorResponse(w, "Short code not found", errCodeNotFound, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infoResponse{
+		ShortCode:   shortCode,
+		OriginalURL: record.Original,
+		CreatedAt:   record.CreatedAt,
+		ExpiresAt:   record.ExpiresAt,
+		Clicks:      clicks.Total,
+	})
+}
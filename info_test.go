@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestInfoHandler(t *testing.T) {
+	getInfo := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	t.Run("should describe the link without redirecting", func(t *testing.T) {
+		resetStore()
+		createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		store.Set("abc123", URLRecord{Original: "https://example.com", CreatedAt: createdAt})
+		store.Resolve("abc123", time.Now(), false)
+		store.Resolve("abc123", time.Now(), false)
+
+		w := getInfo("/abc123/info")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json")
+		should.BeEqual(t, w.Header().Get("Location"), "", should.WithMessage("Info should not redirect"))
+
+		var response infoResponse
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeNil(t, err, should.WithMessage("Response should be valid JSON"))
+		should.BeEqual(t, response.ShortCode, "abc123")
+		should.BeEqual(t, response.OriginalURL, "https://example.com")
+		should.BeTrue(t, response.CreatedAt.Equal(createdAt), should.WithMessage("Info should report when the link was created"))
+		should.BeEqual(t, response.Clicks, uint64(2))
+	})
+
+	t.Run("should not count a lookup as a click", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		getInfo("/abc123/info")
+
+		_, clicks, _, _ := store.Stats("abc123")
+		should.BeEqual(t, clicks.Total, uint64(0))
+	})
+
+	t.Run("should describe expired links with their expiry", func(t *testing.T) {
+		resetStore()
+		expiresAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+		store.Set("abc123", URLRecord{Original: "https://example.com", ExpiresAt: expiresAt})
+
+		w := getInfo("/abc123/info")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		var response infoResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeTrue(t, response.ExpiresAt.Equal(expiresAt), should.WithMessage("Info should report the expiry"))
+	})
+
+	t.Run("should serve namespaced links", func(t *testing.T) {
+		resetStore()
+		store.Set("team:abc123", URLRecord{Original: "https://example.com"})
+
+		w := getInfo("/team/abc123/info")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		var response infoResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeEqual(t, response.ShortCode, "team:abc123")
+	})
+
+	t.Run("should return 404 for unknown codes", func(t *testing.T) {
+		resetStore()
+
+		w := getInfo("/missing/info")
+
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeNotFound)
+	})
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPerPage is how many links a page of GET /links holds unless the
+	// per_page query parameter asks for another number
+	defaultPerPage = 20
+	maxPerPage     = 100
+
+	// defaultSearchLimit is how many matches GET /links/search returns unless
+	// the limit query parameter asks for another number
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+)
+
+var (
+	errPage        = errors.New("page must be a positive whole number")
+	errPerPage     = errors.New("per_page must be a whole number between 1 and 100")
+	errOffset      = errors.New("offset must be a whole number, 0 or more")
+	errListLimit   = errors.New("limit must be a whole number between 1 and 100")
+	errPageMix     = errors.New("page and per_page cannot be combined with offset and limit")
+	errSearchQuery = errors.New("q must not be empty")
+	errLimit       = errors.New("limit must be a whole number between 1 and 200")
+)
+
+// linkEntry describes a stored short link in listing responses
+type linkEntry struct {
+	ShortCode   string    `json:"short_code"`
+	OriginalURL string    `json:"original_url"`
+	ShortURL    string    `json:"short_url"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+}
+
+// newLinkEntry describes the link stored under shortCode
+func newLinkEntry(shortCode string, record URLRecord) linkEntry {
+	return linkEntry{
+		ShortCode:   shortCode,
+		OriginalURL: record.Original,
+		ShortURL:    shortURL(shortCode),
+		CreatedAt:   record.CreatedAt,
+	}
+}
+
+// listedLink describes a stored short link and its clicks in GET /links and
+// GET /links/search responses
+type listedLink struct {
+	linkEntry
+	Clicks uint64 `json:"clicks"`
+}
+
+// newListedLinks describes the links of mappings stored under shortCodes.
+// Links deleted since mappings was read are listed without clicks
+func newListedLinks(mappings map[string]URLRecord, shortCodes []string) ([]listedLink, error) {
+	links := make([]listedLink, 0, len(shortCodes))
+	for _, shortCode := range shortCodes {
+		_, clicks, _, err := store.Stats(shortCode)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, listedLink{newLinkEntry(shortCode, mappings[shortCode]), clicks.Total})
+	}
+	return links, nil
+}
+
+// newestFirst returns the short codes of mappings, newest first. Links
+// created at the same time are ordered by short code
+func newestFirst(mappings map[string]URLRecord) []string {
+	shortCodes := make([]string, 0, len(mappings))
+	for shortCode := range mappings {
+		shortCodes = append(shortCodes, shortCode)
+	}
+	slices.SortFunc(shortCodes, func(a, b string) int {
+		if c := mappings[b].CreatedAt.Compare(mappings[a].CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	return shortCodes
+}
+
+// linkPage is the body returned by listHandler. Page and PerPage echo a
+// page based request, Limit an offset based one, Offset is set for both
+type linkPage struct {
+	Data    []listedLink `json:"data"`
+	Total   int          `json:"total"`
+	Page    int          `json:"page,omitempty"`
+	PerPage int          `json:"per_page,omitempty"`
+	Offset  int          `json:"offset"`
+	Limit   int          `json:"limit,omitempty"`
+}
+
+// parsePageQuery parses the window of links asked for, either by the page
+// and per_page query parameters or by offset and limit. Empty values select
+// the first page and defaultPerPage. The returned linkPage has no Data
+func parsePageQuery(query url.Values) (linkPage, error) {
+	rawPage, rawPerPage := query.Get("page"), query.Get("per_page")
+	rawOffset, rawLimit := query.Get("offset"), query.Get("limit")
+	if (rawPage != "" || rawPerPage != "") && (rawOffset != "" || rawLimit != "") {
+		return linkPage{}, errPageMix
+	}
+
+	if rawOffset != "" || rawLimit != "" {
+		result := linkPage{Limit: defaultPerPage}
+		if rawOffset != "" {
+			n, err := strconv.Atoi(rawOffset)
+			if err != nil || n < 0 {
+				return linkPage{}, errOffset
+			}
+			result.Offset = n
+		}
+		if rawLimit != "" {
+			n, err := strconv.Atoi(rawLimit)
+			if err != nil || n < 1 || n > maxPerPage {
+				return linkPage{}, errListLimit
+			}
+			result.Limit = n
+		}
+		return result, nil
+	}
+
+	result := linkPage{Page: 1, PerPage: defaultPerPage}
+	if rawPage != "" {
+		n, err := strconv.Atoi(rawPage)
+		if err != nil || n < 1 {
+			return linkPage{}, errPage
+		}
+		result.Page = n
+	}
+	if rawPerPage != "" {
+		n, err := strconv.Atoi(rawPerPage)
+		if err != nil || n < 1 || n > maxPerPage {
+			return linkPage{}, errPerPage
+		}
+		result.PerPage = n
+	}
+	// Pages past any store are clamped so (page-1)*per_page cannot overflow
+	result.Offset = math.MaxInt
+	if result.Page-1 <= math.MaxInt/result.PerPage {
+		result.Offset = (result.Page - 1) * result.PerPage
+	}
+	return result, nil
+}
+
+// listHandler returns a window of the stored links with their clicks,
+// newest first, with the number of links in the X-Total-Count header
+func listHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePageQuery(r.URL.Query())
+	if err != nil {
+		errorResponse(w, err.Error(), errCodeInvalidQuery, http.StatusBadRequest)
+		return
+	}
+
+	mappings, err := store.List()
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+	shortCodes := newestFirst(mappings)
+
+	size := page.PerPage
+	if page.Limit != 0 {
+		size = page.Limit
+	}
+	start := min(page.Offset, len(shortCodes))
+	end := start + min(size, len(shortCodes)-start)
+	page.Data, err = newListedLinks(mappings, shortCodes[start:end])
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+	page.Total = len(shortCodes)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(shortCodes)))
+	json.NewEncoder(w).Encode(page)
+}
+
+// searchResult is the body returned by searchHandler, Total counts every
+// match including those past the limit
+type searchResult struct {
+	Data  []listedLink `json:"data"`
+	Total int          `json:"total"`
+}
+
+// parseSearchLimit parses the limit query parameter, an empty value selects
+// defaultSearchLimit
+func parseSearchLimit(raw string) (int, error) {
+	if raw == "" {
+		return defaultSearchLimit, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > maxSearchLimit {
+		return 0, errLimit
+	}
+	return n, nil
+}
+
+// searchHandler serves GET /links/search, the links whose original URL
+// contains the q query parameter regardless of case, newest first and at
+// most limit of them
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(r.URL.Query().Get("q"))
+	if query == "" {
+		errorResponse(w, errSearchQuery.Error(), errCodeInvalidQuery, http.StatusBadRequest)
+		return
+	}
+	limit, err := parseSearchLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		errorResponse(w, err.Error(), errCodeInvalidQuery, http.StatusBadRequest)
+		return
+	}
+
+	mappings, err := store.List()
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+	var matches []string
+	for _, shortCode := range newestFirst(mappings) {
+		if strings.Contains(strings.ToLower(mappings[shortCode].Original), query) {
+			matches = append(matches, shortCode)
+		}
+	}
+	result := searchResult{Total: len(matches)}
+	result.Data, err = newListedLinks(mappings, matches[:min(limit, len(matches))])
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestListHandler(t *testing.T) {
+	getLinks := func(target string) (*httptest.ResponseRecorder, linkPage) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		w := httptest.NewRecorder()
+		listHandler(w, req)
+
+		var page linkPage
+		json.Unmarshal(w.Body.Bytes(), &page)
+		return w, page
+	}
+	// storeNumbered stores n links, link0 being the oldest
+	storeNumbered := func(n int) {
+		start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i := range n {
+			store.Set(fmt.Sprintf("link%d", i), URLRecord{
+				Original:  fmt.Sprintf("https://example.com/%d", i),
+				CreatedAt: start.Add(time.Duration(i) * time.Minute),
+			})
+		}
+	}
+
+	t.Run("should return an empty array for an empty store", func(t *testing.T) {
+		resetStore()
+
+		w, page := getLinks("/links")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json")
+		should.BeEqual(t, w.Header().Get("X-Total-Count"), "0")
+		should.NotBeNil(t, page.Data, should.WithMessage("Empty store should encode as an array, not null"))
+		should.BeEmpty(t, page.Data)
+		should.BeEqual(t, page.Total, 0)
+	})
+
+	t.Run("should list the newest links first", func(t *testing.T) {
+		resetStore()
+		createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		store.Set("abc123", URLRecord{Original: "https://example.com", CreatedAt: createdAt})
+		store.Set("xyz789", URLRecord{Original: "https://google.com", CreatedAt: createdAt.Add(time.Hour)})
+		store.Set("def456", URLRecord{Original: "https://example.org", CreatedAt: createdAt})
+
+		w, page := getLinks("/links")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("X-Total-Count"), "3")
+		should.BeEqual(t, page.Total, 3)
+		should.BeEqual(t, page.Page, 1)
+		should.BeEqual(t, page.PerPage, defaultPerPage)
+		should.BeEqual(t, len(page.Data), 3)
+		should.BeEqual(t, page.Data[0].ShortCode, "xyz789")
+		should.BeEqual(t, page.Data[0].OriginalURL, "https://google.com")
+		should.BeEqual(t, page.Data[0].ShortURL, "http://localhost:8080/xyz789")
+		should.BeEqual(t, page.Data[1].ShortCode, "abc123", should.WithMessage("Ties should be ordered by short code"))
+		should.BeEqual(t, page.Data[2].ShortCode, "def456")
+	})
+
+	t.Run("should include the creation time", func(t *testing.T) {
+		resetStore()
+		createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		store.Set("abc123", URLRecord{Original: "https://example.com", CreatedAt: createdAt})
+
+		w, page := getLinks("/links")
+
+		should.ContainSubstring(t, w.Body.String(), `"created_at":"2025-01-02T03:04:05Z"`)
+		should.BeTrue(t, page.Data[0].CreatedAt.Equal(createdAt), should.WithMessage("Creation time should round trip"))
+	})
+
+	t.Run("should return the first page", func(t *testing.T) {
+		resetStore()
+		storeNumbered(25)
+
+		_, page := getLinks("/links")
+
+		should.BeEqual(t, len(page.Data), defaultPerPage)
+		should.BeEqual(t, page.Data[0].ShortCode, "link24")
+		should.BeEqual(t, page.Total, 25)
+	})
+
+	t.Run("should return a partial last page", func(t *testing.T) {
+		resetStore()
+		storeNumbered(25)
+
+		_, page := getLinks("/links?page=3&per_page=10")
+
+		should.BeEqual(t, page.Page, 3)
+		should.BeEqual(t, page.PerPage, 10)
+		should.BeEqual(t, len(page.Data), 5)
+		should.BeEqual(t, page.Data[0].ShortCode, "link4")
+		should.BeEqual(t, page.Data[4].ShortCode, "link0")
+	})
+
+	t.Run("should return an empty page beyond the end", func(t *testing.T) {
+		resetStore()
+		storeNumbered(5)
+
+		for _, target := range []string{"/links?page=2", "/links?page=" + strconv.Itoa(math.MaxInt)} {
+			w, page := getLinks(target)
+
+			should.BeEqual(t, w.Code, http.StatusOK, should.WithMessage("Target "+target))
+			should.NotBeNil(t, page.Data)
+			should.BeEmpty(t, page.Data)
+			should.BeEqual(t, page.Total, 5)
+		}
+	})
+
+	t.Run("should reject invalid page parameters", func(t *testing.T) {
+		for _, query := range []string{"page=0", "page=-1", "page=abc", "per_page=0", "per_page=101", "per_page=1.5"} {
+			w, _ := getLinks("/links?" + query)
+
+			should.BeEqual(t, w.Code, http.StatusBadRequest, should.WithMessage("Query "+query))
+			should.BeEqual(t, decodeError(t, w).Code, errCodeInvalidQuery)
+		}
+	})
+
+	t.Run("should return the window asked for by offset and limit", func(t *testing.T) {
+		resetStore()
+		storeNumbered(25)
+
+		_, page := getLinks("/links?offset=3&limit=4")
+
+		should.BeEqual(t, page.Offset, 3)
+		should.BeEqual(t, page.Limit, 4)
+		should.BeEqual(t, page.Page, 0, should.WithMessage("Offset based pages have no page number"))
+		should.BeEqual(t, len(page.Data), 4)
+		should.BeEqual(t, page.Data[0].ShortCode, "link21")
+		should.BeEqual(t, page.Data[3].ShortCode, "link18")
+		should.BeEqual(t, page.Total, 25)
+	})
+
+	t.Run("should default the limit and report the offset of pages", func(t *testing.T) {
+		resetStore()
+		storeNumbered(25)
+
+		_, page := getLinks("/links?offset=20")
+		should.BeEqual(t, page.Limit, defaultPerPage)
+		should.BeEqual(t, len(page.Data), 5)
+
+		_, page = getLinks("/links?page=2&per_page=10")
+		should.BeEqual(t, page.Offset, 10)
+		should.BeEqual(t, page.Limit, 0)
+	})
+
+	t.Run("should return an empty window beyond the end", func(t *testing.T) {
+		resetStore()
+		storeNumbered(5)
+
+		w, page := getLinks("/links?offset=" + strconv.Itoa(math.MaxInt))
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.NotBeNil(t, page.Data)
+		should.BeEmpty(t, page.Data)
+	})
+
+	t.Run("should reject invalid offset parameters", func(t *testing.T) {
+		for _, query := range []string{"offset=-1", "offset=abc", "limit=0", "limit=101", "page=2&offset=10", "per_page=5&limit=5"} {
+			w, _ := getLinks("/links?" + query)
+
+			should.BeEqual(t, w.Code, http.StatusBadRequest, should.WithMessage("Query "+query))
+			should.BeEqual(t, decodeError(t, w).Code, errCodeInvalidQuery)
+		}
+	})
+
+	t.Run("should include the clicks of every link", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		store.Set("def456", URLRecord{Original: "https://example.org"})
+		for range 3 {
+			store.Resolve("abc123", time.Now(), false)
+		}
+
+		w, page := getLinks("/links")
+
+		should.ContainSubstring(t, w.Body.String(), `"clicks":0`, should.WithMessage("Unclicked links should report 0"))
+		clicks := map[string]uint64{}
+		for _, link := range page.Data {
+			clicks[link.ShortCode] = link.Clicks
+		}
+		should.BeEqual(t, clicks, map[string]uint64{"abc123": 3, "def456": 0})
+	})
+
+	t.Run("should return method not allowed for non-GET requests", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/links", nil)
+		w := httptest.NewRecorder()
+
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusMethodNotAllowed)
+	})
+}
+
+func TestSearchHandler(t *testing.T) {
+	search := func(query string) (*httptest.ResponseRecorder, searchResult) {
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/links/search?"+query, nil))
+
+		var result searchResult
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w, result
+	}
+	codes := func(result searchResult) []string {
+		shortCodes := make([]string, 0, len(result.Data))
+		for _, entry := range result.Data {
+			shortCodes = append(shortCodes, entry.ShortCode)
+		}
+		return shortCodes
+	}
+	storeLinks := func() {
+		resetStore()
+		start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		store.Set("docs", URLRecord{Original: "https://example.com/Docs/intro", CreatedAt: start})
+		store.Set("blog", URLRecord{Original: "https://example.com/blog", CreatedAt: start.Add(time.Minute)})
+		store.Set("other", URLRecord{Original: "https://other.org/docs", CreatedAt: start.Add(2 * time.Minute)})
+	}
+
+	t.Run("should find an exact original URL", func(t *testing.T) {
+		storeLinks()
+
+		w, result := search("q=" + url.QueryEscape("https://example.com/blog"))
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, codes(result), []string{"blog"})
+		should.BeEqual(t, result.Data[0].ShortURL, shortURL("blog"))
+	})
+
+	t.Run("should include the clicks of matches", func(t *testing.T) {
+		storeLinks()
+		store.Resolve("blog", time.Now(), false)
+
+		_, result := search("q=blog")
+
+		should.BeEqual(t, result.Data[0].Clicks, uint64(1))
+	})
+
+	t.Run("should find partial matches newest first", func(t *testing.T) {
+		storeLinks()
+
+		_, result := search("q=example.com")
+
+		should.BeEqual(t, codes(result), []string{"blog", "docs"})
+		should.BeEqual(t, result.Total, 2)
+	})
+
+	t.Run("should ignore case", func(t *testing.T) {
+		storeLinks()
+
+		_, result := search("q=DOCS")
+
+		should.BeEqual(t, codes(result), []string{"other", "docs"})
+	})
+
+	t.Run("should return an empty array without matches", func(t *testing.T) {
+		storeLinks()
+
+		w, _ := search("q=nothing")
+
+		should.BeEqual(t, strings.TrimSpace(w.Body.String()), `{"data":[],"total":0}`)
+	})
+
+	t.Run("should reject an empty query", func(t *testing.T) {
+		for _, query := range []string{"", "q="} {
+			w, _ := search(query)
+
+			should.BeEqual(t, w.Code, http.StatusBadRequest, should.WithMessage("Query "+query))
+			should.BeEqual(t, decodeError(t, w).Error, errSearchQuery.Error())
+		}
+	})
+
+	t.Run("should enforce the limit", func(t *testing.T) {
+		resetStore()
+		for i := range defaultSearchLimit + 10 {
+			store.Set(fmt.Sprintf("link%d", i), URLRecord{Original: fmt.Sprintf("https://example.com/%d", i)})
+		}
+
+		_, result := search("q=example")
+		should.HaveLength(t, result.Data, defaultSearchLimit)
+		should.BeEqual(t, result.Total, defaultSearchLimit+10, should.WithMessage("Total should count every match"))
+
+		_, result = search("q=example&limit=5")
+		should.HaveLength(t, result.Data, 5)
+
+		for _, limit := range []string{"0", "201", "ten"} {
+			w, _ := search("q=example&limit=" + limit)
+			should.BeEqual(t, w.Code, http.StatusBadRequest, should.WithMessage("limit="+limit))
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Values of SNIPLINK_LOG_FORMAT
+const (
+	logFormatJSON    = "json"
+	logFormatConsole = "console"
+)
+
+// logLevel is the level of logger, changed at runtime through
+// PUT /admin/log-level
+var logLevel = zap.NewAtomicLevel()
+
+// newLogger builds the logger selected by a log format and level setting,
+// along with the level that controls it. JSON logs use the production
+// configuration and console logs the development one, human readable and
+// with stack traces on warnings. An empty level keeps the default of the
+// format, info for JSON and debug for console
+func newLogger(format, level string) (*zap.Logger, zap.AtomicLevel, error) {
+	var config zap.Config
+	switch format {
+	case logFormatJSON:
+		config = zap.NewProductionConfig()
+	case logFormatConsole:
+		config = zap.NewDevelopmentConfig()
+	default:
+		return nil, zap.AtomicLevel{}, fmt.Errorf("log format %q is not %q or %q", format, logFormatJSON, logFormatConsole)
+	}
+
+	if level != "" {
+		atomicLevel, err := zap.ParseAtomicLevel(level)
+		if err != nil {
+			return nil, zap.AtomicLevel{}, err
+		}
+		config.Level = atomicLevel
+	}
+	logger, err := config.Build()
+	return logger, config.Level, err
+}
+
+// logLevelRequest is the body of PUT /admin/log-level and its response
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// logLevelHandler changes the level of logger without a restart
+func logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	var body logLevelRequest
+	if apiErr := decodeJSONBody(w, r, &body); apiErr != nil {
+		apiErr.write(w)
+		return
+	}
+	// zap reads an empty level as info
+	if body.Level == "" {
+		errorResponse(w, "level is required", errCodeInvalidBody, http.StatusBadRequest)
+		return
+	}
+	level, err := zapcore.ParseLevel(body.Level)
+	if err != nil {
+		errorResponse(w, err.Error(), errCodeInvalidBody, http.StatusBadRequest)
+		return
+	}
+
+	// Logged before the change, a higher level would hide the line
+	requestLogger(r).Info("Changing log level", zap.Stringer("from", logLevel.Level()), zap.Stringer("to", level))
+	logLevel.SetLevel(level)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(logLevelRequest{Level: level.String()})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewLogger(t *testing.T) {
+	t.Run("should default to info for JSON and debug for console", func(t *testing.T) {
+		jsonLogger, _, err := newLogger(logFormatJSON, "")
+		should.BeNil(t, err)
+		should.BeTrue(t, jsonLogger.Core().Enabled(zap.InfoLevel))
+		should.BeFalse(t, jsonLogger.Core().Enabled(zap.DebugLevel))
+
+		consoleLogger, _, err := newLogger(logFormatConsole, "")
+		should.BeNil(t, err)
+		should.BeTrue(t, consoleLogger.Core().Enabled(zap.DebugLevel))
+	})
+
+	t.Run("should apply the configured level", func(t *testing.T) {
+		warnLogger, _, err := newLogger(logFormatConsole, "warn")
+
+		should.BeNil(t, err)
+		should.BeFalse(t, warnLogger.Core().Enabled(zap.InfoLevel))
+		should.BeTrue(t, warnLogger.Core().Enabled(zap.WarnLevel))
+	})
+
+	t.Run("should return the level controlling the logger", func(t *testing.T) {
+		logger, level, err := newLogger(logFormatJSON, "")
+		should.BeNil(t, err)
+
+		level.SetLevel(zap.DebugLevel)
+
+		should.BeTrue(t, logger.Core().Enabled(zap.DebugLevel))
+	})
+
+	t.Run("should reject unknown formats and levels", func(t *testing.T) {
+		_, _, err := newLogger("xml", "")
+		should.NotBeNil(t, err)
+
+		_, _, err = newLogger(logFormatJSON, "loud")
+		should.NotBeNil(t, err)
+	})
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	apiKey = "secret"
+	defer func() { apiKey = "" }()
+	setLevel := func(body string) *httptest.ResponseRecorder {
+		req := jsonRequest(http.MethodPut, "/admin/log-level", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		route(w, req)
+		return w
+	}
+
+	t.Run("should change the level of the running logger", func(t *testing.T) {
+		saved, savedLevel := logger, logLevel
+		logLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
+		core, logs := observer.New(logLevel)
+		logger = zap.New(core)
+		defer func() { logger, logLevel = saved, savedLevel }()
+
+		logger.Debug("hidden")
+		should.BeEqual(t, logs.FilterMessage("hidden").Len(), 0)
+
+		w := setLevel(`{"level":"debug"}`)
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, strings.TrimSpace(w.Body.String()), `{"level":"debug"}`)
+		logger.Debug("shown")
+		should.BeEqual(t, logs.FilterMessage("shown").Len(), 1)
+
+		should.BeEqual(t, setLevel(`{"level":"info"}`).Code, http.StatusOK)
+		logger.Debug("hidden again")
+		should.BeEqual(t, logs.FilterMessage("hidden again").Len(), 0)
+		should.BeEqual(t, logs.FilterMessage("Changing log level").Len(), 2)
+	})
+
+	t.Run("should reject unknown levels", func(t *testing.T) {
+		savedLevel := logLevel
+		logLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
+		defer func() { logLevel = savedLevel }()
+
+		for _, body := range []string{`{"level":"loud"}`, `{"level":""}`, `{}`} {
+			w := setLevel(body)
+
+			should.BeEqual(t, w.Code, http.StatusBadRequest, should.WithMessage(body))
+			should.BeEqual(t, decodeError(t, w).Code, errCodeInvalidBody)
+		}
+		should.BeEqual(t, logLevel.Level(), zap.InfoLevel)
+	})
+
+	t.Run("should require the API key", func(t *testing.T) {
+		savedLevel := logLevel
+		logLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
+		defer func() { logLevel = savedLevel }()
+
+		w := httptest.NewRecorder()
+		route(w, jsonRequest(http.MethodPut, "/admin/log-level", strings.NewReader(`{"level":"debug"}`)))
+
+		should.BeEqual(t, w.Code, http.StatusUnauthorized)
+		should.BeEqual(t, logLevel.Level(), zap.InfoLevel)
+	})
+
+	t.Run("should not exist without an API key", func(t *testing.T) {
+		savedLevel := logLevel
+		logLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
+		apiKey = ""
+		defer func() { logLevel, apiKey = savedLevel, "secret" }()
+
+		w := setLevel(`{"level":"debug"}`)
+
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+		should.BeEqual(t, logLevel.Level(), zap.InfoLevel)
+	})
+}
@@ -1,9 +1,18 @@
 package main
 
 import (
+	"crypto/rand"
 	"encoding/json"
-	"math/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -12,92 +21,646 @@ import (
 type URLPair struct {
 	Original  string `json:"original"`
 	ShortCode string `json:"short_code"`
+	ExpiresIn int64  `json:"expires_in,omitempty"`
+	// TTLSeconds is an alias of ExpiresIn
+	TTLSeconds int64     `json:"ttl_seconds,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"`
+	// Namespace groups the link under /{namespace}/{code}, empty keeps it at /{code}
+	Namespace string `json:"namespace,omitempty"`
+	// Permanent makes the link redirect with 301 instead of redirectStatus
+	Permanent bool `json:"permanent,omitempty"`
 }
 
-var urlMap = make(map[string]string)
-var logger *zap.Logger
+// maxCodeRetries is how many times shortenHandler regenerates a short code
+// that collides with an existing one before giving up
+const maxCodeRetries = 5
 
-// loggingMiddleware logs the start and end of each request
+const (
+	// defaultRateLimitRPS is the sustained number of write requests per second
+	// allowed per client IP unless SNIPLINK_RATE_LIMIT_RPS overrides it
+	defaultRateLimitRPS = 5
+	// defaultRateLimitBurst is the number of write requests a client IP may send
+	// at once unless SNIPLINK_RATE_LIMIT_BURST overrides it
+	defaultRateLimitBurst = 10
+)
+
+var errNoUniqueCode = errors.New("no unique short code found")
+
+const (
+	// defaultDataFile is where URL mappings are persisted unless
+	// SNIPLINK_DATA_FILE or --data-file points elsewhere
+	defaultDataFile = "data/urls.json"
+	// defaultAddr is the listen address unless SNIPLINK_ADDR or --port
+	// overrides it
+	defaultAddr = ":8080"
+	// defaultBaseURL is the short URL prefix unless SNIPLINK_BASE_URL overrides it
+	defaultBaseURL = "http://localhost:8080"
+	// defaultSQLitePath is the database file of the sqlite store unless
+	// SNIPLINK_SQLITE_PATH points elsewhere
+	defaultSQLitePath = "data/urls.db"
+	// defaultBoltPath is the database file of the bolt store unless
+	// SNIPLINK_BOLT_PATH points elsewhere
+	defaultBoltPath = "data/urls.bolt"
+)
+
+// Values of SNIPLINK_STORE
+const (
+	storeMemory = "memory"
+	storeSQLite = "sqlite"
+	storeRedis  = "redis"
+	storeBolt   = "bolt"
+)
+
+var store URLStore = newURLStore()
+var logger = zap.NewNop()
+
+// baseURL is prepended to short codes to build the public short URLs
+var baseURL = defaultBaseURL
+
+// redirectStatus is the status code redirectHandler answers with,
+// configured through SNIPLINK_REDIRECT_STATUS
+var redirectStatus = http.StatusTemporaryRedirect
+
+// deduplicate makes shortenHandler return the existing short code for URLs
+// that were already shortened, configured through SNIPLINK_DEDUPLICATE
+var deduplicate bool
+
+// dataFile is the path URL mappings are persisted to, empty disables persistence
+var dataFile string
+
+// persistMu serializes writes to dataFile
+var persistMu sync.Mutex
+
+// CodeGenerator produces a candidate short code of the given length.
+// Generators that ignore the length, like the sequential one, may return
+// codes of any length
+type CodeGenerator func(length int) (string, error)
+
+// newShortCode is the CodeGenerator links are created with, configured
+// through SNIPLINK_CODE_MODE. Tests replace it with stubs returning known
+// codes, or colliding ones
+var newShortCode CodeGenerator = generateShortCode
+
+const (
+	defaultCodeLength = 6
+	minCodeLength     = 4
+	maxCodeLength     = 32
+)
+
+// codeLength is the length of generated short codes, configured through
+// SNIPLINK_CODE_LENGTH
+var codeLength = defaultCodeLength
+
+// loggingMiddleware logs the start and end of each request, the end with
+// the client, the status, the body size and the duration
 func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		logger.Info("Request started",
+		log := requestLogger(r)
+		log.Info("Request started",
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.Path),
 		)
 
-		next(w, r)
+		rec := &statusRecorder{ResponseWriter: w}
+		next(rec, r)
 
 		duration := time.Since(start)
-		logger.Info("Request finished",
+		path := routeLabel(r)
+		requestDuration.WithLabelValues(r.Method, path).Observe(duration.Seconds())
+		requestsTotal.WithLabelValues(r.Method, path, rec.statusLabel()).Inc()
+		log.Info("Request finished",
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.Path),
+			zap.String("remote_ip", clientIP(r)),
+			zap.String("user_agent", r.UserAgent()),
+			zap.Int("status", rec.statusCode()),
+			zap.Int64("bytes", rec.bytes),
 			zap.Duration("duration", duration),
 		)
 	}
 }
 
 func main() {
-	var err error
-	logger, err = zap.NewProduction()
+	startTime = time.Now()
+
+	// Loaded before the logger, whose settings may come from the file
+	envErr := loadEnvFile(envFile)
+	applyEnvAliases()
+
+	config, err := parseFlags(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logger, logLevel, err = newLogger(getEnv(envLogFormat, logFormatJSON), config.logLevel)
 	if err != nil {
 		panic(err)
 	}
 	defer logger.Sync()
+	if envErr != nil {
+		logger.Warn("Ignoring unreadable env file", zap.String("path", envFile), zap.Error(envErr))
+	}
+
+	codeLength = parseCodeLength(os.Getenv(envCodeLength))
+
+	if raw := os.Getenv(envRedirectStatus); raw != "" {
+		redirectStatus, err = parseRedirectStatus(raw)
+		if err != nil {
+			logger.Fatal("Invalid SNIPLINK_REDIRECT_STATUS value", zap.Error(err))
+		}
+	}
+
+	if raw := os.Getenv(envMaxAliasLength); raw != "" {
+		maxAliasLength, err = parseMaxAliasLength(raw)
+		if err != nil {
+			logger.Fatal("Invalid SNIPLINK_MAX_ALIAS_LENGTH value", zap.Error(err))
+		}
+	}
+
+	deduplicate, err = strconv.ParseBool(getEnv(envDeduplicate, "false"))
+	if err != nil {
+		logger.Fatal("Invalid SNIPLINK_DEDUPLICATE value", zap.Error(err))
+	}
+
+	addr := config.addr
+	baseURL = normalizeBaseURL(getEnv(envBaseURL, defaultBaseURL))
 
-	http.HandleFunc("/shorten", loggingMiddleware(shortenHandler))
-	http.HandleFunc("/", loggingMiddleware(redirectHandler))
+	switch kind := getEnv(envStore, storeMemory); kind {
+	case storeMemory:
+		dataFile = config.dataFile
+		mappings, err := loadFromFile(dataFile)
+		if err != nil {
+			logger.Fatal("Failed to load URL mappings", zap.String("path", dataFile), zap.Error(err))
+		}
+		maxLinks, err := parseMaxLinks(os.Getenv(envMaxLinks))
+		if err != nil {
+			logger.Fatal("Invalid SNIPLINK_MAX_LINKS value", zap.Error(err))
+		}
+		memory := newURLStore()
+		memory.maxLinks = maxLinks
+		memory.onEvict = linkEvicted
+		// load may evict links that do not fit, mappings loses them too
+		memory.load(mappings)
+		store = memory
+		if err := saveToFile(dataFile, mappings); err != nil {
+			logger.Fatal("Failed to write URL mappings", zap.String("path", dataFile), zap.Error(err))
+		}
+		logger.Info("Loaded URL mappings", zap.String("path", dataFile), zap.Int("count", len(mappings)))
+	case storeSQLite:
+		// dataFile stays empty, the database already persists every write
+		path := getEnv(envSQLitePath, defaultSQLitePath)
+		sqliteStore, err := newSQLiteStore(path)
+		if err != nil {
+			logger.Fatal("Failed to open SQLite store", zap.String("path", path), zap.Error(err))
+		}
+		store = sqliteStore
+		logger.Info("Opened SQLite store", zap.String("path", path))
+	case storeRedis:
+		// dataFile stays empty, Redis is shared by every instance
+		redisStore, err := newRedisStore(getEnv(envRedisURL, defaultRedisURL))
+		if err != nil {
+			logger.Fatal("Failed to connect to Redis", zap.Error(err))
+		}
+		store = redisStore
+		logger.Info("Connected to Redis store")
+	case storeBolt:
+		// dataFile stays empty, the database already persists every write
+		path := getEnv(envBoltPath, defaultBoltPath)
+		boltStore, err := newBoltStore(path)
+		if err != nil {
+			logger.Fatal("Failed to open bolt store", zap.String("path", path), zap.Error(err))
+		}
+		store = boltStore
+		logger.Info("Opened bolt store", zap.String("path", path))
+	default:
+		logger.Fatal("Invalid SNIPLINK_STORE value", zap.String("value", kind))
+	}
+
+	codeAlphabet, err = parseCodeAlphabet(getEnv(envCodeAlphabet, alphabetDefault))
+	if err != nil {
+		logger.Fatal("Invalid SNIPLINK_CODE_ALPHABET value", zap.Error(err))
+	}
 
-	logger.Info("Server starting", zap.String("address", "http://localhost:8080"))
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	codeMode = getEnv(envCodeMode, codeModeRandom)
+	newShortCode, err = parseCodeMode(codeMode)
+	if err != nil {
+		logger.Fatal("Invalid SNIPLINK_CODE_MODE value", zap.Error(err))
+	}
+	if codeMode == codeModeSequential {
+		// Start past the stored codes so a restart does not walk through
+		// every code handed out before it
+		links, err := store.List()
+		if err != nil {
+			logger.Fatal("Failed to list stored links", zap.Error(err))
+		}
+		codeCounter.Store(lastSequentialCode(links))
+	}
+	// Derived codes take precedence over the code mode
+	hmacSecret = os.Getenv(envHMACSecret)
+
+	stopSweeper := startExpirySweeper(expirySweepInterval)
+
+	rps, burst, err := parseRateLimit(
+		getEnv(envRateLimitRPS, strconv.Itoa(defaultRateLimitRPS)),
+		getEnv(envRateLimitBurst, strconv.Itoa(defaultRateLimitBurst)),
+	)
+	if err != nil {
+		logger.Fatal("Invalid rate limit", zap.Error(err))
+	}
+
+	maxBodyBytes, err = parseMaxBodyBytes(getEnv(envMaxBodyBytes, strconv.Itoa(defaultMaxBodyBytes)))
+	if err != nil {
+		logger.Fatal("Invalid SNIPLINK_MAX_BODY_BYTES value", zap.Error(err))
+	}
+
+	apiKey = os.Getenv(envAPIKey)
+	if apiKey == "" {
+		logger.Warn("SNIPLINK_API_KEY is not set, write endpoints accept unauthenticated requests")
+	}
+
+	if raw := os.Getenv(envBlockedNetworks); raw != "" {
+		blockedNetworks, err = parseBlockedNetworks(raw)
+		if err != nil {
+			logger.Fatal("Invalid SNIPLINK_BLOCKED_NETWORKS value", zap.Error(err))
+		}
+	}
+
+	trustedProxies, err = parseNetworks(os.Getenv(envTrustedProxies))
+	if err != nil {
+		logger.Fatal("Invalid SNIPLINK_TRUSTED_PROXIES value", zap.Error(err))
+	}
+
+	corsOrigins := parseOrigins(getEnv(envCORSOrigins, "*"))
+
+	if path := os.Getenv(envFaviconFile); path != "" {
+		favicon, err = os.ReadFile(path)
+		if err != nil {
+			logger.Fatal("Failed to read SNIPLINK_FAVICON_FILE", zap.Error(err))
+		}
+	}
+
+	router := newRouter(rps, burst, corsOrigins)
+
+	shutdownTimeout, err := time.ParseDuration(getEnv(envShutdownTimeout, defaultShutdownTimeout.String()))
+	if err != nil {
+		logger.Fatal("Invalid shutdown timeout", zap.Error(err))
+	}
+	timeouts, err := parseServerTimeouts(os.Getenv(envReadTimeout), os.Getenv(envWriteTimeout), os.Getenv(envIdleTimeout))
+	if err != nil {
+		logger.Fatal("Invalid server timeout", zap.Error(err))
+	}
+	tlsConfig, err := loadTLSConfig(os.Getenv(envTLSCertFile), os.Getenv(envTLSKeyFile))
+	if err != nil {
+		logger.Fatal("Invalid TLS certificate", zap.Error(err))
+	}
+
+	stopDebug := func() {}
+	dbgAddr, err := debugAddr()
+	if err != nil {
+		logger.Fatal("Invalid SNIPLINK_DEBUG_PORT value", zap.Error(err))
+	}
+	if dbgAddr != "" {
+		dbgLn, err := net.Listen("tcp", dbgAddr)
+		if err != nil {
+			logger.Fatal("Debug server failed to start", zap.Error(err))
+		}
+		stopDebug = serveDebug(dbgLn)
+		logger.Info("Debug server starting", zap.String("address", dbgAddr))
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
 		logger.Fatal("Server failed to start", zap.Error(err))
 	}
+	srv := newServer(addr, NewChain(requestIDMiddleware).Then(router.ServeHTTP), timeouts)
+	srv.TLSConfig = tlsConfig
+	logger.Info("Server starting", zap.String("address", addr), zap.String("base_url", baseURL), zap.Bool("tls", tlsConfig != nil))
+	serveErr := serve(srv, ln, shutdownTimeout)
+
+	// In-flight requests are done by now, save the final state before exiting
+	stopDebug()
+	stopSweeper()
+	persist()
+	if closer, ok := store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Error("Failed to close store", zap.Error(err))
+		}
+	}
+	if serveErr != nil {
+		logger.Error("Server failed", zap.Error(serveErr))
+		logger.Sync()
+		os.Exit(1)
+	}
 }
 
+// getEnv returns the value of the environment variable key, or fallback
+// when it is unset or empty
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// shortenHandler creates a short link from a JSON body, or from the
+// original and short_code fields of a form encoded one. New links are
+// answered with 201 and reused ones with 200, both with the short URL in the
+// Location header. With dry_run=true the request is validated and answered
+// with 200 and the code it would get, without storing anything
 func shortenHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	shortenRequestsTotal.Inc()
+
+	var urlPair URLPair
+	if isFormBody(r) {
+		if apiErr := parseFormBody(w, r); apiErr != nil {
+			apiErr.write(w)
+			return
+		}
+		urlPair = URLPair{Original: r.FormValue("original"), ShortCode: r.FormValue("short_code")}
+	} else if apiErr := decodeJSONBody(w, r, &urlPair); apiErr != nil {
+		apiErr.write(w)
 		return
 	}
 
-	var urlPair URLPair
-	if err := json.NewDecoder(r.Body).Decode(&urlPair); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	dryRun, err := parseDryRun(r.URL.Query())
+	if err != nil {
+		errorResponse(w, err.Error(), errCodeInvalidQuery, http.StatusBadRequest)
 		return
 	}
 
-	shortCode := generateShortCode()
-	urlMap[shortCode] = urlPair.Original
+	link, apiErr := createShortLink(r.Context(), urlPair, dryRun)
+	if apiErr != nil {
+		apiErr.write(w)
+		return
+	}
+	if !dryRun {
+		auditShorten(r, urlPair.Original, link)
+	}
+	if link.created {
+		persist()
+	}
 
 	response := map[string]string{
-		"short_code": shortCode,
-		"short_url":  "http://localhost:8080/" + shortCode,
+		"short_code": link.shortCode,
+		"short_url":  shortURL(link.shortCode),
+	}
+	if !link.expiresAt.IsZero() {
+		response["expires_at"] = link.expiresAt.Format(time.RFC3339)
+	}
+	// Links stored before creation times were recorded have none
+	if !link.createdAt.IsZero() {
+		response["created_at"] = link.createdAt.Format(time.RFC3339)
 	}
 
+	status := http.StatusOK
+	if link.created {
+		status = http.StatusCreated
+	}
 	w.Header().Set("Content-Type", "application/json")
+	// A dry run creates nothing to point at
+	if !dryRun {
+		w.Header().Set("Location", response["short_url"])
+	}
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(response)
 }
 
+// normalizeBaseURL trims trailing slashes so that shortURL never produces a
+// double slash, "https://snip.link/" and "https://snip.link" are equivalent
+func normalizeBaseURL(raw string) string {
+	return strings.TrimRight(raw, "/")
+}
+
+// shortURL builds the public URL for a short code, a namespaced code maps to
+// /{namespace}/{code}
+func shortURL(shortCode string) string {
+	return baseURL + "/" + strings.Replace(shortCode, ":", "/", 1)
+}
+
+// codeCandidates yields the short codes tried for a generated link to
+// original in namespace. With an hmacSecret the code derived from the URL
+// comes first, random codes only stand in when another link already holds
+// it. Up to maxCodeRetries codes are generated after it, reserved codes are
+// skipped like collisions since sequential codes reach the reserved words
+type codeCandidates struct {
+	namespace string
+	original  string
+	hmacTried bool
+	attempts  int
+}
+
+// next returns the next code to try, or false once every attempt is used
+func (c *codeCandidates) next() (string, bool, error) {
+	if !c.hmacTried && hmacSecret != "" {
+		c.hmacTried = true
+		if code := generateHMACCode(hmacSecret, c.original); !reservedCode(code) {
+			return namespacedCode(c.namespace, code), true, nil
+		}
+	}
+	for c.attempts < maxCodeRetries {
+		c.attempts++
+		generated, err := newShortCode(codeLength)
+		if err != nil {
+			return "", false, err
+		}
+		if !reservedCode(generated) {
+			return namespacedCode(c.namespace, generated), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// storeWithGeneratedCode stores record under a freshly generated short code
+// in namespace, trying the codeCandidates in turn. It returns errNoUniqueCode
+// when every attempt collided
+func storeWithGeneratedCode(namespace string, record URLRecord) (string, error) {
+	candidates := codeCandidates{namespace: namespace, original: record.Original}
+	for {
+		shortCode, ok, err := candidates.next()
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", errNoUniqueCode
+		}
+		stored, err := store.SetIfAbsent(shortCode, record)
+		if err != nil {
+			return "", err
+		}
+		if stored {
+			return shortCode, nil
+		}
+	}
+}
+
+// deleteHandler removes the short link named by the path, it serves both
+// DELETE /{code} and DELETE /shorten/{code}, with or without a namespace
+func deleteHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := shortCodeParam(r)
+
+	deleted, err := store.Delete(shortCode)
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+	if !deleted {
+		errorResponse(w, "Short code not found", errCodeNotFound, http.StatusNotFound)
+		return
+	}
+	clickEvents.forget(shortCode)
+	webhooks.forget(shortCode)
+	persist()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// persist saves the current URL mappings to dataFile when persistence is
+// enabled. Failures are logged, the mapping stays available in memory.
+// The snapshot is taken while holding persistMu so that concurrent saves
+// cannot replace a newer snapshot with an older one
+func persist() {
+	if dataFile == "" {
+		return
+	}
+	persistMu.Lock()
+	defer persistMu.Unlock()
+	mappings, err := store.List()
+	if err != nil {
+		logger.Error("Failed to read URL mappings", zap.Error(err))
+		return
+	}
+	if err := saveToFile(dataFile, mappings); err != nil {
+		logger.Error("Failed to persist URL mappings", zap.String("path", dataFile), zap.Error(err))
+	}
+}
+
+// redirectHandler follows the short link named by /{code} or
+// /{namespace}/{code}. Codes are case-sensitive: generated codes mix both
+// cases, so folding them would send aB3xyZ and ab3xyz to the same link.
+// Clients that prefer application/json get the destination as JSON instead
+// of a redirect, still counted as a click
 func redirectHandler(w http.ResponseWriter, r *http.Request) {
-	shortCode := r.URL.Path[1:]
+	shortCode := shortCodeParam(r)
 
-	originalURL, exists := urlMap[shortCode]
+	bot := isBot(r.UserAgent())
+	record, exists, err := store.Resolve(shortCode, time.Now(), bot)
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+	if !exists || record.expired(time.Now()) {
+		redirectsTotal.WithLabelValues("miss").Inc()
+	} else {
+		redirectsTotal.WithLabelValues("hit").Inc()
+	}
 	if !exists {
-		http.Error(w, "Short code not found", http.StatusNotFound)
+		errorResponse(w, "Short code not found", errCodeNotFound, http.StatusNotFound)
+		return
+	}
+	if record.expired(time.Now()) {
+		errorResponse(w, "Short link has expired", errCodeGone, http.StatusGone)
+		return
+	}
+
+	clickedAt := time.Now()
+	clickEvents.record(shortCode, ClickEvent{Time: clickedAt, Referrer: r.Referer(), Bot: bot})
+	notifyWebhooks(shortCode, record.Original, clickedAt)
+	// Caches must not serve the JSON answer to browsers or the other way round
+	w.Header().Add("Vary", "Accept")
+	if prefersJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(previewResponse{
+			ShortCode:   shortCode,
+			OriginalURL: record.Original,
+			CreatedAt:   record.CreatedAt,
+		})
 		return
 	}
+	status := redirectStatus
+	if record.Permanent {
+		status = http.StatusMovedPermanently
+	}
+	http.Redirect(w, r, record.Original, status)
+}
+
+// parseRedirectStatus parses a redirect status setting, only the redirect
+// codes browsers follow for GET requests are accepted: 301, 302, 307 and 308
+func parseRedirectStatus(raw string) (int, error) {
+	status, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("redirect status %q is not a number", raw)
+	}
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return status, nil
+	}
+	return 0, fmt.Errorf("redirect status %d is not one of 301, 302, 307 or 308", status)
+}
+
+// parseCodeLength parses a short code length setting, falling back to
+// defaultCodeLength when raw is empty, not a number or outside the
+// [minCodeLength, maxCodeLength] range
+func parseCodeLength(raw string) int {
+	length, err := strconv.Atoi(raw)
+	if err != nil || length < minCodeLength || length > maxCodeLength {
+		return defaultCodeLength
+	}
+	return length
+}
 
-	http.Redirect(w, r, originalURL, http.StatusTemporaryRedirect)
+// parseMaxLinks parses the link limit of the memory store, an empty value
+// or zero leaves it unbounded
+func parseMaxLinks(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("link limit %q is not a non-negative number", raw)
+	}
+	return n, nil
+}
+
+// linkEvicted forgets what the handlers keep about a link the memory store
+// evicted
+func linkEvicted(shortCode string) {
+	evictedLinksTotal.Inc()
+	clickEvents.forget(shortCode)
+	webhooks.forget(shortCode)
 }
 
 // generateShortCode generates a random short code for the URL
-// it uses a combination of lowercase and uppercase letters and numbers
-// and returns a 6 character string
-func generateShortCode() string {
-	chars := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	shortCode := make([]byte, 6)
-	for i := range shortCode {
-		shortCode[i] = chars[rand.Intn(len(chars))]
-	}
-	return string(shortCode)
-}
\ No newline at end of file
+// it uses the characters of codeAlphabet, letters and numbers by default,
+// and returns a string of the given length. Randomness comes from crypto/rand
+// and bytes that would skew the distribution towards the first characters of
+// the alphabet are discarded, so every character is equally likely.
+//
+// The length trades readability for collision space: there are 62^length
+// possible codes with the default alphabet (57^length without ambiguous
+// characters), and by the birthday bound a collision becomes likely after
+// roughly sqrt(62^length) links. That is about 240 thousand links at the
+// default length of 6, 4 thousand at 4 and 900 million at 10, so busy
+// deployments should raise SNIPLINK_CODE_LENGTH while internal tools can
+// lower it. Collisions are retried by shortenHandler either way.
+// An error is returned if the system entropy source is unavailable
+func generateShortCode(length int) (string, error) {
+	chars := codeAlphabet
+	maxUnbiased := 256 - 256%len(chars)
+
+	shortCode := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(shortCode) < cap(shortCode) {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= maxUnbiased || len(shortCode) == cap(shortCode) {
+				continue
+			}
+			shortCode = append(shortCode, chars[int(b)%len(chars)])
+		}
+	}
+	return string(shortCode), nil
+}
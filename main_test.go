@@ -3,22 +3,34 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/Kairum-Labs/should"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestGenerateShortCode(t *testing.T) {
 	t.Run("should generate 6 character code", func(t *testing.T) {
-		code := generateShortCode()
+		code, err := generateShortCode(6)
+		should.BeNil(t, err)
 		should.BeEqual(t, len(code), 6, should.WithMessage("Short code should be exactly 6 characters"))
 	})
 
 	t.Run("should generate alphanumeric characters", func(t *testing.T) {
-		code := generateShortCode()
+		code, err := generateShortCode(6)
+		should.BeNil(t, err)
 		validChars := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 		
 		for _, char := range code {
@@ -27,14 +39,95 @@ func TestGenerateShortCode(t *testing.T) {
 	})
 
 	t.Run("should generate different codes on multiple calls", func(t *testing.T) {
-		code1 := generateShortCode()
-		code2 := generateShortCode()
-		code3 := generateShortCode()
+		code1, _ := generateShortCode(6)
+		code2, _ := generateShortCode(6)
+		code3, _ := generateShortCode(6)
 		
 		should.NotBeEqual(t, code1, code2, should.WithMessage("Consecutive codes should be different"))
 		should.NotBeEqual(t, code2, code3, should.WithMessage("Consecutive codes should be different"))
 		should.NotBeEqual(t, code1, code3, should.WithMessage("Non-consecutive codes should be different"))
 	})
+
+	t.Run("should not repeat codes across 10000 generations", func(t *testing.T) {
+		seen := make(map[string]bool, 10000)
+		for i := 0; i < 10000; i++ {
+			code, err := generateShortCode(6)
+			should.BeNil(t, err)
+			should.BeFalse(t, seen[code], should.WithMessage("Generated codes should not repeat"))
+			seen[code] = true
+		}
+	})
+}
+
+func TestGenerateShortCodeLength(t *testing.T) {
+	for _, length := range []int{4, 6, 10, 32} {
+		t.Run(fmt.Sprintf("should generate %d character code", length), func(t *testing.T) {
+			code, err := generateShortCode(length)
+			should.BeNil(t, err)
+			should.BeEqual(t, len(code), length)
+		})
+	}
+}
+
+func TestParseCodeLength(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want int
+	}{
+		{name: "unset", raw: "", want: defaultCodeLength},
+		{name: "minimum", raw: "4", want: 4},
+		{name: "custom", raw: "10", want: 10},
+		{name: "maximum", raw: "32", want: 32},
+		{name: "zero", raw: "0", want: defaultCodeLength},
+		{name: "negative", raw: "-8", want: defaultCodeLength},
+		{name: "below minimum", raw: "3", want: defaultCodeLength},
+		{name: "above maximum", raw: "33", want: defaultCodeLength},
+		{name: "non-numeric", raw: "six", want: defaultCodeLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			should.BeEqual(t, parseCodeLength(tt.raw), tt.want)
+		})
+	}
+}
+
+func TestGetEnv(t *testing.T) {
+	t.Run("should return the environment value when set", func(t *testing.T) {
+		t.Setenv("SNIPLINK_BASE_URL", "https://snip.link")
+		should.BeEqual(t, getEnv("SNIPLINK_BASE_URL", defaultBaseURL), "https://snip.link")
+	})
+
+	t.Run("should return the fallback when unset or empty", func(t *testing.T) {
+		t.Setenv("SNIPLINK_ADDR", "")
+		should.BeEqual(t, getEnv("SNIPLINK_ADDR", defaultAddr), defaultAddr)
+	})
+}
+
+func TestNormalizeBaseURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{raw: "http://localhost:8080", want: "http://localhost:8080"},
+		{raw: "https://snip.link/", want: "https://snip.link"},
+		{raw: "https://example.com/s//", want: "https://example.com/s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			should.BeEqual(t, normalizeBaseURL(tt.raw), tt.want)
+		})
+	}
+
+	t.Run("should build short URLs from SNIPLINK_BASE_URL", func(t *testing.T) {
+		t.Setenv("SNIPLINK_BASE_URL", "https://snip.link/")
+		baseURL = normalizeBaseURL(getEnv("SNIPLINK_BASE_URL", defaultBaseURL))
+		defer func() { baseURL = defaultBaseURL }()
+
+		should.BeEqual(t, shortURL("abc123"), "https://snip.link/abc123")
+	})
 }
 
 func TestShortenHandler(t *testing.T) {
@@ -42,10 +135,10 @@ func TestShortenHandler(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/shorten", nil)
 		w := httptest.NewRecorder()
 		
-		shortenHandler(w, req)
+		route(w, req)
 		
 		should.BeEqual(t, w.Code, http.StatusMethodNotAllowed, should.WithMessage("Should return 405 for non-POST requests"))
-		should.BeEqual(t, strings.TrimSpace(w.Body.String()), "Method not allowed")
+		should.BeEqual(t, decodeError(t, w).Error, "Method not allowed")
 	})
 
 	t.Run("should return bad request for invalid JSON", func(t *testing.T) {
@@ -55,12 +148,60 @@ func TestShortenHandler(t *testing.T) {
 		shortenHandler(w, req)
 		
 		should.BeEqual(t, w.Code, http.StatusBadRequest, should.WithMessage("Should return 400 for invalid JSON"))
-		should.BeEqual(t, strings.TrimSpace(w.Body.String()), "Invalid request body")
+		should.BeEqual(t, decodeError(t, w).Error, "Invalid request body")
+	})
+
+	t.Run("should return bad request when the original URL is missing", func(t *testing.T) {
+		resetStore()
+
+		for _, body := range []string{`{}`, `{"short_code":"abc123"}`, `{"original":""}`} {
+			w := httptest.NewRecorder()
+			shortenHandler(w, httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(body)))
+
+			should.BeEqual(t, w.Code, http.StatusBadRequest, should.WithMessage("Should reject "+body))
+			apiErr := decodeError(t, w)
+			should.BeEqual(t, apiErr.Error, "original URL is required")
+			should.BeEqual(t, apiErr.Code, errCodeInvalidBody)
+		}
+		should.BeEmpty(t, storedLinks(t), should.WithMessage("Links without a URL should not be stored"))
+	})
+
+	t.Run("should return bad request for invalid URL", func(t *testing.T) {
+		resetStore()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "javascript:alert(1)"})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusBadRequest, should.WithMessage("Should return 400 for invalid URL"))
+		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json")
+
+		var response map[string]string
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeNil(t, err, should.WithMessage("Error response should be valid JSON"))
+		should.BeEqual(t, response["error"], errURLInvalidScheme.Error())
+	})
+
+	t.Run("should not store URLs that fail validation", func(t *testing.T) {
+		resetStore()
+
+		for _, original := range []string{"   ", "not a url", "javascript:alert(1)", "http://localhost:8080/abc123"} {
+			jsonData, _ := json.Marshal(URLPair{Original: original})
+			req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+			w := httptest.NewRecorder()
+
+			shortenHandler(w, req)
+
+			should.BeEqual(t, w.Code, http.StatusBadRequest, should.WithMessage("Should reject "+original))
+		}
+		should.BeEmpty(t, storedLinks(t), should.WithMessage("Rejected URLs should not be stored"))
 	})
 
 	t.Run("should create short URL successfully", func(t *testing.T) {
-		// Clear the urlMap for clean test
-		urlMap = make(map[string]string)
+		// Clear the store for clean test
+		resetStore()
 		
 		urlPair := URLPair{Original: "https://example.com/very/long/url"}
 		jsonData, _ := json.Marshal(urlPair)
@@ -70,7 +211,7 @@ func TestShortenHandler(t *testing.T) {
 		
 		shortenHandler(w, req)
 		
-		should.BeEqual(t, w.Code, http.StatusOK, should.WithMessage("Should return 200 for successful creation"))
+		should.BeEqual(t, w.Code, http.StatusCreated, should.WithMessage("Should return 201 for successful creation"))
 		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json", should.WithMessage("Should set correct content type"))
 		
 		var response map[string]string
@@ -82,11 +223,208 @@ func TestShortenHandler(t *testing.T) {
 		should.BeEqual(t, len(response["short_code"]), 6, should.WithMessage("Short code should be 6 characters"))
 		should.StartWith(t, response["short_url"], "http://localhost:8080/", should.WithMessage("Short URL should start with localhost"))
 		should.EndWith(t, response["short_url"], response["short_code"], should.WithMessage("Short URL should end with short code"))
+		should.BeEqual(t, w.Header().Get("Location"), response["short_url"], should.WithMessage("Location should be the short URL"))
+	})
+
+	t.Run("should report when the link was created", func(t *testing.T) {
+		resetStore()
+		before := time.Now().Truncate(time.Second)
+
+		req := httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(`{"original":"https://example.com"}`))
+		w := httptest.NewRecorder()
+		shortenHandler(w, req)
+
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		createdAt, err := time.Parse(time.RFC3339, response["created_at"])
+		should.BeNil(t, err, should.WithMessage("created_at should be RFC3339"))
+		should.BeFalse(t, createdAt.Before(before), should.WithMessage("created_at should be the time of the request"))
+
+		record, _, _ := store.Get(response["short_code"])
+		should.BeEqual(t, record.CreatedAt.Format(time.RFC3339), response["created_at"])
+	})
+
+	t.Run("should store links under the code of the generator", func(t *testing.T) {
+		resetStore()
+		newShortCode = func(length int) (string, error) { return "stub" + strconv.Itoa(length), nil }
+		defer func() { newShortCode = generateShortCode }()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com/stub"})
+		w := httptest.NewRecorder()
+		shortenHandler(w, httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData)))
+
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeEqual(t, response["short_code"], "stub"+strconv.Itoa(codeLength))
+		created, _, _ := store.Get(response["short_code"])
+		should.BeEqual(t, created.Original, "https://example.com/stub")
+	})
+
+	t.Run("should retry when the generated code collides", func(t *testing.T) {
+		resetStore()
+		store.Set("taken1", URLRecord{Original: "https://example.com/existing"})
+
+		codes := []string{"taken1", "taken1", "fresh1"}
+		newShortCode = func(int) (string, error) {
+			code := codes[0]
+			codes = codes[1:]
+			return code, nil
+		}
+		defer func() { newShortCode = generateShortCode }()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com/new"})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusCreated, should.WithMessage("Should succeed after regenerating the code"))
+		existing, _, _ := store.Get("taken1")
+		should.BeEqual(t, existing.Original, "https://example.com/existing", should.WithMessage("Existing link should not be overwritten"))
+		created, _, _ := store.Get("fresh1")
+		should.BeEqual(t, created.Original, "https://example.com/new")
+	})
+
+	t.Run("should skip generated codes shadowed by other routes", func(t *testing.T) {
+		resetStore()
+
+		codes := []string{"qr", "stats", "fresh1"}
+		newShortCode = func(int) (string, error) {
+			code := codes[0]
+			codes = codes[1:]
+			return code, nil
+		}
+		defer func() { newShortCode = generateShortCode }()
+
+		shortCode, err := storeWithGeneratedCode("", URLRecord{Original: "https://example.com"})
+
+		should.BeNil(t, err)
+		should.BeEqual(t, shortCode, "fresh1")
+	})
+
+	t.Run("should fail when every retry collides", func(t *testing.T) {
+		resetStore()
+		store.Set("taken1", URLRecord{Original: "https://example.com/existing"})
+
+		attempts := 0
+		newShortCode = func(int) (string, error) {
+			attempts++
+			return "taken1", nil
+		}
+		defer func() { newShortCode = generateShortCode }()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com/new"})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusInternalServerError, should.WithMessage("Should return 500 once retries are exhausted"))
+		should.BeEqual(t, attempts, maxCodeRetries)
+		existing, _, _ := store.Get("taken1")
+		should.BeEqual(t, existing.Original, "https://example.com/existing", should.WithMessage("Existing link should not be overwritten"))
+	})
+
+	t.Run("should use the requested alias", func(t *testing.T) {
+		resetStore()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com", ShortCode: "go-blog"})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusCreated, should.WithMessage("Should accept a free alias"))
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeEqual(t, response["short_code"], "go-blog")
+		should.BeEqual(t, response["short_url"], "http://localhost:8080/go-blog")
+		record, _, _ := store.Get("go-blog")
+		should.BeEqual(t, record.Original, "https://example.com")
+	})
+
+	t.Run("should return conflict when the alias is taken", func(t *testing.T) {
+		resetStore()
+		store.Set("docs", URLRecord{Original: "https://example.com/existing"})
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com/new", ShortCode: "docs"})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusConflict, should.WithMessage("Should return 409 for a taken alias"))
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeEqual(t, response["error"], "short code already in use")
+		record, _, _ := store.Get("docs")
+		should.BeEqual(t, record.Original, "https://example.com/existing", should.WithMessage("Existing link should not be overwritten"))
+	})
+
+	t.Run("should return bad request for an alias with invalid characters", func(t *testing.T) {
+		resetStore()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com", ShortCode: "my docs"})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusBadRequest, should.WithMessage("Should return 400 for an invalid alias"))
+		should.BeEmpty(t, storedLinks(t), should.WithMessage("Invalid alias should not be stored"))
+	})
+
+	t.Run("should build short URL from the configured base URL", func(t *testing.T) {
+		resetStore()
+		baseURL = "https://snip.link"
+		defer func() { baseURL = defaultBaseURL }()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com"})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeEqual(t, response["short_url"], "https://snip.link/"+response["short_code"])
+	})
+
+	t.Run("should return bad request for an alias that is too short", func(t *testing.T) {
+		resetStore()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com", ShortCode: "ab"})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusBadRequest, should.WithMessage("Should return 400 for a short alias"))
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeEqual(t, response["error"], "short code length is out of range, it must be 3 to 32 characters")
+	})
+
+	t.Run("should return internal server error when entropy is unavailable", func(t *testing.T) {
+		resetStore()
+		newShortCode = func(int) (string, error) {
+			return "", errors.New("entropy unavailable")
+		}
+		defer func() { newShortCode = generateShortCode }()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com"})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusInternalServerError, should.WithMessage("Should return 500 when no code can be generated"))
+		should.BeEmpty(t, storedLinks(t))
 	})
 
 	t.Run("should store URL in map", func(t *testing.T) {
-		// Clear the urlMap for clean test
-		urlMap = make(map[string]string)
+		// Clear the store for clean test
+		resetStore()
 		
 		originalURL := "https://google.com"
 		urlPair := URLPair{Original: originalURL}
@@ -101,8 +439,217 @@ func TestShortenHandler(t *testing.T) {
 		json.Unmarshal(w.Body.Bytes(), &response)
 		
 		shortCode := response["short_code"]
-		should.ContainKey(t, urlMap, shortCode, should.WithMessage("URL should be stored in map"))
-		should.BeEqual(t, urlMap[shortCode], originalURL, should.WithMessage("Stored URL should match original"))
+		stored, exists, _ := store.Get(shortCode)
+		should.BeTrue(t, exists, should.WithMessage("URL should be stored in map"))
+		should.BeEqual(t, stored.Original, originalURL, should.WithMessage("Stored URL should match original"))
+	})
+}
+
+func TestShortenHandlerForm(t *testing.T) {
+	postForm := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+		w := httptest.NewRecorder()
+		route(w, req)
+		return w
+	}
+
+	t.Run("should create a link from form fields", func(t *testing.T) {
+		resetStore()
+
+		w := postForm(url.Values{"original": {"https://example.com/form"}, "short_code": {"my-form"}, "submit": {"Shorten"}})
+
+		should.BeEqual(t, w.Code, http.StatusCreated)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json")
+		var response map[string]string
+		should.BeNil(t, json.Unmarshal(w.Body.Bytes(), &response))
+		should.BeEqual(t, response["short_code"], "my-form")
+		stored, exists, _ := store.Get("my-form")
+		should.BeTrue(t, exists)
+		should.BeEqual(t, stored.Original, "https://example.com/form")
+	})
+
+	t.Run("should validate the form like a JSON body", func(t *testing.T) {
+		resetStore()
+
+		w := postForm(url.Values{"original": {"javascript:alert(1)"}})
+
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+		should.BeEmpty(t, storedLinks(t))
+	})
+
+	t.Run("should reject forms over the body limit", func(t *testing.T) {
+		resetStore()
+		maxBodyBytes = 64
+		defer func() { maxBodyBytes = defaultMaxBodyBytes }()
+
+		req := httptest.NewRequest(http.MethodPost, "/shorten",
+			strings.NewReader("original=https://example.com/"+strings.Repeat("a", 64)))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		// An unknown length gets past the Content-Length check of the middleware
+		req.ContentLength = -1
+		w := httptest.NewRecorder()
+		shortenHandler(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusRequestEntityTooLarge)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeBodyTooLarge)
+		should.BeEmpty(t, storedLinks(t))
+	})
+}
+
+func TestShortenHandlerDeduplication(t *testing.T) {
+	shorten := func(urlPair URLPair) map[string]string {
+		jsonData, _ := json.Marshal(urlPair)
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+		shortenHandler(w, req)
+
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response
+	}
+
+	t.Run("should return the existing code when enabled", func(t *testing.T) {
+		resetStore()
+		deduplicate = true
+		defer func() { deduplicate = false }()
+
+		first := shorten(URLPair{Original: "https://example.com"})
+		second := shorten(URLPair{Original: "https://example.com"})
+
+		should.BeEqual(t, second["short_code"], first["short_code"], should.WithMessage("Same URL should map to the same code"))
+		should.BeEqual(t, second["created_at"], first["created_at"], should.WithMessage("A reused link keeps its creation time"))
+		should.HaveLength(t, storedLinks(t), 1, should.WithMessage("No second entry should be stored"))
+	})
+
+	t.Run("should answer 201 for the new link and 200 for the reused one", func(t *testing.T) {
+		resetStore()
+		deduplicate = true
+		defer func() { deduplicate = false }()
+
+		status := func() int {
+			jsonData, _ := json.Marshal(URLPair{Original: "https://example.com"})
+			w := httptest.NewRecorder()
+			shortenHandler(w, httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData)))
+			return w.Code
+		}
+
+		should.BeEqual(t, status(), http.StatusCreated)
+		should.BeEqual(t, status(), http.StatusOK)
+	})
+
+	t.Run("should create distinct codes when disabled", func(t *testing.T) {
+		resetStore()
+
+		first := shorten(URLPair{Original: "https://example.com"})
+		second := shorten(URLPair{Original: "https://example.com"})
+
+		should.NotBeEqual(t, second["short_code"], first["short_code"])
+		should.HaveLength(t, storedLinks(t), 2)
+	})
+
+	t.Run("should honor aliases even when the URL was shortened before", func(t *testing.T) {
+		resetStore()
+		deduplicate = true
+		defer func() { deduplicate = false }()
+
+		shorten(URLPair{Original: "https://example.com"})
+		aliased := shorten(URLPair{Original: "https://example.com", ShortCode: "docs"})
+
+		should.BeEqual(t, aliased["short_code"], "docs")
+	})
+
+	t.Run("should store one link for concurrent requests", func(t *testing.T) {
+		// A slow lookup lets every request miss the link unless they are serialized
+		store = slowLookupStore{newURLStore()}
+		defer resetStore()
+		deduplicate = true
+		defer func() { deduplicate = false }()
+
+		codes := make([]string, 20)
+		var wg sync.WaitGroup
+		for i := range codes {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				codes[i] = shorten(URLPair{Original: "https://example.com"})["short_code"]
+			}(i)
+		}
+		wg.Wait()
+
+		should.HaveLength(t, storedLinks(t), 1, should.WithMessage("Racing requests should not create duplicates"))
+		for _, code := range codes {
+			should.BeEqual(t, code, codes[0])
+		}
+	})
+
+	t.Run("should give permanent links a code of their own", func(t *testing.T) {
+		resetStore()
+		deduplicate = true
+		defer func() { deduplicate = false }()
+
+		first := shorten(URLPair{Original: "https://example.com"})
+		permanent := shorten(URLPair{Original: "https://example.com", Permanent: true})
+
+		should.NotBeEqual(t, permanent["short_code"], first["short_code"], should.WithMessage("A permanent link should not reuse a temporary one"))
+		should.BeTrue(t, storedLinks(t)[permanent["short_code"]].Permanent)
+	})
+}
+
+// slowLookupStore is a URLStore whose LookupOriginal takes a while to
+// return its answer
+type slowLookupStore struct {
+	URLStore
+}
+
+func (s slowLookupStore) LookupOriginal(originalURL string, now time.Time) (string, bool, error) {
+	shortCode, exists, err := s.URLStore.LookupOriginal(originalURL, now)
+	time.Sleep(5 * time.Millisecond)
+	return shortCode, exists, err
+}
+
+func TestShortenHandlerPersistence(t *testing.T) {
+	t.Run("should persist new mappings to the data file", func(t *testing.T) {
+		resetStore()
+		dataFile = filepath.Join(t.TempDir(), "urls.json")
+		defer func() { dataFile = "" }()
+
+		jsonData, _ := json.Marshal(URLPair{Original: "https://example.com/persisted"})
+		req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+
+		shortenHandler(w, req)
+
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		saved, err := loadFromFile(dataFile)
+		should.BeNil(t, err, should.WithMessage("Data file should be readable"))
+		should.BeEqual(t, saved[response["short_code"]].Original, "https://example.com/persisted", should.WithMessage("New mapping should be saved"))
+	})
+}
+
+func TestConcurrentPersistence(t *testing.T) {
+	t.Run("should keep every mapping when shortening concurrently", func(t *testing.T) {
+		resetStore()
+		dataFile = filepath.Join(t.TempDir(), "urls.json")
+		defer func() { dataFile = "" }()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				jsonData, _ := json.Marshal(URLPair{Original: fmt.Sprintf("https://example.com/%d", i)})
+				req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+				shortenHandler(httptest.NewRecorder(), req)
+			}(i)
+		}
+		wg.Wait()
+
+		saved, err := loadFromFile(dataFile)
+		should.BeNil(t, err, should.WithMessage("Data file should be readable"))
+		should.HaveLength(t, saved, 50, should.WithMessage("Every mapping should reach the data file"))
 	})
 }
 
@@ -111,41 +658,259 @@ func TestRedirectHandler(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/nonexistent", nil)
 		w := httptest.NewRecorder()
 		
-		redirectHandler(w, req)
+		route(w, req)
 		
 		should.BeEqual(t, w.Code, http.StatusNotFound, should.WithMessage("Should return 404 for non-existent code"))
-		should.BeEqual(t, strings.TrimSpace(w.Body.String()), "Short code not found")
+		should.BeEqual(t, decodeError(t, w).Error, "Short code not found")
 	})
 
 	t.Run("should redirect to original URL for valid short code", func(t *testing.T) {
-		// Clear and populate urlMap for test
-		urlMap = make(map[string]string)
+		// Clear and populate the store for test
+		resetStore()
 		shortCode := "abc123"
 		originalURL := "https://example.com"
-		urlMap[shortCode] = originalURL
+		store.Set(shortCode, URLRecord{Original: originalURL})
 		
 		req := httptest.NewRequest(http.MethodGet, "/"+shortCode, nil)
 		w := httptest.NewRecorder()
 		
-		redirectHandler(w, req)
+		route(w, req)
 		
 		should.BeEqual(t, w.Code, http.StatusTemporaryRedirect, should.WithMessage("Should return 307 for redirect"))
 		should.BeEqual(t, w.Header().Get("Location"), originalURL, should.WithMessage("Should redirect to original URL"))
 	})
 
+	t.Run("should ignore a trailing slash", func(t *testing.T) {
+		resetStore()
+		store.Set("aB3xyZ", URLRecord{Original: "https://example.com"})
+		store.Set("team:aB3xyZ", URLRecord{Original: "https://example.org"})
+
+		for path, want := range map[string]string{"/aB3xyZ/": "https://example.com", "/team/aB3xyZ/": "https://example.org"} {
+			w := httptest.NewRecorder()
+			route(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+			should.BeEqual(t, w.Code, http.StatusTemporaryRedirect, should.WithMessage("Path "+path))
+			should.BeEqual(t, w.Header().Get("Location"), want)
+		}
+	})
+
+	t.Run("should treat codes as case-sensitive", func(t *testing.T) {
+		resetStore()
+		store.Set("aB3xyZ", URLRecord{Original: "https://example.com"})
+
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/ab3xyz", nil))
+
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+	})
+
+	t.Run("should use the configured redirect status", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		redirectStatus = http.StatusMovedPermanently
+		defer func() { redirectStatus = http.StatusTemporaryRedirect }()
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		w := httptest.NewRecorder()
+
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusMovedPermanently, should.WithMessage("Should return the configured status"))
+		should.BeEqual(t, w.Header().Get("Location"), "https://example.com")
+	})
+
+	t.Run("should redirect permanent links with 301", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com", Permanent: true})
+
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/abc123", nil))
+
+		should.BeEqual(t, w.Code, http.StatusMovedPermanently, should.WithMessage("A permanent link should override the default status"))
+		should.BeEqual(t, w.Header().Get("Location"), "https://example.com")
+	})
+
+	t.Run("should answer with JSON when the client prefers it", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json")
+		should.BeEqual(t, w.Header().Get("Vary"), "Accept")
+		should.BeEmpty(t, w.Header().Get("Location"))
+		var response previewResponse
+		should.BeNil(t, json.Unmarshal(w.Body.Bytes(), &response))
+		should.BeEqual(t, response.ShortCode, "abc123")
+		should.BeEqual(t, response.OriginalURL, "https://example.com")
+
+		_, clicks, _, _ := store.Stats("abc123")
+		should.BeEqual(t, clicks.Total, uint64(1), should.WithMessage("A JSON answer should count as a click"))
+	})
+
+	t.Run("should redirect browsers asking for HTML", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		w := httptest.NewRecorder()
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusTemporaryRedirect)
+		should.BeEqual(t, w.Header().Get("Location"), "https://example.com")
+		should.BeEqual(t, w.Header().Get("Vary"), "Accept")
+	})
+
 	t.Run("should handle root path correctly", func(t *testing.T) {
-		// Clear and populate urlMap for test
-		urlMap = make(map[string]string)
+		// Clear and populate the store for test
+		resetStore()
 		shortCode := "xyz789"
 		originalURL := "https://google.com"
-		urlMap[shortCode] = originalURL
+		store.Set(shortCode, URLRecord{Original: originalURL})
 		
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		w := httptest.NewRecorder()
 		
-		redirectHandler(w, req)
+		route(w, req)
 		
-		should.BeEqual(t, w.Code, http.StatusNotFound, should.WithMessage("Root path should return 404"))
+		should.BeEqual(t, w.Code, http.StatusOK, should.WithMessage("Root path should serve the landing page"))
+		should.BeEmpty(t, w.Header().Get("Location"))
+	})
+}
+
+func TestDeleteHandler(t *testing.T) {
+	t.Run("should delete an existing short code", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		req := httptest.NewRequest(http.MethodDelete, "/shorten/abc123", nil)
+		w := httptest.NewRecorder()
+
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusNoContent, should.WithMessage("Should return 204 after deleting"))
+		should.BeEmpty(t, w.Body.String())
+		_, exists, _ := store.Get("abc123")
+		should.BeFalse(t, exists, should.WithMessage("Deleted code should be removed from the store"))
+	})
+
+	t.Run("should return not found for non-existent short code", func(t *testing.T) {
+		resetStore()
+
+		req := httptest.NewRequest(http.MethodDelete, "/shorten/missing", nil)
+		w := httptest.NewRecorder()
+
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusNotFound, should.WithMessage("Should return 404 for non-existent code"))
+		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json")
+
+		var response map[string]string
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeNil(t, err, should.WithMessage("Error response should be valid JSON"))
+		should.BeEqual(t, response["error"], "Short code not found")
+	})
+
+	t.Run("should delete through DELETE /{code}", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		req := httptest.NewRequest(http.MethodDelete, "/abc123", nil)
+		w := httptest.NewRecorder()
+
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusNoContent, should.WithMessage("Should return 204 after deleting"))
+		_, exists, _ := store.Get("abc123")
+		should.BeFalse(t, exists, should.WithMessage("Deleted code should be removed from the store"))
+	})
+
+	t.Run("should return not found when deleting an unknown code through DELETE /{code}", func(t *testing.T) {
+		resetStore()
+
+		req := httptest.NewRequest(http.MethodDelete, "/missing", nil)
+		w := httptest.NewRecorder()
+
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+	})
+
+	t.Run("should reject deleting without a code", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		req := httptest.NewRequest(http.MethodDelete, "/shorten/", nil)
+		w := httptest.NewRecorder()
+
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusMethodNotAllowed)
+		should.BeEqual(t, len(storedLinks(t)), 1)
+	})
+
+	t.Run("should return method not allowed for other methods", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPatch, "/shorten/abc123", nil)
+		w := httptest.NewRecorder()
+
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusMethodNotAllowed)
+	})
+
+	t.Run("should stop redirecting after deletion", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		route(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/shorten/abc123", nil))
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		w := httptest.NewRecorder()
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusNotFound, should.WithMessage("Deleted code should no longer redirect"))
+	})
+}
+
+func TestParseRedirectStatus(t *testing.T) {
+	for _, raw := range []string{"301", "302", "307", "308"} {
+		t.Run("should accept "+raw, func(t *testing.T) {
+			status, err := parseRedirectStatus(raw)
+			should.BeNil(t, err)
+			should.BeEqual(t, strconv.Itoa(status), raw)
+		})
+	}
+
+	for _, raw := range []string{"200", "303", "404", "permanent"} {
+		t.Run("should reject "+raw, func(t *testing.T) {
+			_, err := parseRedirectStatus(raw)
+			should.NotBeNil(t, err)
+		})
+	}
+}
+
+func TestInvalidRedirectStatus(t *testing.T) {
+	// The child process runs main, whose logger.Fatal exits the process
+	if os.Getenv("SNIPLINK_TEST_MAIN") == "1" {
+		os.Args = os.Args[:1]
+		main()
+		return
+	}
+
+	t.Run("should stop at startup with a clear message", func(t *testing.T) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestInvalidRedirectStatus$")
+		cmd.Env = append(os.Environ(), "SNIPLINK_TEST_MAIN=1", envRedirectStatus+"=303")
+		output, err := cmd.CombinedOutput()
+
+		var exitErr *exec.ExitError
+		should.BeTrue(t, errors.As(err, &exitErr), should.WithMessage("main should exit with an error"))
+		should.ContainSubstring(t, string(output), "Invalid SNIPLINK_REDIRECT_STATUS value")
+		should.ContainSubstring(t, string(output), "redirect status 303 is not one of 301, 302, 307 or 308")
 	})
 }
 
@@ -170,8 +935,8 @@ func TestURLPairStruct(t *testing.T) {
 
 func TestIntegration(t *testing.T) {
 	t.Run("should create and redirect successfully", func(t *testing.T) {
-		// Clear the urlMap for clean test
-		urlMap = make(map[string]string)
+		// Clear the store for clean test
+		resetStore()
 		
 		// Step 1: Create short URL
 		originalURL := "https://github.com"
@@ -182,21 +947,80 @@ func TestIntegration(t *testing.T) {
 		w1 := httptest.NewRecorder()
 		shortenHandler(w1, req1)
 		
-		should.BeEqual(t, w1.Code, http.StatusOK, should.WithMessage("Shorten should succeed"))
+		should.BeEqual(t, w1.Code, http.StatusCreated, should.WithMessage("Shorten should succeed"))
 		
 		var response map[string]string
 		json.Unmarshal(w1.Body.Bytes(), &response)
 		shortCode := response["short_code"]
 		
 		should.NotBeEmpty(t, shortCode, should.WithMessage("Short code should not be empty"))
-		should.ContainKey(t, urlMap, shortCode, should.WithMessage("URL should be stored in map"))
+		_, exists, _ := store.Get(shortCode)
+		should.BeTrue(t, exists, should.WithMessage("URL should be stored in map"))
 		
 		// Step 2: Test redirect
 		req2 := httptest.NewRequest(http.MethodGet, "/"+shortCode, nil)
 		w2 := httptest.NewRecorder()
-		redirectHandler(w2, req2)
+		route(w2, req2)
 		
 		should.BeEqual(t, w2.Code, http.StatusTemporaryRedirect, should.WithMessage("Redirect should succeed"))
 		should.BeEqual(t, w2.Header().Get("Location"), originalURL, should.WithMessage("Should redirect to original URL"))
 	})
-} 
\ No newline at end of file
+} 
+
+func TestConcurrentAccess(t *testing.T) {
+	t.Run("should serve concurrent shorten and redirect requests safely", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				jsonData, _ := json.Marshal(URLPair{Original: "https://example.com/concurrent"})
+				req := httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(jsonData))
+				shortenHandler(httptest.NewRecorder(), req)
+			}()
+			go func() {
+				defer wg.Done()
+				req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+				route(httptest.NewRecorder(), req)
+			}()
+		}
+		wg.Wait()
+
+		record, exists, _ := store.Get("abc123")
+		should.BeTrue(t, exists, should.WithMessage("Existing mapping should survive concurrent access"))
+		should.BeEqual(t, record.Original, "https://example.com")
+	})
+}
+
+func TestParseMaxLinks(t *testing.T) {
+	t.Run("should accept empty and non-negative limits", func(t *testing.T) {
+		for raw, want := range map[string]int{"": 0, "0": 0, "10000": 10000} {
+			n, err := parseMaxLinks(raw)
+			should.BeNil(t, err)
+			should.BeEqual(t, n, want)
+		}
+	})
+
+	t.Run("should reject negative and non-numeric limits", func(t *testing.T) {
+		for _, raw := range []string{"-1", "many"} {
+			_, err := parseMaxLinks(raw)
+			should.NotBeNil(t, err, should.WithMessage(raw+" should be rejected"))
+		}
+	})
+}
+
+func TestLinkEvicted(t *testing.T) {
+	t.Run("should forget the click events of evicted links", func(t *testing.T) {
+		resetStore()
+		clickEvents.record("abc123", ClickEvent{Time: time.Now()})
+		before := testutil.ToFloat64(evictedLinksTotal)
+
+		linkEvicted("abc123")
+
+		should.HaveLength(t, clickEvents.latest("abc123"), 0)
+		should.BeEqual(t, testutil.ToFloat64(evictedLinksTotal), before+1)
+	})
+}
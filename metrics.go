@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	shortenRequestsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sniplink_shorten_requests_total",
+		Help: "Total number of shorten requests.",
+	})
+
+	redirectsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sniplink_redirects_total",
+		Help: "Total number of redirect lookups, labeled by whether the short code was found.",
+	}, []string{"result"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sniplink_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests in seconds, labeled by method and route pattern.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path"})
+
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sniplink_http_requests_total",
+		Help: "Total number of HTTP requests, labeled by method, route pattern and status code.",
+	}, []string{"method", "path", "status"})
+
+	evictedLinksTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sniplink_evicted_links_total",
+		Help: "Total number of links the memory store evicted to stay within SNIPLINK_MAX_LINKS.",
+	})
+
+	storedLinksGauge = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "sniplink_stored_links",
+		Help: "Number of short links currently stored.",
+	}, countStoredLinks)
+)
+
+// countStoredLinks reports the size of the store to storedLinksGauge, a
+// failing store reads as NaN
+func countStoredLinks() float64 {
+	n, err := store.Count()
+	if err != nil {
+		logger.Error("Failed to count stored links", zap.Error(err))
+		return math.NaN()
+	}
+	return float64(n)
+}
+
+// routeLabel is the path label of a request: the route pattern it matched,
+// so that short codes do not each become a time series
+func routeLabel(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.RoutePattern() == "" {
+		return "unmatched"
+	}
+	return rctx.RoutePattern()
+}
+
+// statusRecorder remembers the status code and counts the body bytes
+// written through it
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// statusCode is the status of a finished request, a handler that wrote
+// nothing answered 200
+func (rec *statusRecorder) statusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+// statusLabel is the status label of a finished request
+func (rec *statusRecorder) statusLabel() string {
+	return strconv.Itoa(rec.statusCode())
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("should count shorten requests", func(t *testing.T) {
+		before := testutil.ToFloat64(shortenRequestsTotal)
+
+		shortenHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(`{"original":"https://example.com"}`)))
+
+		should.BeEqual(t, testutil.ToFloat64(shortenRequestsTotal), before+1)
+	})
+
+	t.Run("should count redirect hits and misses", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		hits := testutil.ToFloat64(redirectsTotal.WithLabelValues("hit"))
+		misses := testutil.ToFloat64(redirectsTotal.WithLabelValues("miss"))
+
+		route(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abc123", nil))
+		route(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+		should.BeEqual(t, testutil.ToFloat64(redirectsTotal.WithLabelValues("hit")), hits+1)
+		should.BeEqual(t, testutil.ToFloat64(redirectsTotal.WithLabelValues("miss")), misses+1)
+	})
+
+	t.Run("should observe request durations in the logging middleware", func(t *testing.T) {
+		handler := loggingMiddleware(func(w http.ResponseWriter, r *http.Request) {})
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/anything", nil))
+
+		should.BeGreaterOrEqualTo(t, testutil.CollectAndCount(requestDuration, "sniplink_http_request_duration_seconds"), 1)
+	})
+
+	t.Run("should observe durations by route", func(t *testing.T) {
+		// durations counts the durations observed for method and path
+		durations := func(method, path string) uint64 {
+			families, err := prometheus.DefaultGatherer.Gather()
+			should.BeNil(t, err)
+			for _, family := range families {
+				if family.GetName() != "sniplink_http_request_duration_seconds" {
+					continue
+				}
+				for _, metric := range family.GetMetric() {
+					labels := make(map[string]string)
+					for _, label := range metric.GetLabel() {
+						labels[label.GetName()] = label.GetValue()
+					}
+					if labels["method"] == method && labels["path"] == path {
+						return metric.GetHistogram().GetSampleCount()
+					}
+				}
+			}
+			return 0
+		}
+		before := durations(http.MethodGet, "/preview/{code}")
+
+		resetStore()
+		route(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/preview/missing", nil))
+
+		should.BeEqual(t, durations(http.MethodGet, "/preview/{code}"), before+1)
+	})
+
+	t.Run("should count requests by method, route and status", func(t *testing.T) {
+		counter := requestsTotal.WithLabelValues(http.MethodGet, "/stats/{code}", "404")
+		before := testutil.ToFloat64(counter)
+
+		resetStore()
+		route(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stats/missing", nil))
+		route(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stats/other", nil))
+
+		should.BeEqual(t, testutil.ToFloat64(counter), before+2, should.WithMessage("Short codes should share the route label"))
+	})
+
+	t.Run("should count handlers that write no status as 200", func(t *testing.T) {
+		counter := requestsTotal.WithLabelValues(http.MethodGet, "unmatched", "200")
+		before := testutil.ToFloat64(counter)
+
+		loggingMiddleware(func(w http.ResponseWriter, r *http.Request) {})(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		should.BeEqual(t, testutil.ToFloat64(counter), before+1)
+	})
+
+	t.Run("should report the number of stored links", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		store.Set("def456", URLRecord{Original: "https://example.org"})
+
+		should.BeEqual(t, testutil.ToFloat64(storedLinksGauge), float64(2))
+	})
+
+	t.Run("should expose metrics in the Prometheus text format", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		promhttp.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		body, _ := io.ReadAll(w.Body)
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.ContainSubstring(t, string(body), "sniplink_shorten_requests_total")
+		should.ContainSubstring(t, string(body), "sniplink_redirects_total")
+		should.ContainSubstring(t, string(body), "sniplink_http_requests_total")
+		should.ContainSubstring(t, string(body), "sniplink_http_request_duration_seconds")
+		should.ContainSubstring(t, string(body), "sniplink_stored_links")
+	})
+}
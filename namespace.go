@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxNamespaceLength caps the size of link namespaces
+const maxNamespaceLength = 32
+
+var (
+	errNamespaceInvalid  = errors.New("namespace must be 1 to 32 letters, numbers, '_' or '-'")
+	errNamespaceReserved = errors.New("namespace is reserved")
+)
+
+var namespacePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// reservedNamespaces are the first path segments routed to other handlers,
+// links in them could never be reached
+var reservedNamespaces = []string{"shorten", "links", "stats", "preview", "qr", "healthz", "metrics", "webhooks", "admin"}
+
+// reservedSuffixes are the last path segments of /{code}/info and
+// /{code}/qr, a link named after one of them in a namespace could never be
+// reached
+var reservedSuffixes = []string{"info", "qr"}
+
+// reservedCode reports whether a short code would be shadowed by another
+// route, at the top level or inside a namespace
+func reservedCode(shortCode string) bool {
+	return slices.Contains(reservedNamespaces, shortCode) || slices.Contains(reservedSuffixes, shortCode)
+}
+
+// validateNamespace checks a caller supplied namespace, the empty namespace
+// is valid and keeps links at the top level
+func validateNamespace(namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+	if len(namespace) > maxNamespaceLength || !namespacePattern.MatchString(namespace) {
+		return errNamespaceInvalid
+	}
+	if slices.Contains(reservedNamespaces, namespace) {
+		return errNamespaceReserved
+	}
+	return nil
+}
+
+// namespacedCode is the store key of shortCode in namespace. Short codes
+// cannot contain ':', so namespaced keys never collide with top level ones
+func namespacedCode(namespace, shortCode string) string {
+	if namespace == "" {
+		return shortCode
+	}
+	return namespace + ":" + shortCode
+}
+
+// shortCodeParam returns the store key named by the {code} and optional
+// {namespace} route parameters
+func shortCodeParam(r *http.Request) string {
+	return namespacedCode(chi.URLParam(r, "namespace"), chi.URLParam(r, "code"))
+}
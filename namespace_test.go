@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestValidateNamespace(t *testing.T) {
+	for _, namespace := range []string{"", "go", "docs", "team_a-1"} {
+		t.Run("should accept "+namespace, func(t *testing.T) {
+			should.BeNil(t, validateNamespace(namespace))
+		})
+	}
+
+	for _, namespace := range []string{"a:b", "a/b", "with space", strings.Repeat("a", maxNamespaceLength+1)} {
+		t.Run("should reject "+namespace, func(t *testing.T) {
+			should.BeEqual(t, validateNamespace(namespace), errNamespaceInvalid)
+		})
+	}
+
+	for _, namespace := range reservedNamespaces {
+		t.Run("should reserve "+namespace, func(t *testing.T) {
+			should.BeEqual(t, validateNamespace(namespace), errNamespaceReserved)
+		})
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	shorten := func(body string) (*httptest.ResponseRecorder, map[string]string) {
+		req := httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		shortenHandler(w, req)
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	follow := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	t.Run("should keep namespaced and bare codes apart", func(t *testing.T) {
+		resetStore()
+
+		w, response := shorten(`{"original":"https://example.com/docs","short_code":"intro","namespace":"docs"}`)
+		should.BeEqual(t, w.Code, http.StatusCreated)
+		should.BeEqual(t, response["short_code"], "docs:intro")
+		should.BeEqual(t, response["short_url"], baseURL+"/docs/intro")
+
+		w, _ = shorten(`{"original":"https://example.com/bare","short_code":"intro"}`)
+		should.BeEqual(t, w.Code, http.StatusCreated, should.WithMessage("The bare code should still be free"))
+
+		should.BeEqual(t, follow("/docs/intro").Header().Get("Location"), "https://example.com/docs")
+		should.BeEqual(t, follow("/intro").Header().Get("Location"), "https://example.com/bare")
+	})
+
+	t.Run("should generate codes inside the namespace", func(t *testing.T) {
+		resetStore()
+
+		w, response := shorten(`{"original":"https://example.com","namespace":"go"}`)
+
+		should.BeEqual(t, w.Code, http.StatusCreated)
+		should.BeTrue(t, strings.HasPrefix(response["short_code"], "go:"), should.WithMessage("Generated code should carry the namespace"))
+		should.BeEqual(t, follow("/"+strings.Replace(response["short_code"], ":", "/", 1)).Code, redirectStatus)
+	})
+
+	t.Run("should allow the same alias in different namespaces", func(t *testing.T) {
+		resetStore()
+
+		w, _ := shorten(`{"original":"https://example.com/a","short_code":"intro","namespace":"a"}`)
+		should.BeEqual(t, w.Code, http.StatusCreated)
+		w, _ = shorten(`{"original":"https://example.com/b","short_code":"intro","namespace":"b"}`)
+		should.BeEqual(t, w.Code, http.StatusCreated)
+		w, _ = shorten(`{"original":"https://example.com/c","short_code":"intro","namespace":"a"}`)
+		should.BeEqual(t, w.Code, http.StatusConflict)
+	})
+
+	t.Run("should reject invalid and reserved namespaces", func(t *testing.T) {
+		resetStore()
+
+		w, _ := shorten(`{"original":"https://example.com","namespace":"a:b"}`)
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeInvalidNamespace)
+
+		w, _ = shorten(`{"original":"https://example.com","namespace":"stats"}`)
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+		should.BeEqual(t, decodeError(t, w).Error, errNamespaceReserved.Error())
+	})
+
+	t.Run("should reach namespaced links through the other endpoints", func(t *testing.T) {
+		resetStore()
+		store.Set("docs:intro", URLRecord{Original: "https://example.com"})
+
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/stats/docs/intro", nil))
+		should.BeEqual(t, w.Code, http.StatusOK)
+
+		w = httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/preview/docs/intro", nil))
+		should.BeEqual(t, w.Code, http.StatusOK)
+
+		should.BeEqual(t, follow("/docs/intro/qr").Header().Get("Content-Type"), "image/png")
+
+		w = httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodDelete, "/shorten/docs/intro", nil))
+		should.BeEqual(t, w.Code, http.StatusNoContent)
+		_, exists, _ := store.Get("docs:intro")
+		should.BeFalse(t, exists)
+	})
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// openAPISpec is the JSON encoded newAPISpec, marshaled once at startup
+var openAPISpec = mustMarshal(newAPISpec())
+
+// mustMarshal encodes v as JSON and panics when it cannot, which only a
+// programming error in newAPISpec can cause
+func mustMarshal(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// openAPIHandler serves GET /openapi.json
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(openAPISpec)))
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+
+	t.Run("should serve the spec as JSON", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json")
+		should.BeNil(t, json.Unmarshal(w.Body.Bytes(), &spec), should.WithMessage("Spec should be valid JSON"))
+		should.BeEqual(t, spec.OpenAPI, "3.0.3")
+	})
+
+	t.Run("should pass OpenAPI validation", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+		loader := openapi3.NewLoader()
+		doc, err := loader.LoadFromData(w.Body.Bytes())
+		should.BeNil(t, err, should.WithMessage("Spec should load"))
+		should.BeNil(t, doc.Validate(loader.Context))
+	})
+
+	t.Run("should document every route and nothing else", func(t *testing.T) {
+		documented := make(map[string]bool)
+		for path, item := range spec.Paths {
+			for method := range item {
+				if method != "parameters" {
+					documented[strings.ToUpper(method)+" "+path] = true
+				}
+			}
+		}
+
+		var routed []string
+		chi.Walk(testRouter.(chi.Routes), func(method, pattern string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			// Namespaced variants are described once in the info section
+			if !strings.Contains(pattern, "{namespace}") {
+				path := strings.ReplaceAll(pattern, "/*", "")
+				if path != "/" {
+					path = strings.TrimSuffix(path, "/")
+				}
+				routed = append(routed, method+" "+path)
+			}
+			return nil
+		})
+		for _, r := range routed {
+			should.BeTrue(t, documented[r], should.WithMessage(r+" should be documented"))
+		}
+		for d := range documented {
+			should.BeTrue(t, slices.Contains(routed, d), should.WithMessage(d+" should be routed"))
+		}
+	})
+}
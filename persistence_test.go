@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestPersistence(t *testing.T) {
+	t.Run("should round trip mappings through a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "urls.json")
+		original := map[string]URLRecord{
+			"abc123": {Original: "https://example.com"},
+			"xyz789": {Original: "https://google.com", ExpiresAt: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+			"def456": {Original: "https://example.org", CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+		}
+
+		err := saveToFile(path, original)
+		should.BeNil(t, err, should.WithMessage("Should save without error"))
+
+		loaded, err := loadFromFile(path)
+		should.BeNil(t, err, should.WithMessage("Should load without error"))
+		should.BeEqual(t, loaded, original, should.WithMessage("Loaded mappings should match saved ones"))
+	})
+
+	t.Run("should create missing directories when saving", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "data", "urls.json")
+
+		err := saveToFile(path, map[string]URLRecord{})
+		should.BeNil(t, err, should.WithMessage("Should save without error"))
+
+		_, err = os.Stat(path)
+		should.BeNil(t, err, should.WithMessage("File should exist after saving"))
+	})
+
+	t.Run("should not leave temporary files behind", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "urls.json")
+
+		saveToFile(path, map[string]URLRecord{"abc123": {Original: "https://example.com"}})
+		saveToFile(path, map[string]URLRecord{"xyz789": {Original: "https://google.com"}})
+
+		entries, _ := os.ReadDir(dir)
+		should.HaveLength(t, entries, 1, should.WithMessage("Only the data file should remain"))
+	})
+
+	t.Run("should load files that map codes to bare URLs", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "urls.json")
+		os.WriteFile(path, []byte(`{"abc123":"https://example.com"}`), 0o644)
+
+		loaded, err := loadFromFile(path)
+		should.BeNil(t, err, should.WithMessage("Older data files should still load"))
+		should.BeEqual(t, loaded, map[string]URLRecord{"abc123": {Original: "https://example.com"}})
+	})
+
+	t.Run("should load files mixing bare URLs and records", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "urls.json")
+		os.WriteFile(path, []byte(`{"abc123":"https://example.com","def456":{"original":"https://example.org","created_at":"2025-01-02T03:04:05Z"}}`), 0o644)
+
+		loaded, err := loadFromFile(path)
+		should.BeNil(t, err)
+		should.BeTrue(t, loaded["abc123"].CreatedAt.IsZero(), should.WithMessage("Bare URLs have no creation time"))
+		should.BeTrue(t, loaded["def456"].CreatedAt.Equal(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)))
+	})
+
+	t.Run("should return empty map for missing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing.json")
+
+		loaded, err := loadFromFile(path)
+		should.BeNil(t, err, should.WithMessage("Missing file should not be an error"))
+		should.BeEmpty(t, loaded, should.WithMessage("Missing file should yield no mappings"))
+	})
+
+	t.Run("should return error for corrupt file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "urls.json")
+		os.WriteFile(path, []byte("{not json"), 0o644)
+
+		_, err := loadFromFile(path)
+		should.NotBeNil(t, err, should.WithMessage("Corrupt file should return an error"))
+	})
+}
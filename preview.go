@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// previewResponse is the body returned by previewHandler
+type previewResponse struct {
+	ShortCode   string    `json:"short_code"`
+	OriginalURL string    `json:"original_url"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+}
+
+// previewHandler shows where the short link named by /preview/{code} points
+// without redirecting, so looking before following does not count as a click
+func previewHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := shortCodeParam(r)
+	record, exists, err := store.Get(shortCode)
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+	if !exists {
+		errorResponse(w, "Short code not found", errCodeNotFound, http.StatusNotFound)
+		return
+	}
+	if record.expired(time.Now()) {
+		errorResponse(w, "Short link has expired", errCodeGone, http.StatusGone)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(previewResponse{
+		ShortCode:   shortCode,
+		OriginalURL: record.Original,
+		CreatedAt:   record.CreatedAt,
+	})
+}
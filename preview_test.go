@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestPreviewHandler(t *testing.T) {
+	getPreview := func(shortCode string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/preview/"+shortCode, nil)
+		w := httptest.NewRecorder()
+		route(w, req)
+		return w
+	}
+
+	t.Run("should return the destination without redirecting", func(t *testing.T) {
+		resetStore()
+		createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		store.Set("abc123", URLRecord{Original: "https://example.com", CreatedAt: createdAt})
+
+		w := getPreview("abc123")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json")
+		should.BeEqual(t, w.Header().Get("Location"), "", should.WithMessage("Preview should not redirect"))
+
+		var response previewResponse
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeNil(t, err, should.WithMessage("Response should be valid JSON"))
+		should.BeEqual(t, response.ShortCode, "abc123")
+		should.BeEqual(t, response.OriginalURL, "https://example.com")
+		should.BeTrue(t, response.CreatedAt.Equal(createdAt), should.WithMessage("Preview should report when the link was created"))
+	})
+
+	t.Run("should not count a preview as a click", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		getPreview("abc123")
+		getPreview("abc123")
+
+		_, clicks, _, _ := store.Stats("abc123")
+		should.BeEqual(t, clicks.Total, uint64(0))
+	})
+
+	t.Run("should return 404 for unknown codes", func(t *testing.T) {
+		resetStore()
+
+		w := getPreview("missing")
+
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeNotFound)
+	})
+
+	t.Run("should return 410 for expired links", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com", ExpiresAt: time.Now().Add(-time.Second)})
+
+		w := getPreview("abc123")
+
+		should.BeEqual(t, w.Code, http.StatusGone)
+	})
+
+	t.Run("should reject other methods", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/preview/abc123", nil)
+		w := httptest.NewRecorder()
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusMethodNotAllowed)
+	})
+}
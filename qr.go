@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultQRSize is the width and height in pixels of QR codes unless the
+	// size query parameter asks for another one
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 1024
+)
+
+var errQRSize = errors.New("size must be a whole number of pixels between 64 and 1024")
+
+// parseQRSize parses the size query parameter, an empty value selects the
+// default size
+func parseQRSize(raw string) (int, error) {
+	if raw == "" {
+		return defaultQRSize, nil
+	}
+	size, err := strconv.Atoi(raw)
+	if err != nil || size < minQRSize || size > maxQRSize {
+		return 0, errQRSize
+	}
+	return size, nil
+}
+
+// qrHandler serves GET /{code}/qr and GET /qr/{code} as a PNG QR code
+// encoding the short URL of the link, sized by the optional size query
+// parameter
+func qrHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := shortCodeParam(r)
+	record, exists, err := store.Get(shortCode)
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+	if !exists {
+		errorResponse(w, "Short code not found", errCodeNotFound, http.StatusNotFound)
+		return
+	}
+	if record.expired(time.Now()) {
+		errorResponse(w, "Short link has expired", errCodeGone, http.StatusGone)
+		return
+	}
+
+	size, err := parseQRSize(r.URL.Query().Get("size"))
+	if err != nil {
+		errorResponse(w, err.Error(), errCodeInvalidQuery, http.StatusBadRequest)
+		return
+	}
+
+	png, err := qrcode.Encode(shortURL(shortCode), qrcode.Medium, size)
+	if err != nil {
+		requestLogger(r).Error("Could not encode QR code", zap.String("short_code", shortCode), zap.Error(err))
+		errorResponse(w, "Could not generate the QR code", errCodeInternal, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(png)))
+	w.Write(png)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestQRHandler(t *testing.T) {
+	getQR := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		w := httptest.NewRecorder()
+		route(w, req)
+		return w
+	}
+
+	t.Run("should serve a PNG of the default size", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := getQR("/abc123/qr")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "image/png")
+		img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+		should.BeNil(t, err, should.WithMessage("Body should be a valid PNG"))
+		should.BeEqual(t, img.Bounds().Dx(), defaultQRSize)
+	})
+
+	t.Run("should serve the same image under /qr/{code}", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		store.Set("team:abc123", URLRecord{Original: "https://example.org"})
+
+		should.BeEqual(t, getQR("/qr/abc123?size=128").Body.Bytes(), getQR("/abc123/qr?size=128").Body.Bytes())
+		should.BeEqual(t, getQR("/qr/team/abc123").Body.Bytes(), getQR("/team/abc123/qr").Body.Bytes(),
+			should.WithMessage("Namespaced links should have the prefixed path too"))
+		should.BeEqual(t, getQR("/qr/missing").Code, http.StatusNotFound)
+	})
+
+	t.Run("should honour the size parameter", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := getQR("/abc123/qr?size=512")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+		should.BeNil(t, err)
+		should.BeEqual(t, img.Bounds().Dx(), 512)
+	})
+
+	t.Run("should reject sizes out of range", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		for _, size := range []string{"10", "5000", "big"} {
+			w := getQR("/abc123/qr?size=" + size)
+
+			should.BeEqual(t, w.Code, http.StatusBadRequest, should.WithMessage("size="+size))
+			should.BeEqual(t, decodeError(t, w).Code, errCodeInvalidQuery)
+		}
+	})
+
+	t.Run("should not count as a click", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		getQR("/abc123/qr")
+
+		_, clicks, _, _ := store.Stats("abc123")
+		should.BeEqual(t, clicks.Total, uint64(0))
+	})
+
+	t.Run("should return 404 for unknown codes", func(t *testing.T) {
+		resetStore()
+
+		w := getQR("/missing/qr")
+
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeNotFound)
+	})
+
+	t.Run("should return 410 for expired links", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com", ExpiresAt: time.Now().Add(-time.Second)})
+
+		w := getQR("/abc123/qr")
+
+		should.BeEqual(t, w.Code, http.StatusGone)
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// limiterIdleTimeout is how long a client's limiter is kept after its last request
+	limiterIdleTimeout = 10 * time.Minute
+	// limiterCleanupInterval is how often idle limiters are swept
+	limiterCleanupInterval = time.Minute
+)
+
+var (
+	errRateLimitRPS   = errors.New("rate limit must be a positive number of requests per second")
+	errRateLimitBurst = errors.New("rate limit burst must be a positive integer")
+)
+
+// parseRateLimit parses the requests per second and burst settings of the
+// per client rate limiter
+func parseRateLimit(rawRPS, rawBurst string) (float64, int, error) {
+	rps, err := strconv.ParseFloat(rawRPS, 64)
+	if err != nil || rps <= 0 || math.IsInf(rps, 0) || math.IsNaN(rps) {
+		return 0, 0, errRateLimitRPS
+	}
+	burst, err := strconv.Atoi(rawBurst)
+	if err != nil || burst < 1 {
+		return 0, 0, errRateLimitBurst
+	}
+	return rps, burst, nil
+}
+
+// clientLimiter is the token bucket of a single client IP
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen atomic.Int64
+}
+
+// rateLimiter holds a token bucket per client IP. The routes it wraps share
+// the buckets, so a client has one budget however it spreads its requests
+type rateLimiter struct {
+	limiters   sync.Map
+	rps        float64
+	burst      int
+	retryAfter string
+}
+
+// newRateLimiter creates a rateLimiter allowing each client IP rps requests
+// per second with bursts of up to burst requests. Limiters of clients that
+// went quiet are dropped in the background
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	rl := &rateLimiter{rps: rps, burst: burst, retryAfter: strconv.Itoa(int(math.Max(1, math.Ceil(1/rps))))}
+
+	go func() {
+		ticker := time.NewTicker(limiterCleanupInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			cleanupIdleLimiters(&rl.limiters, time.Now().Add(-limiterIdleTimeout))
+		}
+	}()
+	return rl
+}
+
+// wrap limits the requests reaching next, answering 429 once the bucket of
+// the client is empty
+func (rl *rateLimiter) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value, _ := rl.limiters.LoadOrStore(clientIP(r), &clientLimiter{
+			limiter: rate.NewLimiter(rate.Limit(rl.rps), rl.burst),
+		})
+		client := value.(*clientLimiter)
+		client.lastSeen.Store(time.Now().UnixNano())
+
+		if !client.limiter.Allow() {
+			w.Header().Set("Retry-After", rl.retryAfter)
+			errorResponse(w, "Rate limit exceeded", errCodeRateLimited, http.StatusTooManyRequests)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// rateLimitMiddleware limits each client IP to rps requests per second with
+// bursts of up to burst requests, with buckets of its own
+func rateLimitMiddleware(next http.HandlerFunc, rps float64, burst int) http.HandlerFunc {
+	return newRateLimiter(rps, burst).wrap(next)
+}
+
+// cleanupIdleLimiters drops the limiters of clients not seen since cutoff
+func cleanupIdleLimiters(limiters *sync.Map, cutoff time.Time) {
+	limiters.Range(func(key, value any) bool {
+		if value.(*clientLimiter).lastSeen.Load() < cutoff.UnixNano() {
+			limiters.Delete(key)
+		}
+		return true
+	})
+}
+
+// trustedProxies are the proxies whose X-Forwarded-For header is believed,
+// configured through SNIPLINK_TRUSTED_PROXIES. Empty ignores the header
+var trustedProxies []netip.Prefix
+
+// trustedProxy reports whether addr lies in one of trustedProxies
+func trustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client that sent r, the host part of
+// the connection's remote address. When that is a trusted proxy the
+// X-Forwarded-For addresses are walked from the nearest hop and the first
+// one that is not a trusted proxy is the client, the hops before it were
+// written by the client and may be forged
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !trustedProxy(addr) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A garbled hop, the nearest address seen so far is the client
+			break
+		}
+		host = addr.String()
+		if !trustedProxy(addr) {
+			break
+		}
+	}
+	return host
+}
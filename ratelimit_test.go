@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	t.Run("should reject requests once the burst is used up", func(t *testing.T) {
+		handler := rateLimitMiddleware(okHandler, 1, 2)
+
+		codes := make([]int, 0, 3)
+		for i := 0; i < 3; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/shorten", nil)
+			w := httptest.NewRecorder()
+			handler(w, req)
+			codes = append(codes, w.Code)
+
+			if w.Code == http.StatusTooManyRequests {
+				should.BeEqual(t, w.Header().Get("Retry-After"), "1", should.WithMessage("Should tell the client when to retry"))
+			}
+		}
+
+		should.BeEqual(t, codes, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests})
+	})
+
+	t.Run("should limit each client IP separately", func(t *testing.T) {
+		handler := rateLimitMiddleware(okHandler, 1, 1)
+
+		first := httptest.NewRequest(http.MethodPost, "/shorten", nil)
+		first.RemoteAddr = "10.0.0.1:1234"
+		handler(httptest.NewRecorder(), first)
+
+		second := httptest.NewRequest(http.MethodPost, "/shorten", nil)
+		second.RemoteAddr = "10.0.0.2:1234"
+		w := httptest.NewRecorder()
+		handler(w, second)
+
+		should.BeEqual(t, w.Code, http.StatusOK, should.WithMessage("Another client should have its own bucket"))
+	})
+}
+
+func TestCleanupIdleLimiters(t *testing.T) {
+	t.Run("should drop only limiters idle since the cutoff", func(t *testing.T) {
+		var limiters sync.Map
+		now := time.Now()
+
+		idle := &clientLimiter{limiter: rate.NewLimiter(1, 1)}
+		idle.lastSeen.Store(now.Add(-time.Hour).UnixNano())
+		active := &clientLimiter{limiter: rate.NewLimiter(1, 1)}
+		active.lastSeen.Store(now.UnixNano())
+		limiters.Store("10.0.0.1", idle)
+		limiters.Store("10.0.0.2", active)
+
+		cleanupIdleLimiters(&limiters, now.Add(-limiterIdleTimeout))
+
+		_, idleKept := limiters.Load("10.0.0.1")
+		_, activeKept := limiters.Load("10.0.0.2")
+		should.BeFalse(t, idleKept, should.WithMessage("Idle limiter should be removed"))
+		should.BeTrue(t, activeKept, should.WithMessage("Active limiter should be kept"))
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	t.Run("should use the remote address host", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.7:52000"
+		should.BeEqual(t, clientIP(req), "203.0.113.7")
+	})
+
+	t.Run("should take the client from X-Forwarded-For behind trusted proxies", func(t *testing.T) {
+		trustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+		defer func() { trustedProxies = nil }()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:52000"
+		req.Header.Set("X-Forwarded-For", "192.0.2.99, 198.51.100.4, 10.0.0.2")
+		should.BeEqual(t, clientIP(req), "198.51.100.4", should.WithMessage("The hop before the trusted ones is the client"))
+	})
+
+	t.Run("should ignore X-Forwarded-For from other clients", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.7:52000"
+		req.Header.Set("X-Forwarded-For", "198.51.100.4")
+		should.BeEqual(t, clientIP(req), "203.0.113.7")
+	})
+
+	t.Run("should stop at a garbled hop", func(t *testing.T) {
+		trustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+		defer func() { trustedProxies = nil }()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:52000"
+		req.Header.Set("X-Forwarded-For", "198.51.100.4, not-an-ip, 10.0.0.2")
+		should.BeEqual(t, clientIP(req), "10.0.0.2")
+	})
+}
+
+func TestParseRateLimit(t *testing.T) {
+	t.Run("should parse valid settings", func(t *testing.T) {
+		rps, burst, err := parseRateLimit("0.5", "3")
+		should.BeNil(t, err)
+		should.BeEqual(t, rps, 0.5)
+		should.BeEqual(t, burst, 3)
+	})
+
+	t.Run("should reject invalid settings", func(t *testing.T) {
+		for _, tc := range []struct {
+			rps, burst string
+			want       error
+		}{
+			{"fast", "10", errRateLimitRPS},
+			{"0", "10", errRateLimitRPS},
+			{"-1", "10", errRateLimitRPS},
+			{"Inf", "10", errRateLimitRPS},
+			{"5", "many", errRateLimitBurst},
+			{"5", "0", errRateLimitBurst},
+		} {
+			_, _, err := parseRateLimit(tc.rps, tc.burst)
+			should.BeEqual(t, err, tc.want, should.WithMessage("rps="+tc.rps+" burst="+tc.burst))
+		}
+	})
+}
+
+func TestRouterRateLimit(t *testing.T) {
+	t.Run("should share one budget across the guarded routes", func(t *testing.T) {
+		resetStore()
+		router := newRouter(1, 2, []string{"*"})
+
+		codes := make([]int, 0, 3)
+		for _, target := range []string{"/links", "/links/search?q=example", "/team/missing"} {
+			method := http.MethodGet
+			if target == "/team/missing" {
+				method = http.MethodDelete
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+			codes = append(codes, w.Code)
+		}
+
+		should.BeEqual(t, codes, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests})
+	})
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// defaultRedisURL is the Redis server of the redis store unless
+	// SNIPLINK_REDIS_URL points elsewhere
+	defaultRedisURL = "redis://localhost:6379/0"
+	// redisConnectTimeout bounds the reachability check at startup
+	redisConnectTimeout = 5 * time.Second
+	// redisExpiredRetention is how long Redis keeps an expired link before its
+	// TTL removes it, so that it answers 410 until the sweeper deletes it
+	redisExpiredRetention = 24 * time.Hour
+)
+
+// Keys shared by every SnipLink instance using the same Redis database.
+// Each link is a hash under redisURLPrefix+code and its total and bot
+// clicks a hash under redisClicksPrefix+code, which expires with it
+const (
+	redisURLPrefix    = "sniplink:url:"
+	redisClicksPrefix = "sniplink:clicks:"
+	redisCodesKey     = "sniplink:codes"
+	redisOriginalsKey = "sniplink:originals"
+	redisExpiryKey    = "sniplink:expiry"
+)
+
+// redisSetScript stores links, keeping their clicks, and maintains the code
+// set, the original URL index and the expiry index. With only_if_absent set
+// it stores none of them when a code is taken and returns the taken codes.
+// KEYS: originals, expiry, codes, then the URL and clicks keys of every link
+// ARGV: only_if_absent, then code, original, expires_at_ns, created_at_ns,
+// expires_at_ms, ttl_deadline_ms, permanent for every link
+var redisSetScript = redis.NewScript(`
+local taken = {}
+local links = (#KEYS - 3) / 2
+if ARGV[1] == '1' then
+	for i = 0, links - 1 do
+		if redis.call('EXISTS', KEYS[4 + i * 2]) == 1 then
+			table.insert(taken, ARGV[i * 7 + 2])
+		end
+	end
+	if #taken > 0 then
+		return taken
+	end
+end
+for i = 0, links - 1 do
+	local link, clicks, a = KEYS[4 + i * 2], KEYS[5 + i * 2], i * 7 + 1
+	local old = redis.call('HGET', link, 'original')
+	if old and redis.call('HGET', KEYS[1], old) == ARGV[a + 1] then
+		redis.call('HDEL', KEYS[1], old)
+	end
+	redis.call('HSET', link, 'original', ARGV[a + 2], 'expires_at', ARGV[a + 3], 'created_at', ARGV[a + 4], 'permanent', ARGV[a + 7])
+	redis.call('HSET', KEYS[1], ARGV[a + 2], ARGV[a + 1])
+	redis.call('SADD', KEYS[3], ARGV[a + 1])
+	if ARGV[a + 3] == '0' then
+		redis.call('PERSIST', link)
+		redis.call('PERSIST', clicks)
+		redis.call('ZREM', KEYS[2], ARGV[a + 1])
+	else
+		redis.call('PEXPIREAT', link, ARGV[a + 6])
+		redis.call('PEXPIREAT', clicks, ARGV[a + 6])
+		redis.call('ZADD', KEYS[2], ARGV[a + 5], ARGV[a + 1])
+	end
+end
+return taken
+`)
+
+// redisUpdateScript points an existing link at a new original URL and
+// returns its expires_at, created_at and permanent, or nil for a missing link.
+// KEYS: link, originals
+// ARGV: code, original
+var redisUpdateScript = redis.NewScript(`
+local old = redis.call('HGET', KEYS[1], 'original')
+if not old then
+	return false
+end
+if redis.call('HGET', KEYS[2], old) == ARGV[1] then
+	redis.call('HDEL', KEYS[2], old)
+end
+redis.call('HSET', KEYS[1], 'original', ARGV[2])
+redis.call('HSET', KEYS[2], ARGV[2], ARGV[1])
+return redis.call('HMGET', KEYS[1], 'expires_at', 'created_at', 'permanent')
+`)
+
+// redisResolveScript returns the original, expires_at, created_at and
+// permanent fields of a link, or nil for a missing link, and counts a click,
+// by a bot or not, unless it expired. Counts created by the click get the
+// TTL of the link.
+// KEYS: link, clicks
+// ARGV: now_ns, bot
+var redisResolveScript = redis.NewScript(`
+local fields = redis.call('HMGET', KEYS[1], 'original', 'expires_at', 'created_at', 'permanent')
+if not fields[1] then
+	return false
+end
+local expiresAt = tonumber(fields[2])
+if expiresAt == 0 or expiresAt > tonumber(ARGV[1]) then
+	if ARGV[2] == '1' then
+		redis.call('HINCRBY', KEYS[2], 'bot', 1)
+	end
+	if redis.call('HINCRBY', KEYS[2], 'total', 1) == 1 then
+		local ttl = redis.call('PTTL', KEYS[1])
+		if ttl > 0 then
+			redis.call('PEXPIRE', KEYS[2], ttl)
+		end
+	end
+end
+return fields
+`)
+
+// redisDeleteScript removes a link, its clicks and its index entries and
+// returns 1, or 0 when the link is missing. A non empty expired_by_ns only
+// removes the link if it expired by then.
+// KEYS: link, clicks, originals, expiry, codes
+// ARGV: code, expired_by_ns
+var redisDeleteScript = redis.NewScript(`
+local fields = redis.call('HMGET', KEYS[1], 'original', 'expires_at')
+local original = fields[1]
+if not original then
+	redis.call('DEL', KEYS[2])
+	redis.call('ZREM', KEYS[4], ARGV[1])
+	redis.call('SREM', KEYS[5], ARGV[1])
+	return 0
+end
+if ARGV[2] ~= '' then
+	local expiresAt = tonumber(fields[2])
+	if expiresAt == 0 or expiresAt > tonumber(ARGV[2]) then
+		return 0
+	end
+end
+if redis.call('HGET', KEYS[3], original) == ARGV[1] then
+	redis.call('HDEL', KEYS[3], original)
+end
+redis.call('DEL', KEYS[1], KEYS[2])
+redis.call('ZREM', KEYS[4], ARGV[1])
+redis.call('SREM', KEYS[5], ARGV[1])
+return 1
+`)
+
+// redisStore is the URLStore kept in Redis, which lets several instances
+// share one set of links. Times are stored as Unix nanoseconds with zero
+// standing for the zero time, like in the SQLite store
+type redisStore struct {
+	client *redis.Client
+}
+
+// newRedisStore connects to the Redis server at rawURL and fails when it
+// cannot be reached
+func newRedisStore(rawURL string) (*redisStore, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisConnectTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis at %s is unreachable: %w", opts.Addr, err)
+	}
+	return &redisStore{client: client}, nil
+}
+
+// Close closes the connections to Redis
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
+
+// Ping checks that the Redis server answers
+func (s *redisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// deleteKeys are the KEYS of redisDeleteScript
+func deleteKeys(shortCode string) []string {
+	return []string{redisURLPrefix + shortCode, redisClicksPrefix + shortCode, redisOriginalsKey, redisExpiryKey, redisCodesKey}
+}
+
+// redisTime parses a time field written by the scripts, a missing field
+// reads as the zero time
+func redisTime(v any) (time.Time, error) {
+	s, _ := v.(string)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return timeFromUnix(n), nil
+}
+
+// redisCount parses a click count written by the scripts, a link nobody
+// resolved yet has none and reads as zero
+func redisCount(v any) (uint64, error) {
+	s, _ := v.(string)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// redisRecord builds a record from the original, expires_at, created_at and
+// permanent fields of a link
+func redisRecord(fields []any) (URLRecord, error) {
+	original, _ := fields[0].(string)
+	expiresAt, err := redisTime(fields[1])
+	if err != nil {
+		return URLRecord{}, err
+	}
+	createdAt, err := redisTime(fields[2])
+	if err != nil {
+		return URLRecord{}, err
+	}
+	// Links stored before the flag existed have no permanent field
+	permanent, _ := fields[3].(string)
+	return URLRecord{Original: original, ExpiresAt: expiresAt, CreatedAt: createdAt, Permanent: permanent == "1"}, nil
+}
+
+// set stores the records through redisSetScript and returns the taken codes
+// when onlyIfAbsent kept it from storing them
+func (s *redisStore) set(records map[string]URLRecord, onlyIfAbsent bool) ([]string, error) {
+	flag := "0"
+	if onlyIfAbsent {
+		flag = "1"
+	}
+	keys := []string{redisOriginalsKey, redisExpiryKey, redisCodesKey}
+	args := []any{flag}
+	for shortCode, record := range records {
+		var expiresAtMs, deadlineMs int64
+		if !record.ExpiresAt.IsZero() {
+			expiresAtMs = record.ExpiresAt.UnixMilli()
+			deadlineMs = record.ExpiresAt.Add(redisExpiredRetention).UnixMilli()
+		}
+		permanent := "0"
+		if record.Permanent {
+			permanent = "1"
+		}
+		keys = append(keys, redisURLPrefix+shortCode, redisClicksPrefix+shortCode)
+		args = append(args, shortCode, record.Original, timeToUnix(record.ExpiresAt), timeToUnix(record.CreatedAt),
+			expiresAtMs, deadlineMs, permanent)
+	}
+	return redisSetScript.Run(context.Background(), s.client, keys, args...).StringSlice()
+}
+
+// Get returns the record stored under the given short code
+func (s *redisStore) Get(shortCode string) (URLRecord, bool, error) {
+	record, _, exists, err := s.Stats(shortCode)
+	return record, exists, err
+}
+
+// Stats returns the record stored under the given short code and the number
+// of times it was resolved
+func (s *redisStore) Stats(shortCode string) (URLRecord, ClickCounts, bool, error) {
+	ctx := context.Background()
+	pipe := s.client.Pipeline()
+	fieldsCmd := pipe.HMGet(ctx, redisURLPrefix+shortCode, "original", "expires_at", "created_at", "permanent")
+	clicksCmd := pipe.HMGet(ctx, redisClicksPrefix+shortCode, "total", "bot")
+	if _, err := pipe.Exec(ctx); err != nil {
+		return URLRecord{}, ClickCounts{}, false, err
+	}
+	fields := fieldsCmd.Val()
+	if fields[0] == nil {
+		return URLRecord{}, ClickCounts{}, false, nil
+	}
+
+	record, err := redisRecord(fields)
+	if err != nil {
+		return URLRecord{}, ClickCounts{}, false, err
+	}
+	total, err := redisCount(clicksCmd.Val()[0])
+	if err != nil {
+		return URLRecord{}, ClickCounts{}, false, err
+	}
+	bot, err := redisCount(clicksCmd.Val()[1])
+	if err != nil {
+		return URLRecord{}, ClickCounts{}, false, err
+	}
+	return record, ClickCounts{Total: total, Bot: bot}, true, nil
+}
+
+// Resolve returns the record stored under the given short code and counts
+// it as a click, by a bot or not, unless the record has expired
+func (s *redisStore) Resolve(shortCode string, now time.Time, bot bool) (URLRecord, bool, error) {
+	flag := "0"
+	if bot {
+		flag = "1"
+	}
+	fields, err := redisResolveScript.Run(context.Background(), s.client,
+		[]string{redisURLPrefix + shortCode, redisClicksPrefix + shortCode}, now.UnixNano(), flag).Slice()
+	if errors.Is(err, redis.Nil) {
+		return URLRecord{}, false, nil
+	}
+	if err != nil {
+		return URLRecord{}, false, err
+	}
+	record, err := redisRecord(fields)
+	return record, err == nil, err
+}
+
+// LookupOriginal returns the short code of an unexpired link to originalURL
+func (s *redisStore) LookupOriginal(originalURL string, now time.Time) (string, bool, error) {
+	shortCode, err := s.client.HGet(context.Background(), redisOriginalsKey, originalURL).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	record, exists, err := s.Get(shortCode)
+	if err != nil || !exists || record.expired(now) {
+		return "", false, err
+	}
+	return shortCode, true, nil
+}
+
+// Set stores the record under the given short code, keeping its click count
+// when the code already exists
+func (s *redisStore) Set(shortCode string, record URLRecord) error {
+	_, err := s.set(map[string]URLRecord{shortCode: record}, false)
+	return err
+}
+
+// SetIfAbsent stores the record under the given short code unless the code
+// is already taken, and reports whether it was stored
+func (s *redisStore) SetIfAbsent(shortCode string, record URLRecord) (bool, error) {
+	taken, err := s.set(map[string]URLRecord{shortCode: record}, true)
+	return err == nil && len(taken) == 0, err
+}
+
+// SetAllIfAbsent stores every record under its short code unless one of the
+// codes is taken, then it stores none and returns the taken codes
+func (s *redisStore) SetAllIfAbsent(records map[string]URLRecord) ([]string, error) {
+	return s.set(records, true)
+}
+
+// UpdateOriginal points an existing short code at originalURL, keeping the
+// rest of its record and its clicks, and returns the updated record
+func (s *redisStore) UpdateOriginal(shortCode, originalURL string) (URLRecord, bool, error) {
+	fields, err := redisUpdateScript.Run(context.Background(), s.client,
+		[]string{redisURLPrefix + shortCode, redisOriginalsKey}, shortCode, originalURL).Slice()
+	if errors.Is(err, redis.Nil) {
+		return URLRecord{}, false, nil
+	}
+	if err != nil {
+		return URLRecord{}, false, err
+	}
+	record, err := redisRecord(append([]any{originalURL}, fields...))
+	return record, err == nil, err
+}
+
+// Delete removes the mapping for the given short code and reports whether it existed
+func (s *redisStore) Delete(shortCode string) (bool, error) {
+	deleted, err := redisDeleteScript.Run(context.Background(), s.client, deleteKeys(shortCode), shortCode, "").Int()
+	return deleted == 1, err
+}
+
+// DeleteExpired removes every record that has expired by now and returns
+// their short codes
+func (s *redisStore) DeleteExpired(now time.Time) ([]string, error) {
+	ctx := context.Background()
+	shortCodes, err := s.client.ZRangeByScore(ctx, redisExpiryKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, shortCode := range shortCodes {
+		deleted, err := redisDeleteScript.Run(ctx, s.client, deleteKeys(shortCode), shortCode, now.UnixNano()).Int()
+		if err != nil {
+			return removed, err
+		}
+		if deleted == 1 {
+			removed = append(removed, shortCode)
+		}
+	}
+	return removed, nil
+}
+
+// List returns a copy of every stored mapping
+func (s *redisStore) List() (map[string]URLRecord, error) {
+	ctx := context.Background()
+	shortCodes, err := s.client.SMembers(ctx, redisCodesKey).Result()
+	if err != nil || len(shortCodes) == 0 {
+		return make(map[string]URLRecord), err
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(shortCodes))
+	for i, shortCode := range shortCodes {
+		cmds[i] = pipe.HMGet(ctx, redisURLPrefix+shortCode, "original", "expires_at", "created_at", "permanent")
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]URLRecord, len(shortCodes))
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		// The TTL of a link nobody swept removes its hash but not its code
+		if fields[0] == nil {
+			continue
+		}
+		record, err := redisRecord(fields)
+		if err != nil {
+			return nil, err
+		}
+		m[shortCodes[i]] = record
+	}
+	return m, nil
+}
+
+// Count returns the number of stored mappings
+func (s *redisStore) Count() (int, error) {
+	n, err := s.client.SCard(context.Background(), redisCodesKey).Result()
+	return int(n), err
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisStore(t *testing.T) {
+	openMini := func(t *testing.T) (*redisStore, *miniredis.Miniredis) {
+		server := miniredis.RunT(t)
+		s, err := newRedisStore("redis://" + server.Addr())
+		should.BeNil(t, err, should.WithMessage("Store should connect"))
+		t.Cleanup(func() { s.Close() })
+		return s, server
+	}
+
+	testURLStore(t, func(t *testing.T) URLStore {
+		s, _ := openMini(t)
+		return s
+	})
+
+	t.Run("should share links between instances", func(t *testing.T) {
+		first, server := openMini(t)
+		second, err := newRedisStore("redis://" + server.Addr())
+		should.BeNil(t, err)
+		defer second.Close()
+
+		first.Set("abc123", URLRecord{Original: "https://example.com"})
+		second.Resolve("abc123", time.Now(), false)
+
+		record, clicks, exists, _ := first.Stats("abc123")
+		should.BeTrue(t, exists, should.WithMessage("Link stored by one instance should be visible to the other"))
+		should.BeEqual(t, record.Original, "https://example.com")
+		should.BeEqual(t, clicks.Total, uint64(1))
+	})
+
+	t.Run("should keep links and clicks under their own keys", func(t *testing.T) {
+		s, server := openMini(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com"})
+		s.Resolve("abc123", time.Now(), false)
+		s.Resolve("abc123", time.Now(), false)
+
+		should.BeEqual(t, server.HGet("sniplink:url:abc123", "original"), "https://example.com")
+		should.BeEqual(t, server.HGet("sniplink:clicks:abc123", "total"), "2")
+
+		s.Delete("abc123")
+		should.BeFalse(t, server.Exists("sniplink:url:abc123"))
+		should.BeFalse(t, server.Exists("sniplink:clicks:abc123"))
+	})
+
+	t.Run("should expire the clicks with their link", func(t *testing.T) {
+		s, server := openMini(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com", ExpiresAt: time.Now().Add(time.Minute)})
+		s.Resolve("abc123", time.Now(), false)
+
+		should.BeTrue(t, server.TTL(redisClicksPrefix+"abc123") > 0, should.WithMessage("Counter should expire with its link"))
+
+		s.Set("abc123", URLRecord{Original: "https://example.com"})
+		should.BeEqual(t, server.TTL(redisClicksPrefix+"abc123"), time.Duration(0))
+		_, clicks, _, _ := s.Stats("abc123")
+		should.BeEqual(t, clicks.Total, uint64(1))
+	})
+
+	t.Run("should let Redis expire links nobody swept", func(t *testing.T) {
+		s, server := openMini(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com", ExpiresAt: time.Now().Add(time.Minute)})
+		s.Set("forever", URLRecord{Original: "https://example.org"})
+
+		should.BeTrue(t, server.TTL(redisURLPrefix+"abc123") > 0, should.WithMessage("Expiring link should have a TTL"))
+		should.BeEqual(t, server.TTL(redisURLPrefix+"forever"), time.Duration(0), should.WithMessage("Permanent link should have no TTL"))
+
+		server.FastForward(time.Minute + redisExpiredRetention + time.Second)
+
+		_, exists, _ := s.Get("abc123")
+		should.BeFalse(t, exists)
+		mappings, err := s.List()
+		should.BeNil(t, err)
+		should.BeEqual(t, len(mappings), 1, should.WithMessage("Links removed by their TTL should not be listed"))
+	})
+
+	t.Run("should drop the TTL when a link stops expiring", func(t *testing.T) {
+		s, server := openMini(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com", ExpiresAt: time.Now().Add(time.Minute)})
+		s.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		should.BeEqual(t, server.TTL(redisURLPrefix+"abc123"), time.Duration(0))
+		removed, _ := s.DeleteExpired(time.Now().Add(time.Hour))
+		should.BeEmpty(t, removed)
+	})
+
+	t.Run("should fail when Redis is unreachable", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		addr := server.Addr()
+		server.Close()
+
+		_, err := newRedisStore("redis://" + addr)
+		should.NotBeNil(t, err)
+		should.ContainSubstring(t, err.Error(), "unreachable")
+	})
+
+	t.Run("should reject an invalid URL", func(t *testing.T) {
+		_, err := newRedisStore("localhost:6379")
+		should.NotBeNil(t, err)
+	})
+}
+
+// envTestRedisURL points TestRedisStoreIntegration at a real Redis server,
+// for CI jobs that run one as a service. The tests flush its database
+const envTestRedisURL = "SNIPLINK_TEST_REDIS_URL"
+
+// TestRedisStoreIntegration runs the URLStore contract against a real Redis
+// server, which miniredis only imitates, Lua scripts in particular
+func TestRedisStoreIntegration(t *testing.T) {
+	rawURL := os.Getenv(envTestRedisURL)
+	if rawURL == "" {
+		t.Skip(envTestRedisURL + " is not set")
+	}
+
+	testURLStore(t, func(t *testing.T) URLStore {
+		s, err := newRedisStore(rawURL)
+		should.BeNil(t, err, should.WithMessage("Store should connect"))
+		t.Cleanup(func() { s.Close() })
+		should.BeNil(t, s.client.FlushDB(context.Background()).Err(), should.WithMessage("Database should be emptied"))
+		return s
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	// maxRequestIDLength bounds client supplied IDs, longer ones are replaced
+	maxRequestIDLength = 128
+)
+
+// requestLoggerKey is the context key of the request scoped logger
+type requestLoggerKey struct{}
+
+// requestIDMiddleware tags each request with an ID, reusing a well formed
+// X-Request-ID sent by the client, echoes it in the X-Request-ID response
+// header and hands handlers a logger carrying it through the request context
+func requestIDMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), requestLoggerKey{}, logger.With(zap.String("request_id", requestID)))
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// requestLogger returns the logger of the request, or the global logger when
+// the request did not pass through requestIDMiddleware
+func requestLogger(r *http.Request) *zap.Logger {
+	if l, ok := r.Context().Value(requestLoggerKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return logger
+}
+
+// validRequestID accepts non empty IDs of printable ASCII up to
+// maxRequestIDLength, so client input cannot forge log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random version 4 UUID
+func newRequestID() string {
+	var b [16]byte
+	// crypto/rand.Read never returns an error, it crashes the program if the
+	// system random source fails
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	handler := requestIDMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	send := func(requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if requestID != "" {
+			req.Header.Set(requestIDHeader, requestID)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	t.Run("should set a generated ID on the response", func(t *testing.T) {
+		w := send("")
+
+		should.BeTrue(t, uuidPattern.MatchString(w.Header().Get(requestIDHeader)), should.WithMessage("Generated ID should be a UUID"))
+	})
+
+	t.Run("should echo a client supplied ID", func(t *testing.T) {
+		w := send("trace-42")
+
+		should.BeEqual(t, w.Header().Get(requestIDHeader), "trace-42")
+	})
+
+	t.Run("should replace malformed client IDs", func(t *testing.T) {
+		for _, id := range []string{"with space", "line\nbreak", strings.Repeat("a", maxRequestIDLength+1)} {
+			w := send(id)
+
+			should.BeTrue(t, uuidPattern.MatchString(w.Header().Get(requestIDHeader)), should.WithMessage("ID "+id+" should be replaced"))
+		}
+	})
+
+	t.Run("should generate unique IDs", func(t *testing.T) {
+		seen := make(map[string]bool)
+		for i := 0; i < 1000; i++ {
+			id := send("").Header().Get(requestIDHeader)
+			should.BeFalse(t, seen[id], should.WithMessage("Request IDs should not repeat"))
+			seen[id] = true
+		}
+	})
+
+	t.Run("should pass a logger carrying the ID to handlers", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		saved := logger
+		logger = zap.New(core)
+		defer func() { logger = saved }()
+
+		logged := requestIDMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			requestLogger(r).Info("handled")
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(requestIDHeader, "trace-42")
+		logged(httptest.NewRecorder(), req)
+
+		entries := logs.All()
+		should.BeEqual(t, len(entries), 1)
+		should.BeEqual(t, entries[0].ContextMap()["request_id"], any("trace-42"))
+	})
+}
+
+func TestRequestLogger(t *testing.T) {
+	t.Run("should fall back to the global logger", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		should.BeTrue(t, requestLogger(req) == logger)
+	})
+}
+
+func TestAccessLog(t *testing.T) {
+	t.Run("should tag the start and end lines with the request ID", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		saved := logger
+		logger = zap.New(core)
+		defer func() { logger = saved }()
+		resetStore()
+
+		req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		req.Header.Set(requestIDHeader, "trace-42")
+		w := httptest.NewRecorder()
+		requestIDMiddleware(route)(w, req)
+
+		entries := logs.FilterMessageSnippet("Request").All()
+		should.BeEqual(t, len(entries), 2)
+		for _, entry := range entries {
+			should.BeEqual(t, entry.ContextMap()["request_id"], any("trace-42"), should.WithMessage(entry.Message+" should carry the ID"))
+		}
+		should.BeEqual(t, w.Header().Get(requestIDHeader), "trace-42")
+	})
+
+	t.Run("should log the status of finished requests", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		saved := logger
+		logger = zap.New(core)
+		defer func() { logger = saved }()
+
+		send := func(handler http.HandlerFunc) any {
+			loggingMiddleware(handler)(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			entries := logs.TakeAll()
+			return entries[len(entries)-1].ContextMap()["status"]
+		}
+
+		should.BeEqual(t, send(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}), any(int64(http.StatusNotFound)))
+		should.BeEqual(t, send(func(w http.ResponseWriter, r *http.Request) {}), any(int64(http.StatusOK)),
+			should.WithMessage("A handler that writes no status answered 200"))
+	})
+
+	t.Run("should log the client and the body size of finished requests", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		saved := logger
+		logger = zap.New(core)
+		defer func() { logger = saved }()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.7:51234"
+		req.Header.Set("User-Agent", "curl/8.5.0")
+		loggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("hello"))
+			w.Write([]byte(" world"))
+		})(httptest.NewRecorder(), req)
+
+		entries := logs.TakeAll()
+		fields := entries[len(entries)-1].ContextMap()
+		should.BeEqual(t, fields["remote_ip"], any("203.0.113.7"))
+		should.BeEqual(t, fields["user_agent"], any("curl/8.5.0"))
+		should.BeEqual(t, fields["status"], any(int64(http.StatusCreated)))
+		should.BeEqual(t, fields["bytes"], any(int64(len("hello world"))))
+		should.ContainKey(t, fields, "duration")
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// newRouter routes every endpoint of the service. Write endpoints require
+// the API key and are limited to rps requests per second with bursts of
+// burst per client IP, everything but the probes is logged and answers CORS
+// requests from corsOrigins
+func newRouter(rps float64, burst int, corsOrigins []string) http.Handler {
+	logged := NewChain(loggingMiddleware, func(next http.HandlerFunc) http.HandlerFunc {
+		return corsMiddleware(next, corsOrigins)
+	})
+	// One limiter for every guarded route, each wrap would otherwise give a
+	// client a fresh budget
+	limiter := newRateLimiter(rps, burst)
+	guarded := NewChain(limiter.wrap, authMiddleware)
+	// keyed routes are guarded and do not exist while no API key is set
+	keyed := NewChain(keyRequiredMiddleware, limiter.wrap, authMiddleware)
+	jsonOnly := contentTypeMiddleware(mediaTypeJSON)
+
+	r := chi.NewRouter()
+	// Pasted links often end in a slash, and HEAD requests get the GET route
+	r.Use(middleware.StripSlashes, middleware.GetHead)
+	// Preflight requests have no OPTIONS route, so CORS must wrap the 405 too
+	r.NotFound(logged.Then(notFoundHandler))
+	r.MethodNotAllowed(logged.Then(methodNotAllowedHandler))
+
+	// Probes hit /healthz every few seconds, so it skips the logging middleware,
+	// as do the files browsers and crawlers fetch unasked
+	r.Get("/healthz", healthHandler)
+	r.Get("/favicon.ico", faviconHandler)
+	r.Get("/robots.txt", robotsHandler)
+	r.Method(http.MethodGet, "/metrics", promhttp.Handler())
+
+	r.Group(func(r chi.Router) {
+		r.Use(logged.Wrap, bodyLimitMiddleware)
+
+		r.Get("/", indexHandler)
+		r.Get("/openapi.json", openAPIHandler)
+		// The listings expose every link, so they need the key like writes do
+		r.With(guarded.Wrap).Get("/links", listHandler)
+		r.With(guarded.Wrap).Get("/links/search", searchHandler)
+		// Fixed prefixes are subrouters, so a wrong method on them is a 405
+		// rather than a lookup of a short code named after the prefix
+		r.Mount("/stats", subrouter(func(r chi.Router) {
+			codeRoutes(r.Get, "/{code}", statsHandler)
+			codeRoutes(r.Get, "/{code}/events", clickEventsHandler)
+		}))
+		r.Mount("/preview", subrouter(func(r chi.Router) {
+			codeRoutes(r.Get, "/{code}", previewHandler)
+		}))
+		r.Mount("/qr", subrouter(func(r chi.Router) {
+			codeRoutes(r.Get, "/{code}", qrHandler)
+		}))
+		// Admin routes change the running service and webhooks make it
+		// post to any URL, so both need a key even where the rest is left
+		// open
+		r.With(keyed.Wrap).Mount("/admin", subrouter(func(r chi.Router) {
+			r.With(jsonOnly).Put("/log-level", logLevelHandler)
+		}))
+		r.With(keyed.Wrap).Mount("/webhooks", subrouter(func(r chi.Router) {
+			r.With(jsonOnly).Post("/", webhookHandler)
+		}))
+		r.With(guarded.Wrap).Mount("/shorten", subrouter(func(r chi.Router) {
+			// HTML forms post to /shorten, every other body is JSON
+			r.With(contentTypeMiddleware(mediaTypeJSON, mediaTypeForm)).Post("/", shortenHandler)
+			r.With(jsonOnly).Post("/batch", batchShortenHandler)
+			r.With(jsonOnly).Post("/bulk", batchShortenHandler)
+			codeRoutes(r.With(jsonOnly).Put, "/{code}", updateHandler)
+			codeRoutes(r.Delete, "/{code}", deleteHandler)
+		}))
+
+		codeRoutes(r.Get, "/{code}", redirectHandler)
+		codeRoutes(r.Get, "/{code}/qr", qrHandler)
+		codeRoutes(r.Get, "/{code}/info", infoHandler)
+		codeRoutes(r.With(guarded.Wrap, jsonOnly).Put, "/{code}", updateHandler)
+		codeRoutes(r.With(guarded.Wrap).Delete, "/{code}", deleteHandler)
+	})
+	return r
+}
+
+// subrouter returns a router holding the routes added by fn. Its requests
+// were logged by the parent router, so it answers unmatched ones directly
+func subrouter(fn func(r chi.Router)) chi.Router {
+	r := chi.NewRouter()
+	r.NotFound(notFoundHandler)
+	r.MethodNotAllowed(methodNotAllowedHandler)
+	fn(r)
+	return r
+}
+
+// codeRoutes registers handler for pattern, which names a link by {code},
+// and for the same pattern with the link inside a {namespace}
+func codeRoutes(register func(pattern string, handler http.HandlerFunc), pattern string, handler http.HandlerFunc) {
+	register(pattern, handler)
+	register(strings.Replace(pattern, "{code}", "{namespace}/{code}", 1), handler)
+}
+
+// notFoundHandler answers requests no route matches
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	errorResponse(w, "Not found", errCodeNotFound, http.StatusNotFound)
+}
+
+// methodNotAllowedHandler answers requests whose path has routes for other
+// methods only
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	errorResponse(w, "Method not allowed", errCodeMethodNotAllowed, http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+// testRouter routes test requests like the server does, without rate limits
+var testRouter = newRouter(math.Inf(1), 1, []string{"*"})
+
+// route sends a test request through testRouter
+func route(w http.ResponseWriter, r *http.Request) {
+	testRouter.ServeHTTP(w, r)
+}
+
+func TestRouter(t *testing.T) {
+	send := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	t.Run("should serve the landing page at the root path", func(t *testing.T) {
+		w := send(http.MethodGet, "/")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "text/html; charset=utf-8")
+	})
+
+	t.Run("should match short codes with and without a namespace", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		store.Set("team:abc123", URLRecord{Original: "https://example.org"})
+
+		should.BeEqual(t, send(http.MethodGet, "/abc123").Header().Get("Location"), "https://example.com")
+		should.BeEqual(t, send(http.MethodGet, "/team/abc123").Header().Get("Location"), "https://example.org")
+		should.BeEqual(t, send(http.MethodGet, "/team/abc123/extra").Code, http.StatusNotFound)
+	})
+
+	t.Run("should dispatch DELETE /shorten/{code} to deleteHandler", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := send(http.MethodDelete, "/shorten/abc123")
+
+		should.BeEqual(t, w.Code, http.StatusNoContent)
+		_, exists, _ := store.Get("abc123")
+		should.BeFalse(t, exists, should.WithMessage("The link should be deleted"))
+	})
+
+	t.Run("should prefer fixed segments over short codes", func(t *testing.T) {
+		resetStore()
+		store.Set("links", URLRecord{Original: "https://example.com"})
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		should.BeEqual(t, send(http.MethodGet, "/links").Header().Get("Content-Type"), "application/json")
+		should.BeEqual(t, send(http.MethodGet, "/abc123/qr").Header().Get("Content-Type"), "image/png")
+		should.BeEqual(t, send(http.MethodGet, "/stats/abc123/events").Body.String(), "[]\n")
+	})
+
+	t.Run("should answer HEAD requests with the GET route", func(t *testing.T) {
+		w := send(http.MethodHead, "/healthz")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+	})
+
+	t.Run("should reject other methods with the error envelope", func(t *testing.T) {
+		w := send(http.MethodPatch, "/shorten/abc123")
+
+		should.BeEqual(t, w.Code, http.StatusMethodNotAllowed)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeMethodNotAllowed)
+	})
+
+	t.Run("should answer preflight requests on write routes", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/shorten", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := httptest.NewRecorder()
+
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusNoContent)
+		should.BeEqual(t, w.Header().Get("Access-Control-Allow-Origin"), "*")
+	})
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Values of SNIPLINK_CODE_MODE
+const (
+	codeModeRandom     = "random"
+	codeModeSequential = "sequential"
+)
+
+// base62Digits are the digits of sequential codes with the default
+// alphabet, in increasing value
+const base62Digits = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// codeMode is the SNIPLINK_CODE_MODE links are generated with
+var codeMode = codeModeRandom
+
+// codeCounter is the last number handed out by generateSequentialCode
+var codeCounter atomic.Uint64
+
+// generateSequentialCode returns the encoding of the next value of
+// codeCounter with the characters of codeAlphabet, so codes grow from "1"
+// one character at a time. Codes only stay unique while a single instance
+// writes to the store, codes already taken by aliases are skipped by
+// storeWithGeneratedCode like collisions
+func generateSequentialCode() string {
+	return encodeSequential(codeCounter.Add(1), sequentialDigits(codeAlphabet))
+}
+
+// peekSequentialCode returns the first code past codeCounter that is not
+// reserved, the one storeWithGeneratedCode gives the next link unless an
+// alias takes it first, without handing it out
+func peekSequentialCode() string {
+	digits := sequentialDigits(codeAlphabet)
+	for n := codeCounter.Load() + 1; ; n++ {
+		if code := encodeSequential(n, digits); !reservedCode(code) {
+			return code
+		}
+	}
+}
+
+// sequentialDigits orders the characters of alphabet by increasing value,
+// numbers first, then lower and upper case letters, which turns the default
+// alphabet into base62Digits
+func sequentialDigits(alphabet string) string {
+	class := func(c byte) int {
+		switch {
+		case '0' <= c && c <= '9':
+			return 0
+		case 'a' <= c && c <= 'z':
+			return 1
+		}
+		return 2
+	}
+	digits := []byte(alphabet)
+	slices.SortStableFunc(digits, func(a, b byte) int { return cmp.Compare(class(a), class(b)) })
+	return string(digits)
+}
+
+// encodeSequential writes n with digits
+func encodeSequential(n uint64, digits string) string {
+	base := uint64(len(digits))
+	if n == 0 {
+		return digits[:1]
+	}
+	var buf [64]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = digits[n%base]
+		n /= base
+	}
+	return string(buf[i:])
+}
+
+// decodeSequential reads a number written by encodeSequential, false when
+// code holds other characters than digits or overflows
+func decodeSequential(code string, digits string) (uint64, bool) {
+	base := uint64(len(digits))
+	var n uint64
+	for i := 0; i < len(code); i++ {
+		d := strings.IndexByte(digits, code[i])
+		if d < 0 || n > (math.MaxUint64-uint64(d))/base {
+			return 0, false
+		}
+		n = n*base + uint64(d)
+	}
+	return n, code != ""
+}
+
+// lastSequentialCode returns the largest number the stored short codes
+// decode to with the digits of codeAlphabet, ignoring their namespace, so
+// that a restart resumes after every code handed out before it
+func lastSequentialCode(links map[string]URLRecord) uint64 {
+	digits := sequentialDigits(codeAlphabet)
+	var last uint64
+	for shortCode := range links {
+		if i := strings.IndexByte(shortCode, ':'); i >= 0 {
+			shortCode = shortCode[i+1:]
+		}
+		if n, ok := decodeSequential(shortCode, digits); ok {
+			last = max(last, n)
+		}
+	}
+	return last
+}
+
+// parseCodeMode returns the short code generator selected by a code mode
+// setting, random codes of the configured length or sequential ones
+func parseCodeMode(raw string) (CodeGenerator, error) {
+	switch raw {
+	case codeModeRandom:
+		return generateShortCode, nil
+	case codeModeSequential:
+		return func(int) (string, error) { return generateSequentialCode(), nil }, nil
+	}
+	return nil, fmt.Errorf("code mode %q is not %q or %q", raw, codeModeRandom, codeModeSequential)
+}
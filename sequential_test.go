@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestEncodeSequential(t *testing.T) {
+	t.Run("should encode numbers with base62 digits", func(t *testing.T) {
+		cases := map[uint64]string{
+			0:                    "0",
+			1:                    "1",
+			9:                    "9",
+			10:                   "a",
+			36:                   "A",
+			61:                   "Z",
+			62:                   "10",
+			3843:                 "ZZ",
+			3844:                 "100",
+			18446744073709551615: "lYGhA16ahyf",
+		}
+		for n, want := range cases {
+			should.BeEqual(t, encodeSequential(n, base62Digits), want)
+		}
+	})
+
+	t.Run("should decode what it encodes", func(t *testing.T) {
+		digits := sequentialDigits(unambiguousAlphabet)
+		for _, n := range []uint64{0, 1, 56, 57, 123456789, 18446744073709551615} {
+			decoded, ok := decodeSequential(encodeSequential(n, digits), digits)
+			should.BeTrue(t, ok)
+			should.BeEqual(t, decoded, n)
+		}
+	})
+
+	t.Run("should not decode other characters or overflowing codes", func(t *testing.T) {
+		for _, code := range []string{"", "ab-c", "lYGhA16ahyg", "100000000000"} {
+			_, ok := decodeSequential(code, base62Digits)
+			should.BeFalse(t, ok, should.WithMessage(code))
+		}
+	})
+}
+
+func TestSequentialDigits(t *testing.T) {
+	t.Run("should order the default alphabet as base62 digits", func(t *testing.T) {
+		should.BeEqual(t, sequentialDigits(defaultAlphabet), base62Digits)
+	})
+
+	t.Run("should put numbers first", func(t *testing.T) {
+		should.BeEqual(t, sequentialDigits(unambiguousAlphabet), "23456789abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ")
+	})
+}
+
+func TestLastSequentialCode(t *testing.T) {
+	t.Run("should return the largest code that decodes", func(t *testing.T) {
+		links := map[string]URLRecord{
+			"9":       {},
+			"1a":      {},
+			"team:zz": {},
+			"my-link": {},
+		}
+
+		should.BeEqual(t, lastSequentialCode(links), uint64(35*62+35))
+	})
+
+	t.Run("should return zero without links", func(t *testing.T) {
+		should.BeEqual(t, lastSequentialCode(nil), uint64(0))
+	})
+}
+
+func TestGenerateSequentialCode(t *testing.T) {
+	codeCounter.Store(0)
+	defer codeCounter.Store(0)
+
+	t.Run("should hand out increasing codes", func(t *testing.T) {
+		codes := make([]string, 0, 11)
+		for range 11 {
+			codes = append(codes, generateSequentialCode())
+		}
+
+		should.BeEqual(t, codes, []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "a", "b"})
+	})
+
+	t.Run("should use the configured alphabet", func(t *testing.T) {
+		codeAlphabet = unambiguousAlphabet
+		defer func() { codeAlphabet = defaultAlphabet }()
+		codeCounter.Store(0)
+
+		should.BeEqual(t, generateSequentialCode(), "3", should.WithMessage("0 and 1 are not in the unambiguous alphabet"))
+		codeCounter.Store(56)
+		should.BeEqual(t, generateSequentialCode(), "32")
+	})
+
+	t.Run("should peek at the next code without handing it out", func(t *testing.T) {
+		codeCounter.Store(41)
+
+		should.BeEqual(t, peekSequentialCode(), "G")
+		should.BeEqual(t, codeCounter.Load(), uint64(41))
+		should.BeEqual(t, generateSequentialCode(), "G")
+	})
+
+	t.Run("should peek past reserved codes", func(t *testing.T) {
+		qr, _ := decodeSequential("qr", base62Digits)
+		codeCounter.Store(qr - 1)
+
+		should.BeEqual(t, peekSequentialCode(), "qs")
+	})
+
+	t.Run("should skip codes already in the store", func(t *testing.T) {
+		resetStore()
+		codeCounter.Store(0)
+		newShortCode, _ = parseCodeMode(codeModeSequential)
+		defer func() { newShortCode = generateShortCode }()
+		store.Set("1", URLRecord{Original: "https://example.net"})
+
+		shortCode, err := storeWithGeneratedCode("", URLRecord{Original: "https://example.com"})
+
+		should.BeNil(t, err)
+		should.BeEqual(t, shortCode, "2")
+	})
+}
+
+func TestParseCodeMode(t *testing.T) {
+	t.Run("should return a generator for each mode", func(t *testing.T) {
+		codeCounter.Store(0)
+		defer codeCounter.Store(0)
+
+		random, err := parseCodeMode(codeModeRandom)
+		should.BeNil(t, err)
+		shortCode, _ := random(8)
+		should.BeEqual(t, len(shortCode), 8)
+
+		sequential, err := parseCodeMode(codeModeSequential)
+		should.BeNil(t, err)
+		shortCode, _ = sequential(8)
+		should.BeEqual(t, shortCode, "1", should.WithMessage("Sequential codes should ignore the configured length"))
+	})
+
+	t.Run("should reject unknown modes", func(t *testing.T) {
+		_, err := parseCodeMode("counter")
+
+		should.NotBeNil(t, err)
+		should.ContainSubstring(t, err.Error(), "counter")
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultShutdownTimeout bounds how long in-flight requests may take to
+	// finish once shutdown starts, unless SNIPLINK_SHUTDOWN_TIMEOUT overrides it
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+// serverTimeouts bound how long the server waits on clients, which could
+// otherwise hold connections open forever by trickling their requests in,
+// reading responses slowly or idling between requests
+type serverTimeouts struct {
+	// read covers the whole request, headers and body
+	read time.Duration
+	// write runs from the end of the request headers to the end of the
+	// response
+	write time.Duration
+	// idle is how long a keep-alive connection waits for the next request
+	idle time.Duration
+}
+
+// defaultServerTimeouts apply unless SNIPLINK_READ_TIMEOUT,
+// SNIPLINK_WRITE_TIMEOUT or SNIPLINK_IDLE_TIMEOUT override them
+var defaultServerTimeouts = serverTimeouts{
+	read:  10 * time.Second,
+	write: 10 * time.Second,
+	idle:  60 * time.Second,
+}
+
+// parseServerTimeouts parses the timeout settings, an empty value keeps the
+// default of its timeout
+func parseServerTimeouts(read, write, idle string) (serverTimeouts, error) {
+	timeouts := defaultServerTimeouts
+	for _, setting := range []struct {
+		name string
+		raw  string
+		dst  *time.Duration
+	}{
+		{"read", read, &timeouts.read},
+		{"write", write, &timeouts.write},
+		{"idle", idle, &timeouts.idle},
+	} {
+		if setting.raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(setting.raw)
+		if err != nil || d <= 0 {
+			return serverTimeouts{}, fmt.Errorf("%s timeout %q is not a positive duration", setting.name, setting.raw)
+		}
+		*setting.dst = d
+	}
+	return timeouts, nil
+}
+
+var errTLSFiles = errors.New("TLS needs both a certificate and a key file")
+
+// loadTLSConfig loads the certificate chain and key the server terminates
+// TLS with, both empty leaves the server on plain HTTP with a nil config.
+// The files are read once, renewed certificates take a restart
+func loadTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errTLSFiles
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// newServer returns the HTTP server for handler with the given timeouts
+func newServer(addr string, handler http.Handler, timeouts serverTimeouts) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		ReadTimeout:  timeouts.read,
+		WriteTimeout: timeouts.write,
+		IdleTimeout:  timeouts.idle,
+	}
+}
+
+// serve runs srv on ln until SIGINT or SIGTERM arrives, then stops accepting
+// connections and waits up to shutdownTimeout for in-flight requests. A srv
+// with a TLSConfig serves HTTPS
+func serve(srv *http.Server, ln net.Listener, shutdownTimeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			errCh <- srv.ServeTLS(ln, "", "")
+			return
+		}
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+	logger.Info("Server shutting down", zap.Duration("timeout", shutdownTimeout))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Server did not shut down cleanly", zap.Error(err))
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	logger.Info("Server stopped")
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestServe(t *testing.T) {
+	t.Run("should finish in-flight requests on SIGINT", func(t *testing.T) {
+		started := make(chan struct{})
+		mux := http.NewServeMux()
+		mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			io.WriteString(w, "done")
+		})
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		should.BeNil(t, err)
+		srv := newServer(ln.Addr().String(), mux, defaultServerTimeouts)
+
+		served := make(chan error, 1)
+		go func() {
+			served <- serve(srv, ln, 5*time.Second)
+		}()
+
+		type result struct {
+			status int
+			body   string
+			err    error
+		}
+		responses := make(chan result, 1)
+		go func() {
+			resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+			if err != nil {
+				responses <- result{err: err}
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			responses <- result{status: resp.StatusCode, body: string(body)}
+		}()
+
+		<-started
+		should.BeNil(t, syscall.Kill(syscall.Getpid(), syscall.SIGINT))
+
+		res := <-responses
+		should.BeNil(t, res.err, should.WithMessage("In-flight request should not be dropped"))
+		should.BeEqual(t, res.status, http.StatusOK)
+		should.BeEqual(t, res.body, "done")
+		should.BeNil(t, <-served, should.WithMessage("Server should shut down cleanly"))
+	})
+
+	t.Run("should serve HTTPS with a TLS config", func(t *testing.T) {
+		certFile, keyFile, cert := writeTestCert(t)
+		config, err := loadTLSConfig(certFile, keyFile)
+		should.BeNil(t, err)
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		should.BeNil(t, err)
+		srv := newServer(ln.Addr().String(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "secure")
+		}), defaultServerTimeouts)
+		srv.TLSConfig = config
+		// The plain HTTP request below fails its handshake on purpose
+		srv.ErrorLog = log.New(io.Discard, "", 0)
+
+		served := make(chan error, 1)
+		go func() {
+			served <- serve(srv, ln, 5*time.Second)
+		}()
+
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+		resp, err := client.Get("https://" + ln.Addr().String() + "/")
+		should.BeNil(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		should.BeEqual(t, string(body), "secure")
+
+		resp, err = http.Get("http://" + ln.Addr().String() + "/")
+		should.BeNil(t, err)
+		resp.Body.Close()
+		should.BeEqual(t, resp.StatusCode, http.StatusBadRequest, should.WithMessage("Plain HTTP should not be served"))
+
+		should.BeNil(t, syscall.Kill(syscall.Getpid(), syscall.SIGINT))
+		should.BeNil(t, <-served, should.WithMessage("Server should shut down cleanly"))
+	})
+}
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and its key
+// to PEM files in a temporary directory
+func writeTestCert(t *testing.T) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	should.BeNil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sniplink test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	should.BeNil(t, err)
+	cert, err = x509.ParseCertificate(der)
+	should.BeNil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	should.BeNil(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	should.BeNil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	should.BeNil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile, cert
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	t.Run("should leave the server on plain HTTP without files", func(t *testing.T) {
+		config, err := loadTLSConfig("", "")
+		should.BeNil(t, err)
+		should.BeNil(t, config)
+	})
+
+	t.Run("should require both files", func(t *testing.T) {
+		certFile, keyFile, _ := writeTestCert(t)
+
+		_, err := loadTLSConfig(certFile, "")
+		should.BeEqual(t, err, errTLSFiles)
+		_, err = loadTLSConfig("", keyFile)
+		should.BeEqual(t, err, errTLSFiles)
+	})
+
+	t.Run("should reject missing or mismatched files", func(t *testing.T) {
+		certFile, _, _ := writeTestCert(t)
+		_, otherKey, _ := writeTestCert(t)
+
+		_, err := loadTLSConfig(certFile, filepath.Join(t.TempDir(), "missing.pem"))
+		should.NotBeNil(t, err)
+		_, err = loadTLSConfig(certFile, otherKey)
+		should.NotBeNil(t, err, should.WithMessage("A key of another certificate should be rejected"))
+	})
+
+	t.Run("should load the certificate", func(t *testing.T) {
+		certFile, keyFile, _ := writeTestCert(t)
+
+		config, err := loadTLSConfig(certFile, keyFile)
+
+		should.BeNil(t, err)
+		should.HaveLength(t, config.Certificates, 1)
+		should.BeEqual(t, config.MinVersion, uint16(tls.VersionTLS12))
+	})
+}
+
+func TestParseServerTimeouts(t *testing.T) {
+	t.Run("should keep the defaults for empty settings", func(t *testing.T) {
+		timeouts, err := parseServerTimeouts("", "", "")
+
+		should.BeNil(t, err)
+		should.BeEqual(t, timeouts, defaultServerTimeouts)
+	})
+
+	t.Run("should override each timeout on its own", func(t *testing.T) {
+		timeouts, err := parseServerTimeouts("5s", "", "2m")
+
+		should.BeNil(t, err)
+		should.BeEqual(t, timeouts, serverTimeouts{read: 5 * time.Second, write: defaultServerTimeouts.write, idle: 2 * time.Minute})
+	})
+
+	t.Run("should reject invalid durations", func(t *testing.T) {
+		for _, raw := range []string{"soon", "0s", "-1s"} {
+			_, err := parseServerTimeouts("", raw, "")
+			should.NotBeNil(t, err, should.WithMessage(raw+" should be rejected"))
+		}
+	})
+}
+
+func TestNewServer(t *testing.T) {
+	t.Run("should apply every timeout", func(t *testing.T) {
+		srv := newServer(":0", http.NewServeMux(), serverTimeouts{read: time.Second, write: 2 * time.Second, idle: 3 * time.Second})
+
+		should.BeEqual(t, srv.ReadTimeout, time.Second)
+		should.BeEqual(t, srv.WriteTimeout, 2*time.Second)
+		should.BeEqual(t, srv.IdleTimeout, 3*time.Second)
+	})
+
+	t.Run("should drop clients that trickle their request", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		should.BeNil(t, err)
+		srv := newServer(ln.Addr().String(), http.NewServeMux(), serverTimeouts{read: 100 * time.Millisecond, write: time.Second, idle: time.Second})
+		go srv.Serve(ln)
+		defer srv.Close()
+
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		should.BeNil(t, err)
+		defer conn.Close()
+		// Headers that never end
+		io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.com\r\n")
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = io.ReadAll(conn)
+		should.BeNil(t, err, should.WithMessage("The server should close the connection"))
+	})
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// shortLink is the outcome of a successful createShortLink call
+type shortLink struct {
+	shortCode string
+	expiresAt time.Time
+	createdAt time.Time
+	// created is false when an existing link was reused
+	created bool
+}
+
+// dedupMu serializes deduplicated creations from the lookup to the insert,
+// so concurrent requests for a new URL agree on a single link. Instances
+// sharing a Redis store can still each create one
+var dedupMu sync.Mutex
+
+var errDryRun = errors.New("dry_run must be true or false")
+
+// parseDryRun reads the dry_run query parameter, false when absent
+func parseDryRun(query url.Values) (bool, error) {
+	raw := query.Get("dry_run")
+	if raw == "" {
+		return false, nil
+	}
+	dryRun, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errDryRun
+	}
+	return dryRun, nil
+}
+
+// validateShortLink checks urlPair and returns the record it asks for,
+// created at now. The returned apiError describes why it cannot be stored
+func validateShortLink(ctx context.Context, urlPair URLPair, now time.Time) (URLRecord, *apiError) {
+	// A missing field is a malformed body rather than a bad URL
+	if urlPair.Original == "" {
+		return URLRecord{}, &apiError{"original URL is required", errCodeInvalidBody, http.StatusBadRequest}
+	}
+	if err := validateURL(urlPair.Original); err != nil {
+		return URLRecord{}, &apiError{err.Error(), errCodeInvalidURL, http.StatusBadRequest}
+	}
+	if err := checkDestination(ctx, urlPair.Original); err != nil {
+		return URLRecord{}, destinationError(err)
+	}
+
+	if err := validateNamespace(urlPair.Namespace); err != nil {
+		return URLRecord{}, &apiError{err.Error(), errCodeInvalidNamespace, http.StatusBadRequest}
+	}
+
+	expiresAt, err := linkExpiry(urlPair, now)
+	if err != nil {
+		return URLRecord{}, &apiError{err.Error(), errCodeInvalidExpiry, http.StatusBadRequest}
+	}
+
+	if urlPair.ShortCode != "" {
+		if err := validateAlias(urlPair.ShortCode); err != nil {
+			return URLRecord{}, &apiError{err.Error(), errCodeInvalidShortCode, http.StatusBadRequest}
+		}
+	}
+	return URLRecord{Original: urlPair.Original, ExpiresAt: expiresAt, CreatedAt: now, Permanent: urlPair.Permanent}, nil
+}
+
+// deduplicated reports whether the link may reuse an existing link to the
+// same URL. Only plain requests are deduplicated, an alias, an expiry, a
+// namespace or a permanent redirect asks for a link of its own
+func deduplicated(urlPair URLPair, record URLRecord) bool {
+	return deduplicate && urlPair.ShortCode == "" && record.ExpiresAt.IsZero() && urlPair.Namespace == "" && !urlPair.Permanent
+}
+
+// createShortLink validates urlPair and stores it under its alias or a
+// generated short code. The returned apiError describes why nothing was
+// stored. A dry run validates urlPair the same way and returns the code it
+// would have used without storing anything, so the link is never created
+func createShortLink(ctx context.Context, urlPair URLPair, dryRun bool) (shortLink, *apiError) {
+	now := time.Now()
+	record, apiErr := validateShortLink(ctx, urlPair, now)
+	if apiErr != nil {
+		return shortLink{}, apiErr
+	}
+	expiresAt := record.ExpiresAt
+
+	if deduplicated(urlPair, record) {
+		// Held until the generated code below is stored
+		dedupMu.Lock()
+		defer dedupMu.Unlock()
+		shortCode, exists, err := store.LookupOriginal(urlPair.Original, now)
+		if err != nil {
+			logger.Error("Store operation failed", zap.Error(err))
+			return shortLink{}, errStoreUnavailable
+		}
+		if exists {
+			existing, _, err := store.Get(shortCode)
+			if err != nil {
+				logger.Error("Store operation failed", zap.Error(err))
+				return shortLink{}, errStoreUnavailable
+			}
+			return shortLink{shortCode: shortCode, createdAt: existing.CreatedAt}, nil
+		}
+	}
+
+	if urlPair.ShortCode != "" {
+		shortCode := namespacedCode(urlPair.Namespace, urlPair.ShortCode)
+		if dryRun {
+			_, taken, err := store.Get(shortCode)
+			if err != nil {
+				logger.Error("Store operation failed", zap.Error(err))
+				return shortLink{}, errStoreUnavailable
+			}
+			if taken {
+				return shortLink{}, errAliasTaken
+			}
+			return shortLink{shortCode: shortCode, expiresAt: expiresAt, createdAt: now}, nil
+		}
+		stored, err := store.SetIfAbsent(shortCode, record)
+		if err != nil {
+			logger.Error("Store operation failed", zap.Error(err))
+			return shortLink{}, errStoreUnavailable
+		}
+		if !stored {
+			return shortLink{}, errAliasTaken
+		}
+		return shortLink{shortCode: shortCode, expiresAt: expiresAt, createdAt: now, created: true}, nil
+	}
+
+	if dryRun {
+		shortCode, err := previewGeneratedCode(urlPair.Namespace, urlPair.Original)
+		if err != nil {
+			logger.Error("Could not generate a short code", zap.Error(err))
+			return shortLink{}, errNoCodeLeft
+		}
+		return shortLink{shortCode: shortCode, expiresAt: expiresAt, createdAt: now}, nil
+	}
+	shortCode, err := storeWithGeneratedCode(urlPair.Namespace, record)
+	if errors.Is(err, errNoUniqueCode) {
+		logger.Error("Could not generate a short code", zap.Error(err))
+		return shortLink{}, errNoCodeLeft
+	}
+	if err != nil {
+		logger.Error("Store operation failed", zap.Error(err))
+		return shortLink{}, errStoreUnavailable
+	}
+	return shortLink{shortCode: shortCode, expiresAt: expiresAt, createdAt: now, created: true}, nil
+}
+
+// Responses to a link that could not be stored under its code
+var (
+	errAliasTaken = &apiError{"short code already in use", errCodeConflict, http.StatusConflict}
+	errNoCodeLeft = &apiError{"Could not generate a unique short code", errCodeInternal, http.StatusInternalServerError}
+)
+
+// previewGeneratedCode returns a code storeWithGeneratedCode could give
+// original in namespace without using it up. Random codes are not reserved,
+// the real request generates a new one
+func previewGeneratedCode(namespace, original string) (string, error) {
+	if hmacSecret != "" {
+		return namespacedCode(namespace, generateHMACCode(hmacSecret, original)), nil
+	}
+	if codeMode == codeModeSequential {
+		return namespacedCode(namespace, peekSequentialCode()), nil
+	}
+	generated, err := newShortCode(codeLength)
+	if err != nil {
+		return "", err
+	}
+	return namespacedCode(namespace, generated), nil
+}
+
+const (
+	// maxBatchSize caps the number of URLs shortened by one batch request
+	maxBatchSize = 100
+	// batchCheckTimeout bounds the destination checks of a whole batch,
+	// lookups still running past it let their URL through like a lookup
+	// that fails
+	batchCheckTimeout = 3 * time.Second
+)
+
+// auditShorten logs who shortened original into link, the trail abuse
+// reports and takedowns start from
+func auditShorten(r *http.Request, original string, link shortLink) {
+	requestLogger(r).Info("Link shortened",
+		zap.String("remote_ip", clientIP(r)),
+		zap.String("original", original),
+		zap.String("short_code", link.shortCode),
+		zap.Bool("created", link.created),
+	)
+}
+
+// batchResult is the outcome of shortening one item of a batch, either the
+// link fields or the error fields are set
+type batchResult struct {
+	Original  string `json:"original"`
+	ShortCode string `json:"short_code,omitempty"`
+	ShortURL  string `json:"short_url,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Code      string `json:"code,omitempty"`
+}
+
+// batchLink is a validated item of a batch on its way to the store
+type batchLink struct {
+	urlPair    URLPair
+	record     URLRecord
+	candidates codeCandidates
+	// items are the positions in the batch the link answers, plain items
+	// asking for the same URL share one deduplicated link
+	items []int
+
+	link   shortLink
+	apiErr *apiError
+}
+
+// storeBatch stores the links of a batch all at once, so that no other
+// request sees part of a batch and a failing store leaves nothing behind.
+// Deduplicated links reuse an existing link to their URL. Taken aliases and
+// generated links that run out of codes get an apiError and are left out,
+// colliding generated codes are replaced before the next attempt
+func storeBatch(links []*batchLink, now time.Time) error {
+	if deduplicate {
+		dedupMu.Lock()
+		defer dedupMu.Unlock()
+	}
+
+	// Aliases are placed first so that generated codes make way for them
+	var aliased, generated []*batchLink
+	byOriginal := make(map[string]*batchLink)
+	for _, link := range links {
+		if deduplicated(link.urlPair, link.record) {
+			if first, exists := byOriginal[link.record.Original]; exists {
+				first.items = append(first.items, link.items...)
+				link.items = nil
+				continue
+			}
+			byOriginal[link.record.Original] = link
+
+			shortCode, exists, err := store.LookupOriginal(link.record.Original, now)
+			if err != nil {
+				return err
+			}
+			if exists {
+				existing, _, err := store.Get(shortCode)
+				if err != nil {
+					return err
+				}
+				link.link = shortLink{shortCode: shortCode, createdAt: existing.CreatedAt}
+				continue
+			}
+		}
+
+		link.link = shortLink{expiresAt: link.record.ExpiresAt, createdAt: now, created: true}
+		if link.urlPair.ShortCode != "" {
+			link.link.shortCode = namespacedCode(link.urlPair.Namespace, link.urlPair.ShortCode)
+			aliased = append(aliased, link)
+		} else {
+			link.candidates = codeCandidates{namespace: link.urlPair.Namespace, original: link.record.Original}
+			generated = append(generated, link)
+		}
+	}
+
+	pending := append(aliased, generated...)
+
+	for len(pending) > 0 {
+		records := make(map[string]URLRecord, len(pending))
+		for _, link := range pending {
+			if link.urlPair.ShortCode == "" && link.link.shortCode == "" {
+				if err := link.nextCode(records); err != nil {
+					return err
+				}
+			} else if _, exists := records[link.link.shortCode]; exists {
+				link.apiErr = errAliasTaken
+			}
+			if link.apiErr == nil {
+				records[link.link.shortCode] = link.record
+			}
+		}
+		pending = slices.DeleteFunc(pending, func(link *batchLink) bool {
+			return link.apiErr != nil
+		})
+
+		taken, err := store.SetAllIfAbsent(records)
+		if err != nil {
+			return err
+		}
+		if len(taken) == 0 {
+			return nil
+		}
+		for _, link := range pending {
+			if !slices.Contains(taken, link.link.shortCode) {
+				continue
+			}
+			if link.urlPair.ShortCode != "" {
+				link.apiErr = errAliasTaken
+			} else {
+				link.link.shortCode = ""
+			}
+		}
+		pending = slices.DeleteFunc(pending, func(link *batchLink) bool {
+			return link.apiErr != nil
+		})
+	}
+	return nil
+}
+
+// nextCode gives a generated link the next of its candidates the batch does
+// not use yet, or errNoCodeLeft once they run out
+func (link *batchLink) nextCode(used map[string]URLRecord) error {
+	for {
+		shortCode, ok, err := link.candidates.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			logger.Error("Could not generate a short code", zap.Error(errNoUniqueCode))
+			link.apiErr = errNoCodeLeft
+			return nil
+		}
+		if _, exists := used[shortCode]; !exists {
+			link.link.shortCode = shortCode
+			return nil
+		}
+	}
+}
+
+// batchShortenHandler shortens every URLPair of a JSON array. Items are
+// validated independently, so one invalid URL is reported in its own result
+// without failing the rest of the batch, then the valid ones are stored all
+// at once. Batches over maxBatchSize are rejected with 413 before anything
+// is stored
+func batchShortenHandler(w http.ResponseWriter, r *http.Request) {
+	var urlPairs []URLPair
+	if apiErr := decodeJSONBody(w, r, &urlPairs); apiErr != nil {
+		apiErr.write(w)
+		return
+	}
+	if len(urlPairs) > maxBatchSize {
+		errorResponse(w, fmt.Sprintf("A batch may hold at most %d URLs", maxBatchSize), errCodeBodyTooLarge, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Items are validated concurrently, their destination checks would take
+	// up to a DNS lookup each one after the other
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(r.Context(), batchCheckTimeout)
+	defer cancel()
+	records := make([]URLRecord, len(urlPairs))
+	apiErrs := make([]*apiError, len(urlPairs))
+	var wg sync.WaitGroup
+	for i, urlPair := range urlPairs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			records[i], apiErrs[i] = validateShortLink(ctx, urlPair, now)
+		}()
+	}
+	wg.Wait()
+
+	results := make([]batchResult, len(urlPairs))
+	links := make([]*batchLink, 0, len(urlPairs))
+	for i, urlPair := range urlPairs {
+		results[i].Original = urlPair.Original
+		if apiErr := apiErrs[i]; apiErr != nil {
+			results[i].Error, results[i].Code = apiErr.message, apiErr.code
+			continue
+		}
+		links = append(links, &batchLink{urlPair: urlPair, record: records[i], items: []int{i}})
+	}
+	if err := storeBatch(links, now); err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+
+	created := false
+	for _, link := range links {
+		for _, i := range link.items {
+			result := &results[i]
+			if link.apiErr != nil {
+				result.Error, result.Code = link.apiErr.message, link.apiErr.code
+				continue
+			}
+			auditShorten(r, result.Original, link.link)
+			result.ShortCode = link.link.shortCode
+			result.ShortURL = shortURL(link.link.shortCode)
+			if !link.link.expiresAt.IsZero() {
+				result.ExpiresAt = link.link.expiresAt.Format(time.RFC3339)
+			}
+			if !link.link.createdAt.IsZero() {
+				result.CreatedAt = link.link.createdAt.Format(time.RFC3339)
+			}
+			created = created || link.link.created
+		}
+	}
+	if created {
+		persist()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
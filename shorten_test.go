@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestBatchShortenHandler(t *testing.T) {
+	postBatch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/shorten/batch", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		batchShortenHandler(w, req)
+		return w
+	}
+
+	t.Run("should shorten every URL of the batch", func(t *testing.T) {
+		resetStore()
+
+		w := postBatch(`[{"original":"https://example.com"},{"original":"https://example.org","short_code":"org"}]`)
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json")
+
+		var results []batchResult
+		err := json.Unmarshal(w.Body.Bytes(), &results)
+		should.BeNil(t, err, should.WithMessage("Response should be valid JSON"))
+		should.BeEqual(t, len(results), 2)
+
+		should.BeEqual(t, results[0].Original, "https://example.com")
+		should.BeEqual(t, len(results[0].ShortCode), codeLength)
+		should.BeEqual(t, results[0].ShortURL, shortURL(results[0].ShortCode))
+		should.BeEqual(t, results[1].ShortCode, "org")
+
+		record, exists, _ := store.Get(results[0].ShortCode)
+		should.BeTrue(t, exists)
+		should.BeEqual(t, record.Original, "https://example.com")
+		should.BeFalse(t, record.CreatedAt.IsZero(), should.WithMessage("New links should record when they were created"))
+		should.BeEqual(t, results[0].CreatedAt, record.CreatedAt.Format(time.RFC3339))
+	})
+
+	t.Run("should report invalid items without failing the batch", func(t *testing.T) {
+		resetStore()
+		store.Set("taken", URLRecord{Original: "https://example.net"})
+
+		w := postBatch(`[{"original":"ftp://example.com"},{"original":"https://example.com","short_code":"taken"},{"original":"https://example.org"}]`)
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+
+		var results []batchResult
+		json.Unmarshal(w.Body.Bytes(), &results)
+		should.BeEqual(t, len(results), 3)
+
+		should.BeEqual(t, results[0].Code, errCodeInvalidURL)
+		should.BeEqual(t, results[0].ShortCode, "")
+		should.BeEqual(t, results[1].Code, errCodeConflict)
+		should.BeEqual(t, results[2].Error, "")
+		should.NotBeEmpty(t, results[2].ShortCode)
+	})
+
+	t.Run("should report items missing the original URL", func(t *testing.T) {
+		resetStore()
+
+		w := postBatch(`[{"short_code":"abc123"},{"original":"https://example.com"}]`)
+
+		var results []batchResult
+		json.Unmarshal(w.Body.Bytes(), &results)
+		should.BeEqual(t, results[0].Error, "original URL is required")
+		should.BeEqual(t, results[0].Code, errCodeInvalidBody)
+		should.NotBeEmpty(t, results[1].ShortCode)
+	})
+
+	t.Run("should regenerate codes another link holds", func(t *testing.T) {
+		resetStore()
+		store.Set("taken1", URLRecord{Original: "https://example.net"})
+		codes := []string{"taken1", "fresh1", "fresh2"}
+		newShortCode = func(int) (string, error) {
+			code := codes[0]
+			codes = codes[1:]
+			return code, nil
+		}
+		defer func() { newShortCode = generateShortCode }()
+
+		w := postBatch(`[{"original":"https://example.com"},{"original":"https://example.org","short_code":"org"}]`)
+
+		var results []batchResult
+		json.Unmarshal(w.Body.Bytes(), &results)
+		should.BeEqual(t, results[0].ShortCode, "fresh1")
+		should.BeEqual(t, results[1].ShortCode, "org")
+		should.BeEqual(t, len(storedLinks(t)), 3)
+	})
+
+	t.Run("should give a repeated alias to its first item only", func(t *testing.T) {
+		resetStore()
+
+		w := postBatch(`[{"original":"https://example.com","short_code":"same"},{"original":"https://example.org","short_code":"same"}]`)
+
+		var results []batchResult
+		json.Unmarshal(w.Body.Bytes(), &results)
+		should.BeEqual(t, results[0].ShortCode, "same")
+		should.BeEqual(t, results[1].Code, errCodeConflict)
+
+		record, _, _ := store.Get("same")
+		should.BeEqual(t, record.Original, "https://example.com")
+	})
+
+	t.Run("should share one link between items for the same URL when deduplicating", func(t *testing.T) {
+		resetStore()
+		deduplicate = true
+		defer func() { deduplicate = false }()
+		store.Set("old123", URLRecord{Original: "https://example.net"})
+
+		w := postBatch(`[{"original":"https://example.com"},{"original":"https://example.com"},{"original":"https://example.net"}]`)
+
+		var results []batchResult
+		json.Unmarshal(w.Body.Bytes(), &results)
+		should.BeEqual(t, results[1].ShortCode, results[0].ShortCode)
+		should.BeEqual(t, results[2].ShortCode, "old123")
+		should.BeEqual(t, len(storedLinks(t)), 2)
+	})
+
+	t.Run("should check the destinations of a batch concurrently", func(t *testing.T) {
+		resetStore()
+		lookupHost = func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+			time.Sleep(100 * time.Millisecond)
+			return fakeLookupHost(ctx, network, host)
+		}
+		defer func() { lookupHost = fakeLookupHost }()
+		items := make([]string, 20)
+		for i := range items {
+			items[i] = fmt.Sprintf(`{"original":"https://host%d.example.com"}`, i)
+		}
+
+		start := time.Now()
+		w := postBatch("[" + strings.Join(items, ",") + "]")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeLessThan(t, time.Since(start), time.Second, should.WithMessage("Lookups should not run one after the other"))
+		should.BeEqual(t, len(storedLinks(t)), 20)
+	})
+
+	t.Run("should accept a batch of the maximum size", func(t *testing.T) {
+		resetStore()
+		items := make([]string, maxBatchSize)
+		for i := range items {
+			items[i] = fmt.Sprintf(`{"original":"https://example.com/%d"}`, i)
+		}
+
+		w := postBatch("[" + strings.Join(items, ",") + "]")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, len(storedLinks(t)), maxBatchSize)
+	})
+
+	t.Run("should reject batches over the maximum size", func(t *testing.T) {
+		resetStore()
+		items := make([]string, maxBatchSize+1)
+		for i := range items {
+			items[i] = fmt.Sprintf(`{"original":"https://example.com/%d"}`, i)
+		}
+
+		w := postBatch("[" + strings.Join(items, ",") + "]")
+
+		should.BeEqual(t, w.Code, http.StatusRequestEntityTooLarge)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeBodyTooLarge)
+		should.BeEmpty(t, storedLinks(t), should.WithMessage("Nothing of an oversized batch should be stored"))
+	})
+
+	t.Run("should reject a body that is not an array", func(t *testing.T) {
+		w := postBatch(`{"original":"https://example.com"}`)
+
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeInvalidBody)
+	})
+
+	t.Run("should reject other methods", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/shorten/batch", nil)
+		w := httptest.NewRecorder()
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusMethodNotAllowed)
+	})
+}
+
+func TestAuditShorten(t *testing.T) {
+	observe := func(t *testing.T) *observer.ObservedLogs {
+		core, logs := observer.New(zap.InfoLevel)
+		saved := logger
+		logger = zap.New(core)
+		t.Cleanup(func() { logger = saved })
+		return logs
+	}
+
+	t.Run("should log the client, the URL and the code of a new link", func(t *testing.T) {
+		resetStore()
+		logs := observe(t)
+
+		req := httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(`{"original":"https://example.com","short_code":"audit"}`))
+		req.RemoteAddr = "203.0.113.7:51234"
+		shortenHandler(httptest.NewRecorder(), req)
+
+		entries := logs.FilterMessage("Link shortened").All()
+		should.BeEqual(t, len(entries), 1)
+		fields := entries[0].ContextMap()
+		should.BeEqual(t, fields["remote_ip"], any("203.0.113.7"))
+		should.BeEqual(t, fields["original"], any("https://example.com"))
+		should.BeEqual(t, fields["short_code"], any("audit"))
+		should.BeEqual(t, fields["created"], any(true))
+	})
+
+	t.Run("should take the client from X-Forwarded-For behind a proxy", func(t *testing.T) {
+		resetStore()
+		logs := observe(t)
+		// httptest requests come from 192.0.2.1
+		trustedProxies = []netip.Prefix{netip.MustParsePrefix("192.0.2.1/32"), netip.MustParsePrefix("10.0.0.0/8")}
+		defer func() { trustedProxies = nil }()
+
+		req := httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(`{"original":"https://example.com"}`))
+		req.Header.Set("X-Forwarded-For", "198.51.100.4, 10.0.0.1")
+		shortenHandler(httptest.NewRecorder(), req)
+
+		entries := logs.FilterMessage("Link shortened").All()
+		should.BeEqual(t, len(entries), 1)
+		should.BeEqual(t, entries[0].ContextMap()["remote_ip"], any("198.51.100.4"))
+	})
+
+	t.Run("should not log rejected URLs", func(t *testing.T) {
+		resetStore()
+		logs := observe(t)
+
+		shortenHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(`{"original":"javascript:alert(1)"}`)))
+
+		should.BeEqual(t, logs.FilterMessage("Link shortened").Len(), 0)
+	})
+
+	t.Run("should log every item of a batch", func(t *testing.T) {
+		resetStore()
+		logs := observe(t)
+
+		req := httptest.NewRequest(http.MethodPost, "/shorten/batch",
+			strings.NewReader(`[{"original":"https://example.com"},{"original":"https://example.org"},{"original":"not a url"}]`))
+		batchShortenHandler(httptest.NewRecorder(), req)
+
+		entries := logs.FilterMessage("Link shortened").All()
+		should.BeEqual(t, len(entries), 2)
+		should.BeEqual(t, entries[1].ContextMap()["original"], any("https://example.org"))
+	})
+}
+
+func TestShortenDryRun(t *testing.T) {
+	shorten := func(target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		route(w, jsonRequest(http.MethodPost, target, strings.NewReader(body)))
+		return w
+	}
+
+	t.Run("should answer a generated code without storing it", func(t *testing.T) {
+		resetStore()
+
+		w := shorten("/shorten?dry_run=true", `{"original":"https://example.com"}`)
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEmpty(t, w.Header().Get("Location"), should.WithMessage("A dry run has no link to point at"))
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeEqual(t, len(response["short_code"]), codeLength)
+		should.BeEqual(t, response["short_url"], shortURL(response["short_code"]))
+		should.NotBeEmpty(t, response["created_at"])
+		should.BeEmpty(t, storedLinks(t))
+	})
+
+	t.Run("should validate like a real request", func(t *testing.T) {
+		resetStore()
+		store.Set("taken", URLRecord{Original: "https://example.net"})
+
+		w := shorten("/shorten?dry_run=true", `{"original":"javascript:alert(1)"}`)
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+
+		w = shorten("/shorten?dry_run=true", `{"original":"https://example.com","short_code":"taken"}`)
+		should.BeEqual(t, w.Code, http.StatusConflict)
+
+		w = shorten("/shorten?dry_run=true", `{"original":"https://example.com","short_code":"free"}`)
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.HaveLength(t, storedLinks(t), 1)
+	})
+
+	t.Run("should preview the code a signed link gets", func(t *testing.T) {
+		resetStore()
+		hmacSecret = "secret"
+		defer func() { hmacSecret = "" }()
+
+		var preview, created map[string]string
+		json.Unmarshal(shorten("/shorten?dry_run=1", `{"original":"https://example.com"}`).Body.Bytes(), &preview)
+		json.Unmarshal(shorten("/shorten", `{"original":"https://example.com"}`).Body.Bytes(), &created)
+
+		should.BeEqual(t, preview["short_code"], created["short_code"])
+	})
+
+	t.Run("should not use up sequential codes", func(t *testing.T) {
+		resetStore()
+		codeCounter.Store(0)
+		codeMode = codeModeSequential
+		newShortCode, _ = parseCodeMode(codeModeSequential)
+		defer func() {
+			codeMode, newShortCode = codeModeRandom, generateShortCode
+			codeCounter.Store(0)
+		}()
+
+		var first, second, created map[string]string
+		json.Unmarshal(shorten("/shorten?dry_run=true", `{"original":"https://example.com"}`).Body.Bytes(), &first)
+		json.Unmarshal(shorten("/shorten?dry_run=true", `{"original":"https://example.com"}`).Body.Bytes(), &second)
+		json.Unmarshal(shorten("/shorten", `{"original":"https://example.com"}`).Body.Bytes(), &created)
+
+		should.BeEqual(t, first["short_code"], "1")
+		should.BeEqual(t, second["short_code"], "1")
+		should.BeEqual(t, created["short_code"], "1")
+	})
+
+	t.Run("should store the link when dry_run is false", func(t *testing.T) {
+		resetStore()
+
+		w := shorten("/shorten?dry_run=false", `{"original":"https://example.com"}`)
+
+		should.BeEqual(t, w.Code, http.StatusCreated)
+		should.HaveLength(t, storedLinks(t), 1)
+	})
+
+	t.Run("should reject a malformed flag", func(t *testing.T) {
+		resetStore()
+
+		w := shorten("/shorten?dry_run=maybe", `{"original":"https://example.com"}`)
+
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeInvalidQuery)
+		should.BeEmpty(t, storedLinks(t))
+	})
+}
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// errorResponses are the shared error responses by status, listed under
+// components and referenced by the operations that can answer with them
+var errorResponses = map[int]struct{ name, description string }{
+	http.StatusBadRequest:            {"BadRequest", "Invalid body, parameter, alias or URL"},
+	http.StatusUnauthorized:          {"Unauthorized", "Missing or wrong API key"},
+	http.StatusForbidden:             {"Forbidden", "URL points to a blocked network"},
+	http.StatusNotFound:              {"NotFound", "Unknown short code"},
+	http.StatusConflict:              {"Conflict", "Alias already in use, permanent link or webhook limit reached"},
+	http.StatusGone:                  {"Gone", "The link has expired"},
+	http.StatusRequestEntityTooLarge: {"PayloadTooLarge", "Body or batch over the limit"},
+	http.StatusUnsupportedMediaType:  {"UnsupportedMediaType", "Body is not of an accepted Content-Type"},
+	http.StatusTooManyRequests:       {"TooManyRequests", "Rate limit exceeded"},
+	http.StatusInternalServerError:   {"InternalError", "Storage failure"},
+}
+
+// errorCodes are the values of the code field of error responses
+var errorCodes = []any{
+	errCodeMethodNotAllowed, errCodeInvalidBody, errCodeBodyTooLarge, errCodeMediaType, errCodeInvalidURL,
+	errCodeBlockedURL, errCodeInvalidShortCode, errCodeInvalidNamespace, errCodeInvalidExpiry, errCodeInvalidQuery,
+	errCodeUnauthorized, errCodeConflict, errCodeNotFound, errCodeGone, errCodeRateLimited, errCodeInternal,
+}
+
+// schemaRef refers to the named schema under components
+func schemaRef(name string) *openapi3.SchemaRef {
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, nil)
+}
+
+// codeParam refers to the short code path parameter under components
+func codeParam() *openapi3.ParameterRef {
+	return &openapi3.ParameterRef{Ref: "#/components/parameters/code"}
+}
+
+// queryParam describes an optional query parameter
+func queryParam(name string, schema *openapi3.Schema) *openapi3.ParameterRef {
+	return &openapi3.ParameterRef{Value: openapi3.NewQueryParameter(name).WithSchema(schema)}
+}
+
+// bearerAuth requires the API key when one is configured
+func bearerAuth() *openapi3.SecurityRequirements {
+	return openapi3.NewSecurityRequirements().With(openapi3.NewSecurityRequirement().Authenticate("bearerAuth"))
+}
+
+// jsonBody is a required JSON request body matching schema
+func jsonBody(schema *openapi3.SchemaRef) *openapi3.RequestBodyRef {
+	return &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchemaRef(schema)}
+}
+
+// jsonResponse is a response with a JSON body matching schema
+func jsonResponse(description string, schema *openapi3.SchemaRef) *openapi3.ResponseRef {
+	return &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription(description).WithJSONSchemaRef(schema)}
+}
+
+// responses pairs the successful response of an operation with the shared
+// error responses of the given statuses
+func responses(status int, success *openapi3.ResponseRef, errorStatuses ...int) *openapi3.Responses {
+	options := []openapi3.NewResponsesOption{openapi3.WithStatus(status, success)}
+	for _, errorStatus := range errorStatuses {
+		ref := &openapi3.ResponseRef{Ref: "#/components/responses/" + errorResponses[errorStatus].name}
+		options = append(options, openapi3.WithStatus(errorStatus, ref))
+	}
+	return openapi3.NewResponses(options...)
+}
+
+// headerRef describes a response header
+func headerRef(description string, schema *openapi3.Schema) *openapi3.HeaderRef {
+	return &openapi3.HeaderRef{Value: &openapi3.Header{Parameter: openapi3.Parameter{
+		Description: description,
+		Schema:      schema.NewRef(),
+	}}}
+}
+
+// shortLinkResponse is a response to POST /shorten, the short URL is also in
+// the Location header
+func shortLinkResponse(description string) *openapi3.ResponseRef {
+	response := openapi3.NewResponse().WithDescription(description).WithJSONSchemaRef(schemaRef("ShortenResponse"))
+	response.Headers = openapi3.Headers{"Location": headerRef("The short URL", openapi3.NewStringSchema().WithFormat("uri"))}
+	return &openapi3.ResponseRef{Value: response}
+}
+
+// linkFields are the properties shared by the schemas describing a link
+func linkFields() *openapi3.Schema {
+	return openapi3.NewObjectSchema().
+		WithProperty("short_code", openapi3.NewStringSchema()).
+		WithProperty("original_url", openapi3.NewStringSchema().WithFormat("uri")).
+		WithProperty("created_at", openapi3.NewDateTimeSchema())
+}
+
+// newAPISpec builds the OpenAPI 3.0 description of the routes of newRouter.
+// TestOpenAPISpec fails when it and the router disagree
+func newAPISpec() *openapi3.T {
+	originalSchema := described(openapi3.NewStringSchema().WithFormat("uri"), "URL to shorten, http or https")
+	aliasSchema := described(openapi3.NewStringSchema().WithPattern(aliasPattern.String()).WithMaxLength(aliasLengthCap),
+		fmt.Sprintf("Custom alias of %d to %d characters unless SNIPLINK_MAX_ALIAS_LENGTH says otherwise, "+
+			"a random code is generated when empty", minAliasLength, defaultMaxAliasLength))
+	linkList := openapi3.NewArraySchema()
+	linkList.Items = schemaRef("ListedLink")
+
+	urlPair := openapi3.NewObjectSchema().
+		WithProperty("original", originalSchema).
+		WithProperty("short_code", aliasSchema).
+		WithProperty("expires_in", described(openapi3.NewInt64Schema().WithMin(1).WithMax(float64(maxExpiry/time.Second)),
+			"Lifetime in seconds, at most 100 years")).
+		WithProperty("ttl_seconds", described(openapi3.NewInt64Schema().WithMin(1).WithMax(float64(maxExpiry/time.Second)),
+			"Alias of expires_in")).
+		WithProperty("expires_at", described(openapi3.NewDateTimeSchema(),
+			"Absolute expiry at most 100 years ahead, exclusive with expires_in and ttl_seconds")).
+		WithProperty("namespace", described(openapi3.NewStringSchema().WithPattern(namespacePattern.String()),
+			"Serves the link under /{namespace}/{code}")).
+		WithProperty("permanent", described(openapi3.NewBoolSchema(),
+			"Redirect with 301 instead of the configured status. Permanent links cannot expire or change their destination")).
+		WithRequired([]string{"original"}).
+		WithoutAdditionalProperties()
+
+	shortenForm := described(openapi3.NewObjectSchema().
+		WithProperty("original", originalSchema).
+		WithProperty("short_code", aliasSchema).
+		WithRequired([]string{"original"}),
+		"Form encoded variant of URLPair for plain HTML forms, other fields are ignored")
+
+	shortenResponse := openapi3.NewObjectSchema().
+		WithProperty("short_code", openapi3.NewStringSchema()).
+		WithProperty("short_url", openapi3.NewStringSchema().WithFormat("uri")).
+		WithProperty("expires_at", openapi3.NewDateTimeSchema()).
+		WithProperty("created_at", openapi3.NewDateTimeSchema()).
+		WithRequired([]string{"short_code", "short_url"})
+
+	batchResult := described(openapi3.NewObjectSchema().
+		WithProperty("original", openapi3.NewStringSchema()).
+		WithProperty("short_code", openapi3.NewStringSchema()).
+		WithProperty("short_url", openapi3.NewStringSchema().WithFormat("uri")).
+		WithProperty("expires_at", openapi3.NewDateTimeSchema()).
+		WithProperty("created_at", openapi3.NewDateTimeSchema()).
+		WithProperty("error", openapi3.NewStringSchema()).
+		WithPropertyRef("code", schemaRef("ErrorCode")).
+		WithRequired([]string{"original"}),
+		"Either the link fields or error and code are set")
+
+	webhookRequest := openapi3.NewObjectSchema().
+		WithProperty("short_code", openapi3.NewStringSchema()).
+		WithProperty("namespace", openapi3.NewStringSchema().WithPattern(namespacePattern.String())).
+		WithProperty("target_url", described(openapi3.NewStringSchema().WithFormat("uri"), "URL posted to on every click")).
+		WithRequired([]string{"short_code", "target_url"}).
+		WithoutAdditionalProperties()
+
+	webhookPayload := openapi3.NewObjectSchema().
+		WithProperty("short_code", openapi3.NewStringSchema()).
+		WithProperty("original_url", openapi3.NewStringSchema().WithFormat("uri")).
+		WithProperty("timestamp", openapi3.NewDateTimeSchema()).
+		WithRequired([]string{"short_code", "original_url", "timestamp"})
+
+	logLevelSchema := openapi3.NewObjectSchema().
+		WithProperty("level", openapi3.NewStringSchema().WithEnum("debug", "info", "warn", "error", "dpanic", "panic", "fatal")).
+		WithRequired([]string{"level"}).
+		WithoutAdditionalProperties()
+
+	updateRequest := openapi3.NewObjectSchema().
+		WithProperty("original", openapi3.NewStringSchema().WithFormat("uri")).
+		WithRequired([]string{"original"}).
+		WithoutAdditionalProperties()
+
+	linkEntry := linkFields().
+		WithProperty("short_url", openapi3.NewStringSchema().WithFormat("uri")).
+		WithRequired([]string{"short_code", "original_url", "short_url"})
+
+	listedLink := linkFields().
+		WithProperty("short_url", openapi3.NewStringSchema().WithFormat("uri")).
+		WithProperty("clicks", openapi3.NewInt64Schema().WithMin(0)).
+		WithRequired([]string{"short_code", "original_url", "short_url", "clicks"})
+
+	linkPage := openapi3.NewObjectSchema().
+		WithProperty("data", linkList).
+		WithProperty("total", openapi3.NewIntegerSchema()).
+		WithProperty("page", described(openapi3.NewIntegerSchema(), "Set for page based requests")).
+		WithProperty("per_page", described(openapi3.NewIntegerSchema(), "Set for page based requests")).
+		WithProperty("offset", openapi3.NewIntegerSchema().WithMin(0)).
+		WithProperty("limit", described(openapi3.NewIntegerSchema(), "Set for offset based requests")).
+		WithRequired([]string{"data", "total", "offset"})
+
+	searchResult := openapi3.NewObjectSchema().
+		WithProperty("data", linkList).
+		WithProperty("total", described(openapi3.NewIntegerSchema(), "Every match, including those past the limit")).
+		WithRequired([]string{"data", "total"})
+
+	stats := openapi3.NewObjectSchema().
+		WithProperty("short_code", openapi3.NewStringSchema()).
+		WithProperty("original", openapi3.NewStringSchema().WithFormat("uri")).
+		WithProperty("clicks", openapi3.NewInt64Schema().WithMin(0)).
+		WithProperty("human_clicks", described(openapi3.NewInt64Schema().WithMin(0), "Clicks from browsers")).
+		WithProperty("bot_clicks", described(openapi3.NewInt64Schema().WithMin(0),
+			"Clicks from crawlers and HTTP libraries, told apart by their User-Agent")).
+		WithRequired([]string{"short_code", "original", "clicks", "human_clicks", "bot_clicks"})
+
+	clickEvent := openapi3.NewObjectSchema().
+		WithProperty("time", openapi3.NewDateTimeSchema()).
+		WithProperty("referrer", openapi3.NewStringSchema()).
+		WithProperty("bot", described(openapi3.NewBoolSchema(), "Set when the User-Agent looks automated")).
+		WithRequired([]string{"time"})
+
+	preview := linkFields().
+		WithRequired([]string{"short_code", "original_url"})
+
+	info := linkFields().
+		WithProperty("expires_at", openapi3.NewDateTimeSchema()).
+		WithProperty("clicks", openapi3.NewInt64Schema().WithMin(0)).
+		WithRequired([]string{"short_code", "original_url", "clicks"})
+
+	health := openapi3.NewObjectSchema().
+		WithProperty("status", openapi3.NewStringSchema().WithEnum("ok", "unavailable")).
+		WithProperty("uptime_seconds", openapi3.NewFloat64Schema()).
+		WithRequired([]string{"status", "uptime_seconds"})
+
+	errorSchema := openapi3.NewObjectSchema().
+		WithProperty("error", described(openapi3.NewStringSchema(), "Human readable message")).
+		WithPropertyRef("code", schemaRef("ErrorCode")).
+		WithRequired([]string{"error", "code"})
+
+	batch := func(summary string) *openapi3.Operation {
+		items := openapi3.NewArraySchema().WithMaxItems(maxBatchSize)
+		items.Items = schemaRef("URLPair")
+		results := openapi3.NewArraySchema()
+		results.Items = schemaRef("BatchResult")
+		return &openapi3.Operation{
+			Summary:     summary,
+			Security:    bearerAuth(),
+			RequestBody: &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchema(items)},
+			Responses: responses(http.StatusOK,
+				&openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("One result per item, in order").WithJSONSchema(results)},
+				http.StatusBadRequest, http.StatusUnauthorized, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType,
+				http.StatusTooManyRequests, http.StatusInternalServerError),
+		}
+	}
+
+	update := &openapi3.Operation{
+		Summary:     "Change the destination of a link",
+		Security:    bearerAuth(),
+		RequestBody: jsonBody(schemaRef("UpdateRequest")),
+		Responses: responses(http.StatusOK, jsonResponse("The updated link", schemaRef("LinkEntry")),
+			http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict,
+			http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusTooManyRequests, http.StatusInternalServerError),
+	}
+
+	remove := &openapi3.Operation{
+		Summary:  "Delete a link",
+		Security: bearerAuth(),
+		Responses: responses(http.StatusNoContent,
+			&openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("Deleted")},
+			http.StatusUnauthorized, http.StatusNotFound, http.StatusTooManyRequests, http.StatusInternalServerError),
+	}
+
+	qr := &openapi3.Operation{
+		Summary: "QR code of the short URL",
+		Parameters: openapi3.Parameters{
+			codeParam(),
+			queryParam("size", openapi3.NewIntegerSchema().WithMin(minQRSize).WithMax(maxQRSize).WithDefault(defaultQRSize)),
+		},
+		Responses: responses(http.StatusOK,
+			&openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("PNG image").
+				WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema().WithFormat("binary"), []string{"image/png"}))},
+			http.StatusBadRequest, http.StatusNotFound, http.StatusGone, http.StatusInternalServerError),
+	}
+
+	shortenResponses := responses(http.StatusCreated, shortLinkResponse("The new short link"),
+		http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict,
+		http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusTooManyRequests, http.StatusInternalServerError)
+	shortenResponses.Set(strconv.Itoa(http.StatusOK),
+		shortLinkResponse("An existing link to the same URL, reused when deduplication is on, or the link a dry run would create"))
+
+	webhookResponses := responses(http.StatusCreated, jsonResponse("The new webhook", schemaRef("WebhookRequest")),
+		http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict,
+		http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusTooManyRequests, http.StatusInternalServerError)
+	webhookResponses.Set(strconv.Itoa(http.StatusOK), jsonResponse("The webhook, already registered", schemaRef("WebhookRequest")))
+	webhookCallback := openapi3.NewCallback(openapi3.WithCallback("{$request.body#/target_url}", &openapi3.PathItem{
+		Post: &openapi3.Operation{
+			Summary:     "Posted in the background on every click of the link",
+			RequestBody: jsonBody(schemaRef("WebhookPayload")),
+			Responses: openapi3.NewResponses(
+				openapi3.WithStatus(http.StatusOK, &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("Any 2xx status")}),
+			),
+		},
+	}))
+
+	shortenBody := openapi3.NewRequestBody().WithRequired(true).WithJSONSchemaRef(schemaRef("URLPair"))
+	shortenBody.Content["application/x-www-form-urlencoded"] = openapi3.NewMediaType().WithSchemaRef(schemaRef("ShortenForm"))
+
+	redirectResponses := responses(http.StatusOK, jsonResponse(
+		"The destination as JSON, for clients whose Accept header prefers application/json over text/html",
+		schemaRef("Preview")),
+		http.StatusNotFound, http.StatusGone, http.StatusInternalServerError)
+	for _, status := range []int{http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect} {
+		redirect := openapi3.NewResponse().
+			WithDescription("Redirect to the original URL, 301 for permanent links and the configured status otherwise")
+		redirect.Headers = openapi3.Headers{"Location": headerRef("", openapi3.NewStringSchema().WithFormat("uri"))}
+		redirectResponses.Set(strconv.Itoa(status), &openapi3.ResponseRef{Value: redirect})
+	}
+
+	eventsList := openapi3.NewArraySchema()
+	eventsList.Items = schemaRef("ClickEvent")
+
+	listResponse := openapi3.NewResponse().WithDescription("One page of links").WithJSONSchemaRef(schemaRef("LinkPage"))
+	listResponse.Headers = openapi3.Headers{"X-Total-Count": headerRef("Number of stored links", openapi3.NewIntegerSchema())}
+
+	searchQuery := openapi3.NewQueryParameter("q").WithRequired(true).WithSchema(openapi3.NewStringSchema().WithMinLength(1))
+	searchQuery.Description = "Case-insensitive substring of the original URL"
+
+	spec := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:   "SnipLink",
+			Version: "1.0.0",
+			Description: "URL shortener API. Every path with {code} also answers under /{namespace}/{code} for namespaced links. " +
+				"Write endpoints require the API key when one is configured.",
+		},
+		Paths: openapi3.NewPaths(
+			openapi3.WithPath("/", &openapi3.PathItem{
+				Get: &openapi3.Operation{
+					Summary: "Landing page with a form that shortens URLs",
+					Responses: openapi3.NewResponses(openapi3.WithStatus(http.StatusOK, &openapi3.ResponseRef{
+						Value: openapi3.NewResponse().WithDescription("HTML page").
+							WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/html"})),
+					})),
+				},
+			}),
+			openapi3.WithPath("/shorten", &openapi3.PathItem{
+				Post: &openapi3.Operation{
+					Summary:  "Shorten a URL",
+					Security: bearerAuth(),
+					Parameters: openapi3.Parameters{
+						queryParam("dry_run", described(openapi3.NewBoolSchema().WithDefault(false),
+							"Validate and answer the code the link would get without storing it")),
+					},
+					RequestBody: &openapi3.RequestBodyRef{Value: shortenBody},
+					Responses:   shortenResponses,
+				},
+			}),
+			openapi3.WithPath("/admin/log-level", &openapi3.PathItem{
+				Put: &openapi3.Operation{
+					Summary:     "Change the log level without a restart",
+					Description: "Only served when an API key is configured, 404 otherwise",
+					Security:    bearerAuth(),
+					RequestBody: jsonBody(schemaRef("LogLevel")),
+					Responses: responses(http.StatusOK, jsonResponse("The new level", schemaRef("LogLevel")),
+						http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusRequestEntityTooLarge,
+						http.StatusUnsupportedMediaType, http.StatusTooManyRequests),
+				},
+			}),
+			openapi3.WithPath("/webhooks", &openapi3.PathItem{
+				Post: &openapi3.Operation{
+					Summary:     "Register a webhook for the clicks of a link",
+					Description: "Only served when an API key is configured, 404 otherwise",
+					Security:    bearerAuth(),
+					RequestBody: jsonBody(schemaRef("WebhookRequest")),
+					Responses:   webhookResponses,
+					Callbacks:   openapi3.Callbacks{"click": {Value: webhookCallback}},
+				},
+			}),
+			openapi3.WithPath("/shorten/batch", &openapi3.PathItem{Post: batch("Shorten several URLs")}),
+			openapi3.WithPath("/shorten/bulk", &openapi3.PathItem{Post: batch("Alias of /shorten/batch")}),
+			openapi3.WithPath("/shorten/{code}", &openapi3.PathItem{
+				Parameters: openapi3.Parameters{codeParam()},
+				Put:        update,
+				Delete:     remove,
+			}),
+			openapi3.WithPath("/{code}", &openapi3.PathItem{
+				Parameters: openapi3.Parameters{codeParam()},
+				Get:        &openapi3.Operation{Summary: "Follow a short link", Responses: redirectResponses},
+				Put:        update,
+				Delete:     remove,
+			}),
+			openapi3.WithPath("/{code}/qr", &openapi3.PathItem{Get: qr}),
+			openapi3.WithPath("/qr/{code}", &openapi3.PathItem{Get: qr}),
+			openapi3.WithPath("/{code}/info", &openapi3.PathItem{
+				Parameters: openapi3.Parameters{codeParam()},
+				Get: &openapi3.Operation{
+					Summary: "Describe a link without following it",
+					Responses: responses(http.StatusOK, jsonResponse("The link and its clicks", schemaRef("Info")),
+						http.StatusNotFound, http.StatusInternalServerError),
+				},
+			}),
+			openapi3.WithPath("/stats/{code}", &openapi3.PathItem{
+				Parameters: openapi3.Parameters{codeParam()},
+				Get: &openapi3.Operation{
+					Summary: "Click count of a link",
+					Responses: responses(http.StatusOK, jsonResponse("The click count", schemaRef("Stats")),
+						http.StatusNotFound, http.StatusInternalServerError),
+				},
+			}),
+			openapi3.WithPath("/stats/{code}/events", &openapi3.PathItem{
+				Parameters: openapi3.Parameters{codeParam()},
+				Get: &openapi3.Operation{
+					Summary: "Latest clicks of a link, newest first",
+					Responses: responses(http.StatusOK,
+						&openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("The latest clicks").WithJSONSchema(eventsList)},
+						http.StatusNotFound, http.StatusInternalServerError),
+				},
+			}),
+			openapi3.WithPath("/preview/{code}", &openapi3.PathItem{
+				Parameters: openapi3.Parameters{codeParam()},
+				Get: &openapi3.Operation{
+					Summary: "Destination of a link without following it",
+					Responses: responses(http.StatusOK, jsonResponse("The destination", schemaRef("Preview")),
+						http.StatusNotFound, http.StatusGone, http.StatusInternalServerError),
+				},
+			}),
+			openapi3.WithPath("/links", &openapi3.PathItem{
+				Get: &openapi3.Operation{
+					Summary:  "List links, newest first",
+					Security: bearerAuth(),
+					Parameters: openapi3.Parameters{
+						queryParam("page", openapi3.NewIntegerSchema().WithMin(1).WithDefault(1)),
+						queryParam("per_page", openapi3.NewIntegerSchema().WithMin(1).WithMax(maxPerPage).WithDefault(defaultPerPage)),
+						queryParam("offset", described(openapi3.NewIntegerSchema().WithMin(0).WithDefault(0),
+							"Links to skip, cannot be combined with page and per_page")),
+						queryParam("limit", described(openapi3.NewIntegerSchema().WithMin(1).WithMax(maxPerPage).WithDefault(defaultPerPage),
+							"Links to return, cannot be combined with page and per_page")),
+					},
+					Responses: responses(http.StatusOK, &openapi3.ResponseRef{Value: listResponse},
+						http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusInternalServerError),
+				},
+			}),
+			openapi3.WithPath("/links/search", &openapi3.PathItem{
+				Get: &openapi3.Operation{
+					Summary:  "Find links by original URL",
+					Security: bearerAuth(),
+					Parameters: openapi3.Parameters{
+						{Value: searchQuery},
+						queryParam("limit", openapi3.NewIntegerSchema().WithMin(1).WithMax(maxSearchLimit).WithDefault(defaultSearchLimit)),
+					},
+					Responses: responses(http.StatusOK, jsonResponse("Matching links, newest first", schemaRef("SearchResult")),
+						http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusInternalServerError),
+				},
+			}),
+			openapi3.WithPath("/healthz", &openapi3.PathItem{
+				Get: &openapi3.Operation{
+					Summary: "Liveness and readiness probe",
+					Responses: openapi3.NewResponses(
+						openapi3.WithStatus(http.StatusOK, jsonResponse("Ready", schemaRef("Health"))),
+						openapi3.WithStatus(http.StatusServiceUnavailable, jsonResponse("The store does not answer", schemaRef("Health"))),
+					),
+				},
+			}),
+			openapi3.WithPath("/metrics", &openapi3.PathItem{
+				Get: &openapi3.Operation{
+					Summary: "Prometheus metrics",
+					Responses: openapi3.NewResponses(openapi3.WithStatus(http.StatusOK, &openapi3.ResponseRef{
+						Value: openapi3.NewResponse().WithDescription("Metrics in the Prometheus text format").
+							WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/plain"})),
+					})),
+				},
+			}),
+			openapi3.WithPath("/favicon.ico", &openapi3.PathItem{
+				Get: &openapi3.Operation{
+					Summary: "Site icon, from SNIPLINK_FAVICON_FILE or a built-in SVG",
+					Responses: openapi3.NewResponses(
+						openapi3.WithStatus(http.StatusOK, &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("The icon").
+							WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema().WithFormat("binary"), []string{"image/svg+xml", "image/x-icon"}))}),
+					),
+				},
+			}),
+			openapi3.WithPath("/robots.txt", &openapi3.PathItem{
+				Get: &openapi3.Operation{
+					Summary: "Crawler rules, short links may be crawled and the API may not",
+					Responses: openapi3.NewResponses(openapi3.WithStatus(http.StatusOK, &openapi3.ResponseRef{
+						Value: openapi3.NewResponse().WithDescription("robots.txt").
+							WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/plain"})),
+					})),
+				},
+			}),
+			openapi3.WithPath("/openapi.json", &openapi3.PathItem{
+				Get: &openapi3.Operation{
+					Summary: "This document",
+					Responses: openapi3.NewResponses(openapi3.WithStatus(http.StatusOK, &openapi3.ResponseRef{
+						Value: openapi3.NewResponse().WithDescription("OpenAPI 3.0 document").
+							WithJSONSchema(&openapi3.Schema{Type: &openapi3.Types{openapi3.TypeObject}}),
+					})),
+				},
+			}),
+		),
+		Components: &openapi3.Components{
+			Schemas: openapi3.Schemas{
+				"URLPair":         urlPair.NewRef(),
+				"ShortenForm":     shortenForm.NewRef(),
+				"ShortenResponse": shortenResponse.NewRef(),
+				"BatchResult":     batchResult.NewRef(),
+				"UpdateRequest":   updateRequest.NewRef(),
+				"WebhookRequest":  webhookRequest.NewRef(),
+				"WebhookPayload":  webhookPayload.NewRef(),
+				"LogLevel":        logLevelSchema.NewRef(),
+				"LinkEntry":       linkEntry.NewRef(),
+				"ListedLink":      listedLink.NewRef(),
+				"LinkPage":        linkPage.NewRef(),
+				"SearchResult":    searchResult.NewRef(),
+				"Stats":           stats.NewRef(),
+				"ClickEvent":      clickEvent.NewRef(),
+				"Preview":         preview.NewRef(),
+				"Info":            info.NewRef(),
+				"Health":          health.NewRef(),
+				"ErrorCode":       openapi3.NewStringSchema().WithEnum(errorCodes...).NewRef(),
+				"Error":           errorSchema.NewRef(),
+			},
+			Parameters: openapi3.ParametersMap{
+				"code": {Value: openapi3.NewPathParameter("code").
+					WithDescription("Short code of the link").
+					WithSchema(openapi3.NewStringSchema())},
+			},
+			Responses: openapi3.ResponseBodies{},
+			SecuritySchemes: openapi3.SecuritySchemes{
+				"bearerAuth": {Value: openapi3.NewSecurityScheme().WithType("http").WithScheme("bearer").
+					WithDescription("SNIPLINK_API_KEY")},
+			},
+		},
+	}
+
+	for _, response := range errorResponses {
+		spec.Components.Responses[response.name] = jsonResponse(response.description, schemaRef("Error"))
+	}
+	spec.Components.Responses[errorResponses[http.StatusTooManyRequests].name].Value.Headers = openapi3.Headers{
+		"Retry-After": headerRef("Seconds to wait", openapi3.NewIntegerSchema()),
+	}
+	return spec
+}
+
+// described sets the description of schema and returns it
+func described(schema *openapi3.Schema, description string) *openapi3.Schema {
+	schema.Description = description
+	return schema
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order by initSchema, the schema version
+// stored in PRAGMA user_version is the number already applied
+var sqliteMigrations = []string{
+	`CREATE TABLE links (
+		short_code TEXT PRIMARY KEY,
+		original   TEXT NOT NULL,
+		expires_at INTEGER NOT NULL DEFAULT 0,
+		clicks     INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE INDEX links_original ON links (original)`,
+	`ALTER TABLE links ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE links ADD COLUMN permanent INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE links ADD COLUMN bot_clicks INTEGER NOT NULL DEFAULT 0`,
+}
+
+// sqliteStore is the URLStore kept in a SQLite database. Expiry and creation
+// times are stored as Unix nanoseconds, with zero standing for the zero time
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// newSQLiteStore opens the database at path, creating it and its directory
+// when missing, and brings its schema up to date
+func newSQLiteStore(path string) (*sqliteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// A single connection serializes writes, which avoids SQLITE_BUSY errors
+	// between the handlers
+	db.SetMaxOpenConns(1)
+
+	if err := initSchema(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// initSchema applies every migration the database has not seen yet
+func initSchema(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// Ping checks that the database can still be reached
+func (s *sqliteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Get returns the record stored under the given short code
+func (s *sqliteStore) Get(shortCode string) (URLRecord, bool, error) {
+	record, _, exists, err := s.Stats(shortCode)
+	return record, exists, err
+}
+
+// Resolve returns the record stored under the given short code and counts
+// it as a click, by a bot or not, unless the record has expired
+func (s *sqliteStore) Resolve(shortCode string, now time.Time, bot bool) (URLRecord, bool, error) {
+	record, exists, err := s.Get(shortCode)
+	if err != nil || !exists || record.expired(now) {
+		return record, exists, err
+	}
+	_, err = s.db.Exec(`UPDATE links SET clicks = clicks + 1, bot_clicks = bot_clicks + ? WHERE short_code = ?`, bot, shortCode)
+	return record, true, err
+}
+
+// Stats returns the record stored under the given short code and the number
+// of times it was resolved
+func (s *sqliteStore) Stats(shortCode string) (URLRecord, ClickCounts, bool, error) {
+	var (
+		record    URLRecord
+		expiresAt int64
+		createdAt int64
+		clicks    ClickCounts
+	)
+	err := s.db.QueryRow(`SELECT original, expires_at, created_at, permanent, clicks, bot_clicks FROM links WHERE short_code = ?`, shortCode).
+		Scan(&record.Original, &expiresAt, &createdAt, &record.Permanent, &clicks.Total, &clicks.Bot)
+	if errors.Is(err, sql.ErrNoRows) {
+		return URLRecord{}, ClickCounts{}, false, nil
+	}
+	if err != nil {
+		return URLRecord{}, ClickCounts{}, false, err
+	}
+	record.ExpiresAt = timeFromUnix(expiresAt)
+	record.CreatedAt = timeFromUnix(createdAt)
+	return record, clicks, true, nil
+}
+
+// LookupOriginal returns the short code of an unexpired link to originalURL
+func (s *sqliteStore) LookupOriginal(originalURL string, now time.Time) (string, bool, error) {
+	var shortCode string
+	err := s.db.QueryRow(`SELECT short_code FROM links WHERE original = ? AND (expires_at = 0 OR expires_at > ?) LIMIT 1`,
+		originalURL, now.UnixNano()).Scan(&shortCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return shortCode, true, nil
+}
+
+// Set stores the record under the given short code, keeping its click count
+// when the code already exists
+func (s *sqliteStore) Set(shortCode string, record URLRecord) error {
+	_, err := s.db.Exec(`INSERT INTO links (short_code, original, expires_at, created_at, permanent) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (short_code) DO UPDATE SET original = excluded.original, expires_at = excluded.expires_at,
+			created_at = excluded.created_at, permanent = excluded.permanent`,
+		shortCode, record.Original, timeToUnix(record.ExpiresAt), timeToUnix(record.CreatedAt), record.Permanent)
+	return err
+}
+
+// SetIfAbsent stores the record under the given short code unless the code
+// is already taken, and reports whether it was stored
+func (s *sqliteStore) SetIfAbsent(shortCode string, record URLRecord) (bool, error) {
+	result, err := s.db.Exec(`INSERT INTO links (short_code, original, expires_at, created_at, permanent) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (short_code) DO NOTHING`,
+		shortCode, record.Original, timeToUnix(record.ExpiresAt), timeToUnix(record.CreatedAt), record.Permanent)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// SetAllIfAbsent stores every record under its short code unless one of the
+// codes is taken, then it stores none and returns the taken codes
+func (s *sqliteStore) SetAllIfAbsent(records map[string]URLRecord) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var taken []string
+	for shortCode, record := range records {
+		result, err := tx.Exec(`INSERT INTO links (short_code, original, expires_at, created_at, permanent) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (short_code) DO NOTHING`,
+			shortCode, record.Original, timeToUnix(record.ExpiresAt), timeToUnix(record.CreatedAt), record.Permanent)
+		if err != nil {
+			return nil, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			taken = append(taken, shortCode)
+		}
+	}
+	if len(taken) > 0 {
+		return taken, nil
+	}
+	return nil, tx.Commit()
+}
+
+// UpdateOriginal points an existing short code at originalURL, keeping the
+// rest of its record and its clicks, and returns the updated record
+func (s *sqliteStore) UpdateOriginal(shortCode, originalURL string) (URLRecord, bool, error) {
+	var (
+		record    URLRecord
+		expiresAt int64
+		createdAt int64
+	)
+	err := s.db.QueryRow(`UPDATE links SET original = ? WHERE short_code = ? RETURNING original, expires_at, created_at, permanent`,
+		originalURL, shortCode).Scan(&record.Original, &expiresAt, &createdAt, &record.Permanent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return URLRecord{}, false, nil
+	}
+	if err != nil {
+		return URLRecord{}, false, err
+	}
+	record.ExpiresAt = timeFromUnix(expiresAt)
+	record.CreatedAt = timeFromUnix(createdAt)
+	return record, true, nil
+}
+
+// Delete removes the mapping for the given short code and reports whether it existed
+func (s *sqliteStore) Delete(shortCode string) (bool, error) {
+	result, err := s.db.Exec(`DELETE FROM links WHERE short_code = ?`, shortCode)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteExpired removes every record that has expired by now and returns
+// their short codes
+func (s *sqliteStore) DeleteExpired(now time.Time) ([]string, error) {
+	rows, err := s.db.Query(`DELETE FROM links WHERE expires_at != 0 AND expires_at <= ? RETURNING short_code`, now.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var removed []string
+	for rows.Next() {
+		var shortCode string
+		if err := rows.Scan(&shortCode); err != nil {
+			return nil, err
+		}
+		removed = append(removed, shortCode)
+	}
+	return removed, rows.Err()
+}
+
+// Count returns the number of stored mappings
+func (s *sqliteStore) Count() (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM links`).Scan(&n)
+	return n, err
+}
+
+// List returns a copy of every stored mapping
+func (s *sqliteStore) List() (map[string]URLRecord, error) {
+	rows, err := s.db.Query(`SELECT short_code, original, expires_at, created_at, permanent FROM links`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := make(map[string]URLRecord)
+	for rows.Next() {
+		var (
+			shortCode string
+			record    URLRecord
+			expiresAt int64
+			createdAt int64
+		)
+		if err := rows.Scan(&shortCode, &record.Original, &expiresAt, &createdAt, &record.Permanent); err != nil {
+			return nil, err
+		}
+		record.ExpiresAt = timeFromUnix(expiresAt)
+		record.CreatedAt = timeFromUnix(createdAt)
+		m[shortCode] = record
+	}
+	return m, rows.Err()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	openTemp := func(t *testing.T, path string) *sqliteStore {
+		s, err := newSQLiteStore(path)
+		should.BeNil(t, err, should.WithMessage("Database should open"))
+		t.Cleanup(func() { s.Close() })
+		return s
+	}
+
+	testURLStore(t, func(t *testing.T) URLStore {
+		return openTemp(t, filepath.Join(t.TempDir(), "urls.db"))
+	})
+
+	t.Run("should keep links across reopening", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "data", "urls.db")
+		first := openTemp(t, path)
+		first.Set("abc123", URLRecord{Original: "https://example.com"})
+		first.Close()
+
+		second := openTemp(t, path)
+		record, exists, _ := second.Get("abc123")
+		should.BeTrue(t, exists, should.WithMessage("Stored link should survive a restart"))
+		should.BeEqual(t, record.Original, "https://example.com")
+	})
+
+	t.Run("should record the schema version", func(t *testing.T) {
+		s := openTemp(t, filepath.Join(t.TempDir(), "urls.db"))
+		should.BeNil(t, initSchema(s.db), should.WithMessage("Running migrations twice should be a no-op"))
+
+		var version int
+		s.db.QueryRow(`PRAGMA user_version`).Scan(&version)
+		should.BeEqual(t, version, len(sqliteMigrations))
+	})
+}
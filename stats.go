@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// statsResponse is the body returned by statsHandler
+type statsResponse struct {
+	ShortCode   string `json:"short_code"`
+	OriginalURL string `json:"original"`
+	Clicks      uint64 `json:"clicks"`
+	// HumanClicks and BotClicks split Clicks by isBot
+	HumanClicks uint64 `json:"human_clicks"`
+	BotClicks   uint64 `json:"bot_clicks"`
+}
+
+// statsHandler reports how many times the short link named by
+// /stats/{code} was followed, by people and by bots
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := shortCodeParam(r)
+	record, clicks, exists, err := store.Stats(shortCode)
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+	if !exists {
+		errorResponse(w, "Short code not found", errCodeNotFound, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statsResponse{
+		ShortCode:   shortCode,
+		OriginalURL: record.Original,
+		Clicks:      clicks.Total,
+		HumanClicks: clicks.Human(),
+		BotClicks:   clicks.Bot,
+	})
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestStatsHandler(t *testing.T) {
+	getStats := func(shortCode string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/stats/"+shortCode, nil)
+		w := httptest.NewRecorder()
+		route(w, req)
+		return w
+	}
+
+	t.Run("should start counting at zero", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := getStats("abc123")
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json")
+
+		var response statsResponse
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeNil(t, err, should.WithMessage("Response should be valid JSON"))
+		should.BeEqual(t, response, statsResponse{ShortCode: "abc123", OriginalURL: "https://example.com", Clicks: 0})
+		should.ContainSubstring(t, w.Body.String(), `"original":"https://example.com"`)
+	})
+
+	t.Run("should count every redirect", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		for i := 0; i < 3; i++ {
+			route(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abc123", nil))
+		}
+
+		var response statsResponse
+		json.Unmarshal(getStats("abc123").Body.Bytes(), &response)
+		should.BeEqual(t, response.Clicks, uint64(3), should.WithMessage("Each redirect should increment the counter"))
+	})
+
+	t.Run("should count concurrent redirects exactly", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				route(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abc123", nil))
+			}()
+		}
+		wg.Wait()
+
+		var response statsResponse
+		json.Unmarshal(getStats("abc123").Body.Bytes(), &response)
+		should.BeEqual(t, response.Clicks, uint64(100), should.WithMessage("No click should be lost under concurrent load"))
+	})
+
+	t.Run("should count human and bot clicks apart", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		for _, userAgent := range []string{
+			"Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0",
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			"curl/8.5.0",
+		} {
+			req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+			req.Header.Set("User-Agent", userAgent)
+			route(httptest.NewRecorder(), req)
+		}
+
+		var response statsResponse
+		json.Unmarshal(getStats("abc123").Body.Bytes(), &response)
+		should.BeEqual(t, response.Clicks, uint64(3))
+		should.BeEqual(t, response.HumanClicks, uint64(1))
+		should.BeEqual(t, response.BotClicks, uint64(2))
+	})
+
+	t.Run("should keep the human and bot clicks of dropped click events", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		req.Header.Set("User-Agent", "curl/8.5.0")
+		route(httptest.NewRecorder(), req)
+		clickEvents.forget("abc123")
+
+		var response statsResponse
+		json.Unmarshal(getStats("abc123").Body.Bytes(), &response)
+		should.BeEqual(t, response.BotClicks, uint64(1), should.WithMessage("The store should count clicks, not the click log"))
+	})
+
+	t.Run("should not count requests for unknown codes", func(t *testing.T) {
+		resetStore()
+
+		route(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+		store.Set("missing", URLRecord{Original: "https://example.com"})
+
+		var response statsResponse
+		json.Unmarshal(getStats("missing").Body.Bytes(), &response)
+		should.BeEqual(t, response.Clicks, uint64(0), should.WithMessage("Misses should not be recorded as clicks"))
+	})
+
+	t.Run("should return not found for non-existent short code", func(t *testing.T) {
+		resetStore()
+
+		w := getStats("missing")
+
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeEqual(t, response["error"], "Short code not found")
+	})
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"cmp"
+	"container/list"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
+
+// URLRecord is what the store keeps for each short code
+type URLRecord struct {
+	Original  string    `json:"original"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	// CreatedAt is zero for links saved before creation times were recorded
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	// Permanent links redirect with 301 whatever SNIPLINK_REDIRECT_STATUS says
+	Permanent bool `json:"permanent,omitempty"`
+}
+
+// ClickCounts are the times a short code was resolved, Bot of them by
+// clients isBot recognized
+type ClickCounts struct {
+	Total uint64
+	Bot   uint64
+}
+
+// Human returns the clicks that did not come from bots
+func (c ClickCounts) Human() uint64 {
+	return c.Total - c.Bot
+}
+
+// add returns the counts with one more click, by a bot or not
+func (c ClickCounts) add(bot bool) ClickCounts {
+	c.Total++
+	if bot {
+		c.Bot++
+	}
+	return c
+}
+
+// expired reports whether the record has an expiry that is not after now
+func (rec URLRecord) expired(now time.Time) bool {
+	return !rec.ExpiresAt.IsZero() && !now.Before(rec.ExpiresAt)
+}
+
+// timeToUnix encodes t as Unix nanoseconds for the database backed stores,
+// the zero time encodes as 0
+func timeToUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// timeFromUnix decodes a time written by timeToUnix
+func timeFromUnix(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// UnmarshalJSON also accepts a bare URL string, the format data files used
+// before records carried an expiry
+func (rec *URLRecord) UnmarshalJSON(data []byte) error {
+	var original string
+	if err := json.Unmarshal(data, &original); err == nil {
+		*rec = URLRecord{Original: original}
+		return nil
+	}
+
+	type plain URLRecord
+	return json.Unmarshal(data, (*plain)(rec))
+}
+
+// URLStore is implemented by every storage backend for short links. Short
+// codes map to a record and its ClickCounts, and LookupOriginal finds the code
+// of an existing link to the same URL. SetAllIfAbsent stores a batch of
+// records all at once or, when some of their codes are taken, not at all
+// and returns the taken codes. Errors report a failing backend, a missing
+// code is not an error
+type URLStore interface {
+	Get(shortCode string) (URLRecord, bool, error)
+	Set(shortCode string, record URLRecord) error
+	SetIfAbsent(shortCode string, record URLRecord) (bool, error)
+	SetAllIfAbsent(records map[string]URLRecord) ([]string, error)
+	UpdateOriginal(shortCode, originalURL string) (URLRecord, bool, error)
+	Delete(shortCode string) (bool, error)
+	List() (map[string]URLRecord, error)
+	Count() (int, error)
+	Resolve(shortCode string, now time.Time, bot bool) (URLRecord, bool, error)
+	Stats(shortCode string) (URLRecord, ClickCounts, bool, error)
+	LookupOriginal(originalURL string, now time.Time) (string, bool, error)
+	DeleteExpired(now time.Time) ([]string, error)
+}
+
+// urlStore is the in-memory URLStore. It holds the short code to URL record
+// mappings, their click counts and a reverse index from original URL to short
+// code, and guards them for concurrent access from the HTTP handlers. Its
+// methods never fail
+type urlStore struct {
+	mu         sync.RWMutex
+	m          map[string]URLRecord
+	clicks     map[string]ClickCounts
+	byOriginal map[string]string
+
+	// maxLinks bounds the number of mappings, past it the least recently
+	// stored or resolved ones are evicted. Zero keeps every mapping
+	maxLinks int
+	// recency orders the short codes from most to least recently used
+	recency *list.List
+	entries map[string]*list.Element
+	// onEvict, when set, is called with the short code of every evicted
+	// mapping while the write lock is held
+	onEvict func(shortCode string)
+}
+
+func newURLStore() *urlStore {
+	s := &urlStore{}
+	s.reset()
+	return s
+}
+
+// index points the reverse index for the record's original URL at shortCode,
+// the caller must hold the write lock
+func (s *urlStore) index(shortCode string, record URLRecord) {
+	s.byOriginal[record.Original] = shortCode
+}
+
+// unindex drops shortCode from the reverse index, the caller must hold the
+// write lock
+func (s *urlStore) unindex(shortCode string) {
+	record := s.m[shortCode]
+	if s.byOriginal[record.Original] == shortCode {
+		delete(s.byOriginal, record.Original)
+	}
+}
+
+// touch marks shortCode as the most recently used mapping, the caller must
+// hold the write lock
+func (s *urlStore) touch(shortCode string) {
+	if e, exists := s.entries[shortCode]; exists {
+		s.recency.MoveToFront(e)
+		return
+	}
+	s.entries[shortCode] = s.recency.PushFront(shortCode)
+}
+
+// remove drops shortCode with its clicks and index entry, the caller must
+// hold the write lock
+func (s *urlStore) remove(shortCode string) {
+	s.unindex(shortCode)
+	delete(s.m, shortCode)
+	delete(s.clicks, shortCode)
+	if e, exists := s.entries[shortCode]; exists {
+		s.recency.Remove(e)
+		delete(s.entries, shortCode)
+	}
+}
+
+// evict removes the least recently used mappings until at most maxLinks
+// remain, the caller must hold the write lock
+func (s *urlStore) evict() {
+	for s.maxLinks > 0 && len(s.m) > s.maxLinks {
+		shortCode := s.recency.Back().Value.(string)
+		s.remove(shortCode)
+		if s.onEvict != nil {
+			s.onEvict(shortCode)
+		}
+	}
+}
+
+// LookupOriginal returns the short code of an unexpired link to originalURL
+func (s *urlStore) LookupOriginal(originalURL string, now time.Time) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	shortCode, exists := s.byOriginal[originalURL]
+	if !exists || s.m[shortCode].expired(now) {
+		return "", false, nil
+	}
+	return shortCode, true, nil
+}
+
+// Get returns the record stored under the given short code
+func (s *urlStore) Get(shortCode string) (URLRecord, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, exists := s.m[shortCode]
+	return record, exists, nil
+}
+
+// Resolve returns the record stored under the given short code and counts
+// it as a click, by a bot or not, unless the record has expired
+func (s *urlStore) Resolve(shortCode string, now time.Time, bot bool) (URLRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, exists := s.m[shortCode]
+	if exists && !record.expired(now) {
+		s.clicks[shortCode] = s.clicks[shortCode].add(bot)
+		s.touch(shortCode)
+	}
+	return record, exists, nil
+}
+
+// Stats returns the record stored under the given short code and the number
+// of times it was resolved
+func (s *urlStore) Stats(shortCode string) (URLRecord, ClickCounts, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, exists := s.m[shortCode]
+	return record, s.clicks[shortCode], exists, nil
+}
+
+// Set stores the record under the given short code
+func (s *urlStore) Set(shortCode string, record URLRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unindex(shortCode)
+	s.m[shortCode] = record
+	s.index(shortCode, record)
+	s.touch(shortCode)
+	s.evict()
+	return nil
+}
+
+// SetIfAbsent stores the record under the given short code unless the code
+// is already taken, and reports whether it was stored
+func (s *urlStore) SetIfAbsent(shortCode string, record URLRecord) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.m[shortCode]; exists {
+		return false, nil
+	}
+	s.m[shortCode] = record
+	s.index(shortCode, record)
+	s.touch(shortCode)
+	s.evict()
+	return true, nil
+}
+
+// SetAllIfAbsent stores every record under its short code unless one of the
+// codes is taken, then it stores none and returns the taken codes
+func (s *urlStore) SetAllIfAbsent(records map[string]URLRecord) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var taken []string
+	for shortCode := range records {
+		if _, exists := s.m[shortCode]; exists {
+			taken = append(taken, shortCode)
+		}
+	}
+	if len(taken) > 0 {
+		return taken, nil
+	}
+	for shortCode, record := range records {
+		s.m[shortCode] = record
+		s.index(shortCode, record)
+		s.touch(shortCode)
+	}
+	s.evict()
+	return nil, nil
+}
+
+// UpdateOriginal points an existing short code at originalURL, keeping the
+// rest of its record and its clicks, and returns the updated record
+func (s *urlStore) UpdateOriginal(shortCode, originalURL string) (URLRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, exists := s.m[shortCode]
+	if !exists {
+		return URLRecord{}, false, nil
+	}
+	s.unindex(shortCode)
+	record.Original = originalURL
+	s.m[shortCode] = record
+	s.index(shortCode, record)
+	return record, true, nil
+}
+
+// Delete removes the mapping for the given short code and reports whether it existed
+func (s *urlStore) Delete(shortCode string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.m[shortCode]; !exists {
+		return false, nil
+	}
+	s.remove(shortCode)
+	return true, nil
+}
+
+// DeleteExpired removes every record that has expired by now and returns
+// their short codes
+func (s *urlStore) DeleteExpired(now time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed []string
+	for shortCode, record := range s.m {
+		if record.expired(now) {
+			s.remove(shortCode)
+			removed = append(removed, shortCode)
+		}
+	}
+	return removed, nil
+}
+
+// List returns a copy of every stored mapping
+func (s *urlStore) List() (map[string]URLRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m := make(map[string]URLRecord, len(s.m))
+	for shortCode, record := range s.m {
+		m[shortCode] = record
+	}
+	return m, nil
+}
+
+// Count returns the number of stored mappings
+func (s *urlStore) Count() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.m), nil
+}
+
+// load replaces every stored mapping with the given ones. Their use is not
+// saved, so they count as used in order of creation and the oldest are
+// evicted first when they do not fit
+func (s *urlStore) load(m map[string]URLRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m = m
+	s.clicks = make(map[string]ClickCounts)
+	s.byOriginal = make(map[string]string, len(m))
+	s.recency = list.New()
+	s.entries = make(map[string]*list.Element, len(m))
+
+	shortCodes := make([]string, 0, len(m))
+	for shortCode, record := range m {
+		s.index(shortCode, record)
+		shortCodes = append(shortCodes, shortCode)
+	}
+	slices.SortFunc(shortCodes, func(a, b string) int {
+		return cmp.Or(m[a].CreatedAt.Compare(m[b].CreatedAt), cmp.Compare(a, b))
+	})
+	for _, shortCode := range shortCodes {
+		s.touch(shortCode)
+	}
+	s.evict()
+}
+
+// reset removes every stored mapping
+func (s *urlStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m = make(map[string]URLRecord)
+	s.clicks = make(map[string]ClickCounts)
+	s.byOriginal = make(map[string]string)
+	s.recency = list.New()
+	s.entries = make(map[string]*list.Element)
+}
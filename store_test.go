@@ -0,0 +1,380 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+)
+
+// resetStore replaces the store and click log used by the handlers with
+// empty ones
+func resetStore() {
+	store = newURLStore()
+	clickEvents = newClickLog(maxClickEvents, maxTotalClickEvents)
+	webhooks = newWebhookRegistry()
+}
+
+// storedLinks returns every mapping in the store used by the handlers
+func storedLinks(t *testing.T) map[string]URLRecord {
+	t.Helper()
+	mappings, err := store.List()
+	should.BeNil(t, err)
+	return mappings
+}
+
+func TestURLStore(t *testing.T) {
+	testURLStore(t, func(t *testing.T) URLStore {
+		return newURLStore()
+	})
+
+	t.Run("should index loaded mappings", func(t *testing.T) {
+		s := newURLStore()
+		s.load(map[string]URLRecord{"abc123": {Original: "https://example.com"}})
+
+		shortCode, _, _ := s.LookupOriginal("https://example.com", time.Now())
+		should.BeEqual(t, shortCode, "abc123")
+	})
+
+	t.Run("should clear all mappings on reset", func(t *testing.T) {
+		s := newURLStore()
+		s.Set("abc123", URLRecord{Original: "https://example.com"})
+		s.reset()
+
+		_, exists, _ := s.Get("abc123")
+		should.BeFalse(t, exists, should.WithMessage("Reset should remove every mapping"))
+	})
+
+	t.Run("should evict the least recently used link past the limit", func(t *testing.T) {
+		var evicted []string
+		s := newURLStore()
+		s.maxLinks = 2
+		s.onEvict = func(shortCode string) { evicted = append(evicted, shortCode) }
+
+		s.Set("first", URLRecord{Original: "https://example.com/1"})
+		s.Set("second", URLRecord{Original: "https://example.com/2"})
+		s.Resolve("first", time.Now(), false)
+		s.Set("third", URLRecord{Original: "https://example.com/3"})
+
+		should.BeEqual(t, evicted, []string{"second"}, should.WithMessage("A redirect should keep a link in use"))
+		n, _ := s.Count()
+		should.BeEqual(t, n, 2)
+		_, exists, _ := s.LookupOriginal("https://example.com/2", time.Now())
+		should.BeFalse(t, exists, should.WithMessage("Evicted links should leave the index"))
+	})
+
+	t.Run("should not count expired redirects as use", func(t *testing.T) {
+		s := newURLStore()
+		s.maxLinks = 2
+		s.Set("expired", URLRecord{Original: "https://example.com/1", ExpiresAt: time.Now().Add(-time.Second)})
+		s.Set("live", URLRecord{Original: "https://example.com/2"})
+		s.Resolve("expired", time.Now(), false)
+		s.Set("new", URLRecord{Original: "https://example.com/3"})
+
+		_, exists, _ := s.Get("expired")
+		should.BeFalse(t, exists)
+	})
+
+	t.Run("should keep the newest links when loading more than the limit", func(t *testing.T) {
+		now := time.Now()
+		s := newURLStore()
+		s.maxLinks = 2
+		s.load(map[string]URLRecord{
+			"old":    {Original: "https://example.com/1", CreatedAt: now.Add(-2 * time.Hour)},
+			"middle": {Original: "https://example.com/2", CreatedAt: now.Add(-time.Hour)},
+			"new":    {Original: "https://example.com/3", CreatedAt: now},
+		})
+
+		mappings, _ := s.List()
+		should.HaveLength(t, mappings, 2)
+		_, exists, _ := s.Get("old")
+		should.BeFalse(t, exists, should.WithMessage("The oldest link should be evicted"))
+	})
+
+	t.Run("should keep every link without a limit", func(t *testing.T) {
+		s := newURLStore()
+		for i := 0; i < 100; i++ {
+			s.Set(fmt.Sprintf("code%d", i), URLRecord{Original: fmt.Sprintf("https://example.com/%d", i)})
+		}
+
+		n, _ := s.Count()
+		should.BeEqual(t, n, 100)
+	})
+}
+
+// testURLStore runs the behaviour every URLStore backend must share against
+// the stores returned by newStore
+func testURLStore(t *testing.T, newStore func(t *testing.T) URLStore) {
+	t.Run("should return stored URL", func(t *testing.T) {
+		s := newStore(t)
+		should.BeNil(t, s.Set("abc123", URLRecord{Original: "https://example.com"}))
+
+		record, exists, err := s.Get("abc123")
+		should.BeNil(t, err)
+		should.BeTrue(t, exists, should.WithMessage("Stored code should be found"))
+		should.BeEqual(t, record.Original, "https://example.com")
+	})
+
+	t.Run("should keep the creation time", func(t *testing.T) {
+		createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		s := newStore(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com", CreatedAt: createdAt})
+
+		record, _, _ := s.Get("abc123")
+		should.BeTrue(t, record.CreatedAt.Equal(createdAt), should.WithMessage("Creation time should round trip"))
+	})
+
+	t.Run("should keep the permanent flag", func(t *testing.T) {
+		s := newStore(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com", Permanent: true})
+		s.Set("def456", URLRecord{Original: "https://example.org"})
+
+		record, _, _ := s.Get("abc123")
+		should.BeTrue(t, record.Permanent, should.WithMessage("Permanent flag should round trip"))
+		record, _, _ = s.Resolve("abc123", time.Now(), false)
+		should.BeTrue(t, record.Permanent, should.WithMessage("Resolve should return the flag"))
+		record, _, _ = s.UpdateOriginal("abc123", "https://example.net")
+		should.BeTrue(t, record.Permanent, should.WithMessage("Update should keep the flag"))
+		mappings, _ := s.List()
+		should.BeTrue(t, mappings["abc123"].Permanent)
+		should.BeFalse(t, mappings["def456"].Permanent)
+	})
+
+	t.Run("should report missing codes", func(t *testing.T) {
+		s := newStore(t)
+
+		_, exists, _ := s.Get("missing")
+		should.BeFalse(t, exists, should.WithMessage("Unknown code should not be found"))
+	})
+
+	t.Run("should not overwrite an existing code with SetIfAbsent", func(t *testing.T) {
+		s := newStore(t)
+		stored, err := s.SetIfAbsent("abc123", URLRecord{Original: "https://example.com"})
+		should.BeNil(t, err)
+		should.BeTrue(t, stored)
+		stored, _ = s.SetIfAbsent("abc123", URLRecord{Original: "https://other.com"})
+		should.BeFalse(t, stored, should.WithMessage("Taken code should be rejected"))
+
+		record, _, _ := s.Get("abc123")
+		should.BeEqual(t, record.Original, "https://example.com")
+	})
+
+	t.Run("should store a batch all at once or not at all", func(t *testing.T) {
+		s := newStore(t)
+		taken, err := s.SetAllIfAbsent(map[string]URLRecord{
+			"abc123": {Original: "https://example.com/1"},
+			"def456": {Original: "https://example.com/2", ExpiresAt: time.Now().Add(time.Hour)},
+		})
+		should.BeNil(t, err)
+		should.BeEmpty(t, taken)
+
+		taken, err = s.SetAllIfAbsent(map[string]URLRecord{
+			"def456": {Original: "https://other.com/2"},
+			"ghi789": {Original: "https://other.com/3"},
+		})
+		should.BeNil(t, err)
+		should.BeEqual(t, taken, []string{"def456"})
+
+		_, exists, _ := s.Get("ghi789")
+		should.BeFalse(t, exists, should.WithMessage("Nothing should be stored when a code is taken"))
+		record, _, _ := s.Get("def456")
+		should.BeEqual(t, record.Original, "https://example.com/2")
+		shortCode, _, _ := s.LookupOriginal("https://example.com/1", time.Now())
+		should.BeEqual(t, shortCode, "abc123")
+	})
+
+	t.Run("should update the destination of existing codes only", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		s := newStore(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com", ExpiresAt: expiresAt})
+		s.Resolve("abc123", time.Now(), false)
+
+		record, exists, _ := s.UpdateOriginal("abc123", "https://example.org")
+		should.BeTrue(t, exists, should.WithMessage("Existing code should be updated"))
+		should.BeEqual(t, record.Original, "https://example.org")
+		should.BeTrue(t, record.ExpiresAt.Equal(expiresAt), should.WithMessage("Update should keep the expiry"))
+
+		_, clicks, _, _ := s.Stats("abc123")
+		should.BeEqual(t, clicks.Total, uint64(1), should.WithMessage("Update should keep the clicks"))
+		shortCode, _, _ := s.LookupOriginal("https://example.org", time.Now())
+		should.BeEqual(t, shortCode, "abc123")
+		_, exists, _ = s.LookupOriginal("https://example.com", time.Now())
+		should.BeFalse(t, exists, should.WithMessage("The old URL should leave the index"))
+
+		_, exists, _ = s.UpdateOriginal("missing", "https://example.org")
+		should.BeFalse(t, exists, should.WithMessage("Unknown code should not be created"))
+	})
+
+	t.Run("should delete existing codes only", func(t *testing.T) {
+		s := newStore(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		deleted, err := s.Delete("abc123")
+		should.BeNil(t, err)
+		should.BeTrue(t, deleted, should.WithMessage("Existing code should be deleted"))
+		deleted, _ = s.Delete("abc123")
+		should.BeFalse(t, deleted, should.WithMessage("Deleting twice should report a missing code"))
+	})
+
+	t.Run("should count clicks when resolving", func(t *testing.T) {
+		s := newStore(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		_, clicks, _, _ := s.Stats("abc123")
+		should.BeEqual(t, clicks.Total, uint64(0), should.WithMessage("New links should start with no clicks"))
+
+		s.Resolve("abc123", time.Now(), false)
+		s.Resolve("abc123", time.Now(), false)
+		s.Resolve("missing", time.Now(), false)
+
+		_, clicks, _, _ = s.Stats("abc123")
+		should.BeEqual(t, clicks.Total, uint64(2), should.WithMessage("Every resolve should count as a click"))
+		_, _, exists, _ := s.Stats("missing")
+		should.BeFalse(t, exists, should.WithMessage("Unknown codes should not gain stats"))
+	})
+
+	t.Run("should count bot clicks apart", func(t *testing.T) {
+		s := newStore(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		s.Resolve("abc123", time.Now(), false)
+		s.Resolve("abc123", time.Now(), true)
+		s.Resolve("abc123", time.Now(), true)
+
+		_, clicks, _, err := s.Stats("abc123")
+		should.BeNil(t, err)
+		should.BeEqual(t, clicks, ClickCounts{Total: 3, Bot: 2})
+		should.BeEqual(t, clicks.Human(), uint64(1))
+	})
+
+	t.Run("should forget clicks of deleted codes", func(t *testing.T) {
+		s := newStore(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com"})
+		s.Resolve("abc123", time.Now(), false)
+		s.Resolve("abc123", time.Now(), true)
+		s.Delete("abc123")
+		s.Set("abc123", URLRecord{Original: "https://other.com"})
+
+		_, clicks, _, _ := s.Stats("abc123")
+		should.BeEqual(t, clicks, ClickCounts{}, should.WithMessage("A reused code should start from zero"))
+	})
+
+	t.Run("should find codes by original URL", func(t *testing.T) {
+		s := newStore(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		shortCode, exists, _ := s.LookupOriginal("https://example.com", time.Now())
+		should.BeTrue(t, exists, should.WithMessage("Indexed URL should be found"))
+		should.BeEqual(t, shortCode, "abc123")
+
+		s.Delete("abc123")
+		_, exists, _ = s.LookupOriginal("https://example.com", time.Now())
+		should.BeFalse(t, exists, should.WithMessage("Deleted links should leave the index"))
+	})
+
+	t.Run("should not find expired links by original URL", func(t *testing.T) {
+		s := newStore(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com", ExpiresAt: time.Now().Add(-time.Second)})
+
+		_, exists, _ := s.LookupOriginal("https://example.com", time.Now())
+		should.BeFalse(t, exists, should.WithMessage("Expired links should not be reused"))
+	})
+
+	t.Run("should list every stored mapping", func(t *testing.T) {
+		s := newStore(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com"})
+		s.Set("def456", URLRecord{Original: "https://example.org"})
+
+		mappings, err := s.List()
+		should.BeNil(t, err)
+		should.BeEqual(t, len(mappings), 2)
+		should.BeEqual(t, mappings["def456"].Original, "https://example.org")
+	})
+
+	t.Run("should count stored mappings", func(t *testing.T) {
+		s := newStore(t)
+		s.Set("abc123", URLRecord{Original: "https://example.com"})
+		s.Set("def456", URLRecord{Original: "https://example.org"})
+		s.Delete("abc123")
+
+		n, err := s.Count()
+		should.BeNil(t, err)
+		should.BeEqual(t, n, 1)
+	})
+
+	t.Run("should delete expired records only", func(t *testing.T) {
+		now := time.Now()
+		s := newStore(t)
+		s.Set("old", URLRecord{Original: "https://example.com", ExpiresAt: now.Add(-time.Minute)})
+		s.Set("new", URLRecord{Original: "https://example.org", ExpiresAt: now.Add(time.Minute)})
+		s.Set("forever", URLRecord{Original: "https://example.net"})
+
+		removed, err := s.DeleteExpired(now)
+		should.BeNil(t, err)
+		should.BeEqual(t, removed, []string{"old"})
+
+		_, exists, _ := s.Get("old")
+		should.BeFalse(t, exists, should.WithMessage("Expired record should be removed"))
+		record, exists, _ := s.Get("new")
+		should.BeTrue(t, exists, should.WithMessage("Unexpired record should be kept"))
+		should.BeTrue(t, record.ExpiresAt.Equal(now.Add(time.Minute)), should.WithMessage("Expiry should round trip"))
+	})
+
+	t.Run("should handle concurrent writes and reads", func(t *testing.T) {
+		s := newStore(t)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				s.Set(fmt.Sprintf("code%d", i), URLRecord{Original: fmt.Sprintf("https://example.com/%d", i)})
+			}(i)
+			go func(i int) {
+				defer wg.Done()
+				s.Get(fmt.Sprintf("code%d", i))
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < 50; i++ {
+			record, exists, _ := s.Get(fmt.Sprintf("code%d", i))
+			should.BeTrue(t, exists, should.WithMessage("Every concurrent write should be stored"))
+			should.BeEqual(t, record.Original, fmt.Sprintf("https://example.com/%d", i))
+		}
+	})
+}
+
+// errStoreDown is returned by every failingStore method
+var errStoreDown = errors.New("store down")
+
+// failingStore is a URLStore whose backend is unreachable
+type failingStore struct{}
+
+func (failingStore) Get(string) (URLRecord, bool, error) { return URLRecord{}, false, errStoreDown }
+func (failingStore) Set(string, URLRecord) error         { return errStoreDown }
+func (failingStore) SetIfAbsent(string, URLRecord) (bool, error) {
+	return false, errStoreDown
+}
+func (failingStore) SetAllIfAbsent(map[string]URLRecord) ([]string, error) {
+	return nil, errStoreDown
+}
+func (failingStore) UpdateOriginal(string, string) (URLRecord, bool, error) {
+	return URLRecord{}, false, errStoreDown
+}
+func (failingStore) Delete(string) (bool, error)               { return false, errStoreDown }
+func (failingStore) List() (map[string]URLRecord, error)       { return nil, errStoreDown }
+func (failingStore) Count() (int, error)                       { return 0, errStoreDown }
+func (failingStore) DeleteExpired(time.Time) ([]string, error) { return nil, errStoreDown }
+func (failingStore) Resolve(string, time.Time, bool) (URLRecord, bool, error) {
+	return URLRecord{}, false, errStoreDown
+}
+func (failingStore) Stats(string) (URLRecord, ClickCounts, bool, error) {
+	return URLRecord{}, ClickCounts{}, false, errStoreDown
+}
+func (failingStore) LookupOriginal(string, time.Time) (string, bool, error) {
+	return "", false, errStoreDown
+}
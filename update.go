@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// updateRequest is the body accepted by updateHandler
+type updateRequest struct {
+	Original string `json:"original"`
+}
+
+// updateHandler points the short code of PUT /shorten/{code} or PUT /{code}
+// at a new destination, keeping its expiry and click count. Permanent links
+// keep theirs, browsers cache their 301 and would not see the change
+func updateHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := shortCodeParam(r)
+
+	var body updateRequest
+	if apiErr := decodeJSONBody(w, r, &body); apiErr != nil {
+		apiErr.write(w)
+		return
+	}
+	if err := validateURL(body.Original); err != nil {
+		errorResponse(w, err.Error(), errCodeInvalidURL, http.StatusBadRequest)
+		return
+	}
+	if err := checkDestination(r.Context(), body.Original); err != nil {
+		destinationError(err).write(w)
+		return
+	}
+
+	current, exists, err := store.Get(shortCode)
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+	if exists && current.Permanent {
+		errorResponse(w, "permanent links cannot change their destination", errCodeConflict, http.StatusConflict)
+		return
+	}
+
+	record, exists, err := store.UpdateOriginal(shortCode, body.Original)
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+	if !exists {
+		errorResponse(w, "Short code not found", errCodeNotFound, http.StatusNotFound)
+		return
+	}
+	persist()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newLinkEntry(shortCode, record))
+}
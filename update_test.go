@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestUpdateHandler(t *testing.T) {
+	putUpdate := func(shortCode, body string) *httptest.ResponseRecorder {
+		req := jsonRequest(http.MethodPut, "/shorten/"+shortCode, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		route(w, req)
+		return w
+	}
+
+	t.Run("should point the code at the new URL", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := putUpdate("abc123", `{"original":"https://example.org"}`)
+
+		should.BeEqual(t, w.Code, http.StatusOK)
+		should.BeEqual(t, w.Header().Get("Content-Type"), "application/json")
+
+		var response linkEntry
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeNil(t, err, should.WithMessage("Response should be valid JSON"))
+		should.BeEqual(t, response, linkEntry{
+			ShortCode:   "abc123",
+			OriginalURL: "https://example.org",
+			ShortURL:    shortURL("abc123"),
+		})
+
+		record, _, _ := store.Get("abc123")
+		should.BeEqual(t, record.Original, "https://example.org")
+	})
+
+	t.Run("should keep the creation time", func(t *testing.T) {
+		resetStore()
+		createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		store.Set("abc123", URLRecord{Original: "https://example.com", CreatedAt: createdAt})
+
+		w := putUpdate("abc123", `{"original":"https://example.org"}`)
+
+		var response linkEntry
+		json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeTrue(t, response.CreatedAt.Equal(createdAt), should.WithMessage("Updating should not reset the creation time"))
+	})
+
+	t.Run("should not change the destination of permanent links", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com", Permanent: true})
+
+		w := putUpdate("abc123", `{"original":"https://example.org"}`)
+
+		should.BeEqual(t, w.Code, http.StatusConflict)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeConflict)
+		record, _, _ := store.Get("abc123")
+		should.BeEqual(t, record.Original, "https://example.com")
+	})
+
+	t.Run("should return 404 for unknown codes", func(t *testing.T) {
+		resetStore()
+
+		w := putUpdate("missing", `{"original":"https://example.org"}`)
+
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeNotFound)
+		_, exists, _ := store.Get("missing")
+		should.BeFalse(t, exists, should.WithMessage("Update should not create links"))
+	})
+
+	t.Run("should reject an invalid replacement URL", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := putUpdate("abc123", `{"original":"javascript:alert(1)"}`)
+
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeInvalidURL)
+		record, _, _ := store.Get("abc123")
+		should.BeEqual(t, record.Original, "https://example.com", should.WithMessage("Rejected update should keep the old URL"))
+	})
+
+	t.Run("should reject a malformed body", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := putUpdate("abc123", `not json`)
+
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeInvalidBody)
+	})
+
+	t.Run("should update through PUT /{code} as well", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		store.Set("team:abc123", URLRecord{Original: "https://example.com"})
+
+		for _, target := range []string{"/abc123", "/team/abc123"} {
+			req := jsonRequest(http.MethodPut, target, strings.NewReader(`{"original":"https://example.org"}`))
+			w := httptest.NewRecorder()
+			route(w, req)
+			should.BeEqual(t, w.Code, http.StatusOK, should.WithMessage("PUT "+target))
+		}
+
+		record, _, _ := store.Get("team:abc123")
+		should.BeEqual(t, record.Original, "https://example.org")
+		req := jsonRequest(http.MethodPut, "/missing", strings.NewReader(`{"original":"https://example.org"}`))
+		w := httptest.NewRecorder()
+		route(w, req)
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+	})
+
+	t.Run("should require the API key on PUT /{code}", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		apiKey = "secret"
+		defer func() { apiKey = "" }()
+
+		req := jsonRequest(http.MethodPut, "/abc123", strings.NewReader(`{"original":"https://example.org"}`))
+		w := httptest.NewRecorder()
+		route(w, req)
+
+		should.BeEqual(t, w.Code, http.StatusUnauthorized)
+		record, _, _ := store.Get("abc123")
+		should.BeEqual(t, record.Original, "https://example.com")
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import "strings"
+
+// botSignatures are lowercase substrings of the User-Agent headers sent by
+// crawlers, link previewers and HTTP libraries. Their clicks are counted
+// apart from the human ones
+var botSignatures = []string{
+	"googlebot", "bingbot", "yandexbot", "duckduckbot", "baiduspider", "slurp",
+	"facebookexternalhit", "twitterbot", "slackbot", "discordbot", "whatsapp", "telegrambot",
+	"crawler", "spider", "headlesschrome",
+	"curl/", "wget/", "python-requests", "python-urllib", "go-http-client", "okhttp", "java/",
+}
+
+// isBot reports whether userAgent looks like an automated client. A missing
+// User-Agent counts as one, browsers always send it
+func isBot(userAgent string) bool {
+	if strings.TrimSpace(userAgent) == "" {
+		return true
+	}
+	userAgent = strings.ToLower(userAgent)
+	for _, signature := range botSignatures {
+		if strings.Contains(userAgent, signature) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestIsBot(t *testing.T) {
+	t.Run("should recognize crawlers and HTTP libraries", func(t *testing.T) {
+		for _, userAgent := range []string{
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+			"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+			"curl/8.5.0",
+			"Wget/1.21.4",
+			"python-requests/2.31.0",
+			"Go-http-client/1.1",
+			"",
+		} {
+			should.BeTrue(t, isBot(userAgent), should.WithMessage(userAgent+" should be a bot"))
+		}
+	})
+
+	t.Run("should count browsers as human", func(t *testing.T) {
+		for _, userAgent := range []string{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+			"Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0",
+		} {
+			should.BeFalse(t, isBot(userAgent), should.WithMessage(userAgent+" should be human"))
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxURLLength caps the size of URLs accepted for shortening
+const maxURLLength = 2048
+
+var (
+	errURLEmpty         = errors.New("URL is required")
+	errURLTooLong       = errors.New("URL exceeds the maximum length")
+	errURLMalformed     = errors.New("URL is malformed")
+	errURLInvalidScheme = errors.New("URL scheme must be http or https")
+	errURLMissingHost   = errors.New("URL must include a host")
+	errURLSelf          = errors.New("cannot shorten a URL pointing to this server")
+
+	errAliasInvalidChars = errors.New("short code may only contain letters, numbers, '_' and '-'")
+	errAliasLength       = errors.New("short code length is out of range")
+	errAliasReserved     = errors.New("short code is reserved")
+)
+
+const (
+	minAliasLength = 3
+	// defaultMaxAliasLength is the longest accepted alias unless
+	// SNIPLINK_MAX_ALIAS_LENGTH says otherwise, up to aliasLengthCap
+	defaultMaxAliasLength = 32
+	aliasLengthCap        = 64
+)
+
+// maxAliasLength is the longest accepted alias
+var maxAliasLength = defaultMaxAliasLength
+
+var aliasPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// validateURL checks that raw is a well formed absolute http or https URL
+// that is safe to store and redirect to. Anything else, javascript: and data:
+// URIs in particular, would turn the redirect endpoint into a phishing or XSS
+// gadget, and URLs of this service would let links redirect in chains or
+// loops
+func validateURL(raw string) error {
+	if strings.TrimSpace(raw) == "" {
+		return errURLEmpty
+	}
+	if len(raw) > maxURLLength {
+		return errURLTooLong
+	}
+
+	parsed, err := url.ParseRequestURI(raw)
+	if err != nil {
+		return errURLMalformed
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return errURLInvalidScheme
+	}
+	if parsed.Host == "" {
+		return errURLMissingHost
+	}
+	if isOwnURL(parsed) {
+		return errURLSelf
+	}
+	return nil
+}
+
+// isOwnURL reports whether u is on the host and port of baseURL
+func isOwnURL(u *url.URL) bool {
+	base, err := url.Parse(baseURL)
+	if err != nil || base.Host == "" {
+		return false
+	}
+	// A trailing dot names the same host, fully qualified
+	hostname := strings.TrimSuffix(u.Hostname(), ".")
+	return strings.EqualFold(hostname, base.Hostname()) && urlPort(u) == urlPort(base)
+}
+
+// urlPort returns the port of u, the default port of its scheme when it has
+// none
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if u.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// validateAlias checks that a caller supplied short code is between
+// minAliasLength and maxAliasLength characters of letters, numbers, '_'
+// and '-', and is not shadowed by another route. Whitespace is rejected
+// rather than trimmed, a trimmed alias would not be the one the caller
+// asked for
+func validateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return errAliasInvalidChars
+	}
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength {
+		return fmt.Errorf("%w, it must be %d to %d characters", errAliasLength, minAliasLength, maxAliasLength)
+	}
+	if reservedCode(alias) {
+		return errAliasReserved
+	}
+	return nil
+}
+
+// parseMaxAliasLength parses the alias length limit setting, which may not
+// be below minAliasLength or above aliasLengthCap
+func parseMaxAliasLength(raw string) (int, error) {
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < minAliasLength || n > aliasLengthCap {
+		return 0, fmt.Errorf("max alias length %q is not a number between %d and %d", raw, minAliasLength, aliasLengthCap)
+	}
+	return n, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want error
+	}{
+		{name: "empty string", raw: "", want: errURLEmpty},
+		{name: "whitespace only", raw: "   ", want: errURLEmpty},
+		{name: "missing scheme", raw: "example.com/path", want: errURLMalformed},
+		{name: "relative path", raw: "/just/a/path", want: errURLInvalidScheme},
+		{name: "ftp scheme", raw: "ftp://example.com/file", want: errURLInvalidScheme},
+		{name: "javascript scheme", raw: "javascript:alert(1)", want: errURLInvalidScheme},
+		{name: "garbage", raw: "not a url", want: errURLMalformed},
+		{name: "data scheme", raw: "data:text/html,<script>alert(1)</script>", want: errURLInvalidScheme},
+		{name: "malformed", raw: "http://exa mple.com", want: errURLMalformed},
+		{name: "missing host", raw: "https:///path", want: errURLMissingHost},
+		{name: "too long", raw: "https://example.com/" + strings.Repeat("a", maxURLLength), want: errURLTooLong},
+		{name: "own short URL", raw: "http://localhost:8080/abc123", want: errURLSelf},
+		{name: "own host in another case", raw: "http://LocalHost:8080/", want: errURLSelf},
+		{name: "own host fully qualified", raw: "http://localhost.:8080/abc123", want: errURLSelf},
+		{name: "same host on another port", raw: "http://localhost:9090/abc123", want: nil},
+		{name: "valid http", raw: "http://example.com", want: nil},
+		{name: "valid https with path and query", raw: "https://example.com/very/long/url?q=1", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			should.BeEqual(t, validateURL(tt.raw), tt.want)
+		})
+	}
+}
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		name  string
+		alias string
+		want  error
+	}{
+		{name: "letters", alias: "docs", want: nil},
+		{name: "letters and numbers", alias: "Docs2024", want: nil},
+		{name: "dashes and underscores", alias: "go-blog_2024", want: nil},
+		{name: "minimum length", alias: "abc", want: nil},
+		{name: "maximum length", alias: strings.Repeat("a", 32), want: nil},
+		{name: "too short", alias: "ab", want: errAliasLength},
+		{name: "too long", alias: strings.Repeat("a", 33), want: errAliasLength},
+		{name: "slash", alias: "docs/v2", want: errAliasInvalidChars},
+		{name: "space", alias: "my docs", want: errAliasInvalidChars},
+		{name: "surrounding whitespace", alias: " docs\t", want: errAliasInvalidChars},
+		{name: "unicode", alias: "döcs", want: errAliasInvalidChars},
+		{name: "route prefix", alias: "stats", want: errAliasReserved},
+		{name: "route suffix", alias: "info", want: errAliasReserved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAlias(tt.alias)
+			should.BeTrue(t, errors.Is(err, tt.want), should.WithMessage(fmt.Sprintf("got %v", err)))
+		})
+	}
+
+	t.Run("should apply the configured maximum", func(t *testing.T) {
+		maxAliasLength = 40
+		defer func() { maxAliasLength = defaultMaxAliasLength }()
+
+		should.BeNil(t, validateAlias(strings.Repeat("a", 40)))
+		err := validateAlias(strings.Repeat("a", 41))
+		should.BeTrue(t, errors.Is(err, errAliasLength))
+		should.BeEqual(t, err.Error(), "short code length is out of range, it must be 3 to 40 characters")
+	})
+}
+
+func TestParseMaxAliasLength(t *testing.T) {
+	t.Run("should accept lengths within the bounds", func(t *testing.T) {
+		for _, raw := range []string{"3", "32", "64"} {
+			n, err := parseMaxAliasLength(raw)
+			should.BeNil(t, err, should.WithMessage(raw))
+			should.BeEqual(t, strconv.Itoa(n), raw)
+		}
+	})
+
+	t.Run("should reject other values", func(t *testing.T) {
+		for _, raw := range []string{"2", "65", "10000", "-1", "abc", ""} {
+			_, err := parseMaxAliasLength(raw)
+			should.NotBeNil(t, err, should.WithMessage(raw))
+		}
+	})
+}
+
+func TestIsOwnURL(t *testing.T) {
+	baseURL = "https://snip.link"
+	defer func() { baseURL = defaultBaseURL }()
+
+	for raw, want := range map[string]bool{
+		"https://snip.link/abc123":     true,
+		"https://snip.link:443/abc123": true,
+		"https://SNIP.link/abc123":     true,
+		"http://snip.link:80/abc123":   false,
+		"https://other.link/abc123":    false,
+		"https://sub.snip.link/abc123": false,
+	} {
+		u, err := url.Parse(raw)
+		should.BeNil(t, err)
+		should.BeEqual(t, isOwnURL(u), want, should.WithMessage(raw))
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// webhookTimeout bounds each webhook delivery, connecting included
+	webhookTimeout = 5 * time.Second
+	// maxWebhooks caps the webhooks of each short code, every click is
+	// delivered to all of them
+	maxWebhooks = 10
+	// webhookWorkers is the number of deliveries in flight at once
+	webhookWorkers = 8
+	// webhookQueueSize caps the deliveries waiting for a worker, past it
+	// new ones are dropped
+	webhookQueueSize = 1000
+)
+
+var errTooManyWebhooks = errors.New("short code has the maximum number of webhooks")
+
+// webhookClient delivers webhooks. It does not follow redirects, they could
+// lead past the destination check of the target URL, and its dialer checks
+// the address it connects to against blockedNetworks, since the name of a
+// target may resolve elsewhere than when it was registered
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{Control: webhookDialControl}).DialContext,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// webhookDialControl refuses connections to addresses in blockedNetworks
+func webhookDialControl(network, address string, conn syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if blockedAddr(addrPort.Addr()) {
+		return errURLBlocked
+	}
+	return nil
+}
+
+// webhookRegistry keeps the webhook target URLs of every short code in
+// memory
+type webhookRegistry struct {
+	mu      sync.Mutex
+	targets map[string][]string
+}
+
+// webhooks are the webhooks registered through POST /webhooks
+var webhooks = newWebhookRegistry()
+
+// newWebhookRegistry creates an empty webhookRegistry
+func newWebhookRegistry() *webhookRegistry {
+	return &webhookRegistry{targets: make(map[string][]string)}
+}
+
+// register adds targetURL to the webhooks of shortCode. It reports false
+// when targetURL was already registered, and fails with errTooManyWebhooks
+// when shortCode has maxWebhooks
+func (reg *webhookRegistry) register(shortCode, targetURL string) (bool, error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	targets := reg.targets[shortCode]
+	if slices.Contains(targets, targetURL) {
+		return false, nil
+	}
+	if len(targets) >= maxWebhooks {
+		return false, errTooManyWebhooks
+	}
+	reg.targets[shortCode] = append(targets, targetURL)
+	return true, nil
+}
+
+// list returns a copy of the webhook target URLs of shortCode
+func (reg *webhookRegistry) list(shortCode string) []string {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	return slices.Clone(reg.targets[shortCode])
+}
+
+// forget drops the webhooks of shortCode
+func (reg *webhookRegistry) forget(shortCode string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	delete(reg.targets, shortCode)
+}
+
+// webhookPayload is the JSON body posted to webhooks for every click
+type webhookPayload struct {
+	ShortCode   string    `json:"short_code"`
+	OriginalURL string    `json:"original_url"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// webhookDelivery is a payload waiting in webhookQueue to be posted
+type webhookDelivery struct {
+	target    string
+	shortCode string
+	body      []byte
+}
+
+// webhookQueue holds the deliveries of notifyWebhooks until one of the
+// webhookWorkers posts them
+var webhookQueue = make(chan webhookDelivery, webhookQueueSize)
+
+// startWebhookWorkers starts the webhookWorkers on the first click with
+// webhooks, they run for the life of the process
+var startWebhookWorkers = sync.OnceFunc(func() {
+	for range webhookWorkers {
+		go func() {
+			for delivery := range webhookQueue {
+				deliverWebhook(delivery.target, delivery.shortCode, delivery.body)
+			}
+		}()
+	}
+})
+
+// notifyWebhooks queues a click of shortCode for each of its webhooks, the
+// webhook workers post them in the background. Deliveries that find the
+// queue full and failed ones are logged and not retried
+func notifyWebhooks(shortCode, originalURL string, clickedAt time.Time) {
+	targets := webhooks.list(shortCode)
+	if len(targets) == 0 {
+		return
+	}
+	body, err := json.Marshal(webhookPayload{ShortCode: shortCode, OriginalURL: originalURL, Timestamp: clickedAt})
+	if err != nil {
+		logger.Error("Failed to encode webhook payload", zap.Error(err))
+		return
+	}
+	startWebhookWorkers()
+	for _, target := range targets {
+		select {
+		case webhookQueue <- webhookDelivery{target: target, shortCode: shortCode, body: body}:
+		default:
+			logger.Warn("Webhook queue full, dropping delivery", zap.String("target_url", target), zap.String("short_code", shortCode))
+		}
+	}
+}
+
+// deliverWebhook posts body to target
+func deliverWebhook(target, shortCode string, body []byte) {
+	log := logger.With(zap.String("target_url", target), zap.String("short_code", shortCode))
+	resp, err := webhookClient.Post(target, mediaTypeJSON, bytes.NewReader(body))
+	if err != nil {
+		log.Warn("Webhook delivery failed", zap.Error(err))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.Warn("Webhook delivery rejected", zap.Int("status", resp.StatusCode))
+	}
+}
+
+// webhookRequest is the body of POST /webhooks
+type webhookRequest struct {
+	ShortCode string `json:"short_code"`
+	Namespace string `json:"namespace,omitempty"`
+	TargetURL string `json:"target_url"`
+}
+
+// webhookHandler registers a webhook notified of every click of an existing
+// link. New webhooks are answered with 201, already registered ones with
+// 200. Target URLs pass the same checks as the links themselves
+func webhookHandler(w http.ResponseWriter, r *http.Request) {
+	var body webhookRequest
+	if apiErr := decodeJSONBody(w, r, &body); apiErr != nil {
+		apiErr.write(w)
+		return
+	}
+	if err := validateURL(body.TargetURL); err != nil {
+		errorResponse(w, err.Error(), errCodeInvalidURL, http.StatusBadRequest)
+		return
+	}
+	if err := checkDestination(r.Context(), body.TargetURL); err != nil {
+		destinationError(err).write(w)
+		return
+	}
+
+	shortCode := namespacedCode(body.Namespace, body.ShortCode)
+	_, exists, err := store.Get(shortCode)
+	if err != nil {
+		storeFailure(w, r, err)
+		return
+	}
+	if !exists {
+		errorResponse(w, "Short code not found", errCodeNotFound, http.StatusNotFound)
+		return
+	}
+
+	added, err := webhooks.register(shortCode, body.TargetURL)
+	if err != nil {
+		errorResponse(w, err.Error(), errCodeConflict, http.StatusConflict)
+		return
+	}
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kairum-Labs/should"
+)
+
+func TestWebhookRegistry(t *testing.T) {
+	t.Run("should register each target once", func(t *testing.T) {
+		reg := newWebhookRegistry()
+
+		added, err := reg.register("abc123", "https://hooks.example.com/a")
+		should.BeNil(t, err)
+		should.BeTrue(t, added)
+
+		added, err = reg.register("abc123", "https://hooks.example.com/a")
+		should.BeNil(t, err)
+		should.BeFalse(t, added, should.WithMessage("A registered target should not be added twice"))
+
+		should.BeEqual(t, reg.list("abc123"), []string{"https://hooks.example.com/a"})
+	})
+
+	t.Run("should cap the webhooks of a code", func(t *testing.T) {
+		reg := newWebhookRegistry()
+		for i := range maxWebhooks {
+			reg.register("abc123", fmt.Sprintf("https://hooks.example.com/%d", i))
+		}
+
+		_, err := reg.register("abc123", "https://hooks.example.com/extra")
+
+		should.BeEqual(t, err, errTooManyWebhooks)
+		should.HaveLength(t, reg.list("abc123"), maxWebhooks)
+	})
+
+	t.Run("should forget the webhooks of a code", func(t *testing.T) {
+		reg := newWebhookRegistry()
+		reg.register("abc123", "https://hooks.example.com/a")
+		reg.register("def456", "https://hooks.example.com/b")
+
+		reg.forget("abc123")
+
+		should.BeEmpty(t, reg.list("abc123"))
+		should.HaveLength(t, reg.list("def456"), 1)
+	})
+}
+
+func TestWebhookClient(t *testing.T) {
+	t.Run("should refuse to dial blocked addresses", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("webhook reached a loopback server")
+		}))
+		defer server.Close()
+
+		_, err := webhookClient.Get(server.URL)
+
+		should.BeTrue(t, errors.Is(err, errURLBlocked))
+	})
+}
+
+func TestWebhookHandler(t *testing.T) {
+	apiKey = "secret"
+	defer func() { apiKey = "" }()
+	register := func(body string) *httptest.ResponseRecorder {
+		req := jsonRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		route(w, req)
+		return w
+	}
+
+	t.Run("should register a webhook with 201", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := register(`{"short_code":"abc123","target_url":"https://hooks.example.com/clicks"}`)
+
+		should.BeEqual(t, w.Code, http.StatusCreated)
+		var response webhookRequest
+		json.Unmarshal(w.Body.Bytes(), &response)
+		should.BeEqual(t, response, webhookRequest{ShortCode: "abc123", TargetURL: "https://hooks.example.com/clicks"})
+		should.BeEqual(t, webhooks.list("abc123"), []string{"https://hooks.example.com/clicks"})
+
+		w = register(`{"short_code":"abc123","target_url":"https://hooks.example.com/clicks"}`)
+		should.BeEqual(t, w.Code, http.StatusOK, should.WithMessage("Registering again should answer 200"))
+	})
+
+	t.Run("should register webhooks of namespaced links", func(t *testing.T) {
+		resetStore()
+		store.Set("team:abc123", URLRecord{Original: "https://example.com"})
+
+		w := register(`{"short_code":"abc123","namespace":"team","target_url":"https://hooks.example.com/clicks"}`)
+
+		should.BeEqual(t, w.Code, http.StatusCreated)
+		should.HaveLength(t, webhooks.list("team:abc123"), 1)
+	})
+
+	t.Run("should return 404 for unknown codes", func(t *testing.T) {
+		resetStore()
+
+		w := register(`{"short_code":"missing","target_url":"https://hooks.example.com/clicks"}`)
+
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeNotFound)
+	})
+
+	t.Run("should check the target like a link", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := register(`{"short_code":"abc123","target_url":"javascript:alert(1)"}`)
+		should.BeEqual(t, w.Code, http.StatusBadRequest)
+
+		w = register(`{"short_code":"abc123","target_url":"http://169.254.169.254/latest"}`)
+		should.BeEqual(t, w.Code, http.StatusForbidden)
+		should.BeEqual(t, decodeError(t, w).Code, errCodeBlockedURL)
+
+		should.BeEmpty(t, webhooks.list("abc123"))
+	})
+
+	t.Run("should return 409 past the webhook limit", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		for i := range maxWebhooks {
+			webhooks.register("abc123", fmt.Sprintf("https://hooks.example.com/%d", i))
+		}
+
+		w := register(`{"short_code":"abc123","target_url":"https://hooks.example.com/extra"}`)
+
+		should.BeEqual(t, w.Code, http.StatusConflict)
+		should.BeEqual(t, decodeError(t, w).Error, errTooManyWebhooks.Error())
+	})
+
+	t.Run("should require the API key", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+
+		w := httptest.NewRecorder()
+		route(w, jsonRequest(http.MethodPost, "/webhooks", strings.NewReader(`{"short_code":"abc123","target_url":"https://hooks.example.com/clicks"}`)))
+
+		should.BeEqual(t, w.Code, http.StatusUnauthorized)
+		should.BeEmpty(t, webhooks.list("abc123"))
+	})
+
+	t.Run("should not exist without an API key", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		apiKey = ""
+		defer func() { apiKey = "secret" }()
+
+		w := register(`{"short_code":"abc123","target_url":"https://hooks.example.com/clicks"}`)
+
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+		should.BeEmpty(t, webhooks.list("abc123"))
+	})
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	// The test server listens on loopback
+	blockedNetworks = nil
+	defer func() { blockedNetworks = defaultBlockedNetworks }()
+
+	// receive starts a webhook target answering status and returns the
+	// payloads it is sent
+	receive := func(t *testing.T, status int) (*httptest.Server, chan webhookPayload) {
+		payloads := make(chan webhookPayload, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			should.BeEqual(t, r.Method, http.MethodPost)
+			should.BeEqual(t, r.Header.Get("Content-Type"), mediaTypeJSON)
+			var payload webhookPayload
+			json.NewDecoder(r.Body).Decode(&payload)
+			w.WriteHeader(status)
+			payloads <- payload
+		}))
+		t.Cleanup(server.Close)
+		return server, payloads
+	}
+	await := func(t *testing.T, payloads chan webhookPayload) webhookPayload {
+		t.Helper()
+		select {
+		case payload := <-payloads:
+			return payload
+		case <-time.After(webhookTimeout):
+			t.Fatal("Webhook was not delivered")
+			return webhookPayload{}
+		}
+	}
+
+	t.Run("should post every click to the webhook", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		server, payloads := receive(t, http.StatusNoContent)
+		webhooks.register("abc123", server.URL)
+
+		before := time.Now()
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/abc123", nil))
+		should.BeEqual(t, w.Code, redirectStatus)
+
+		payload := await(t, payloads)
+		should.BeEqual(t, payload.ShortCode, "abc123")
+		should.BeEqual(t, payload.OriginalURL, "https://example.com")
+		should.BeFalse(t, payload.Timestamp.Before(before.Truncate(time.Second)), should.WithMessage("Timestamp should be the click time"))
+	})
+
+	t.Run("should redirect whatever the webhook answers", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		server, payloads := receive(t, http.StatusInternalServerError)
+		webhooks.register("abc123", server.URL)
+
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/abc123", nil))
+
+		should.BeEqual(t, w.Code, redirectStatus)
+		await(t, payloads)
+	})
+
+	t.Run("should not post misses", func(t *testing.T) {
+		resetStore()
+		server, payloads := receive(t, http.StatusOK)
+		webhooks.register("abc123", server.URL)
+
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodGet, "/abc123", nil))
+
+		should.BeEqual(t, w.Code, http.StatusNotFound)
+		select {
+		case <-payloads:
+			t.Fatal("A miss should not be delivered")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("should drop the webhooks of deleted links", func(t *testing.T) {
+		resetStore()
+		store.Set("abc123", URLRecord{Original: "https://example.com"})
+		webhooks.register("abc123", "https://hooks.example.com/clicks")
+
+		w := httptest.NewRecorder()
+		route(w, httptest.NewRequest(http.MethodDelete, "/abc123", nil))
+
+		should.BeEqual(t, w.Code, http.StatusNoContent)
+		should.BeEmpty(t, webhooks.list("abc123"))
+	})
+}